@@ -0,0 +1,125 @@
+// Package auth authenticates API clients by API key or bearer JWT, and carries the
+// authenticated client in the context of their requests.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// APIKeyHeader carries the API key of a client
+const APIKeyHeader = "X-API-Key"
+
+// ErrUnauthenticated is returned for credentials that are malformed, unknown, revoked or expired
+var ErrUnauthenticated = errors.New("invalid credentials")
+
+// Client is the authenticated caller of a request
+type Client struct {
+	// ID identifies the client, receipts it submits are owned by it
+	ID string
+	// Admin clients may call the admin routes and see the receipts of every client
+	Admin bool
+}
+
+type clientKey struct{}
+
+// NewContext returns a copy of ctx carrying client
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// FromContext returns the client carried by ctx, and false if the request was not authenticated
+func FromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(Client)
+	return client, ok
+}
+
+// KeyVerifier looks up the client an API key belongs to
+type KeyVerifier interface {
+	// VerifyKey returns the client of an active key, or an error matching ErrUnauthenticated
+	VerifyKey(key string) (Client, error)
+}
+
+// Options configures Middleware
+type Options struct {
+	// Keys verifies the API keys sent in the X-API-Key header, which are rejected if it is nil
+	Keys KeyVerifier
+	// Tokens verifies the JWTs sent as bearer tokens, which are rejected if it is nil
+	Tokens *TokenVerifier
+	// AdminPaths are the paths whose routes, and the routes below them, only admin clients may call
+	AdminPaths []string
+}
+
+// Middleware authenticates every request by its API key or bearer JWT, responding 401 to requests
+// without valid credentials and 403 to non-admin clients calling an admin route. The client is put in
+// the request context and its ID in the context logger.
+func Middleware(opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		client, err := authenticate(c.Request, opts)
+		if err != nil {
+			if !errors.Is(err, ErrUnauthenticated) {
+				c.Error(err)
+				abort(c, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			logging.FromContext(ctx).Info("request not authenticated", "error", err)
+			c.Header("WWW-Authenticate", `Bearer realm="receipt-processor"`)
+			abort(c, http.StatusUnauthorized, "Missing or invalid credentials")
+			return
+		}
+		if !client.Admin && isAdminRoute(c.FullPath(), opts.AdminPaths) {
+			abort(c, http.StatusForbidden, "Admin credentials required")
+			return
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", client.ID))
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("client_id", client.ID))
+		c.Request = c.Request.WithContext(NewContext(ctx, client))
+		c.Next()
+	}
+}
+
+// authenticate verifies the API key of a request, or else its bearer token
+func authenticate(r *http.Request, opts Options) (Client, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		if opts.Keys == nil {
+			return Client{}, fmt.Errorf("%w: api keys are not accepted", ErrUnauthenticated)
+		}
+		return opts.Keys.VerifyKey(key)
+	}
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return Client{}, fmt.Errorf("%w: no api key or bearer token", ErrUnauthenticated)
+	}
+	if opts.Tokens == nil {
+		return Client{}, fmt.Errorf("%w: bearer tokens are not accepted", ErrUnauthenticated)
+	}
+	return opts.Tokens.Verify(strings.TrimSpace(token))
+}
+
+// isAdminRoute reports whether a route is one of the admin paths or below one
+func isAdminRoute(route string, adminPaths []string) bool {
+	for _, path := range adminPaths {
+		path = strings.TrimSuffix(path, "/")
+		if route == path || strings.HasPrefix(route, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// abort responds with an error tagged with the ID of the request and skips the handlers
+func abort(c *gin.Context, status int, message string) {
+	c.Abort()
+	logging.WriteError(c, status, models.ErrorResponse{Error: message})
+}
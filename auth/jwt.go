@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"receipt-processor/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// AdminScope in the scope claim of a JWT makes its client an admin
+const AdminScope = "receipts:admin"
+
+// tokenLeeway tolerates clock skew between the issuer and the service
+const tokenLeeway = time.Minute
+
+// signatureAlgorithms are the asymmetric algorithms tokens may be signed with.
+// Symmetric algorithms are refused, since a JWKS of public keys cannot verify them.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// TokenOptions configures a TokenVerifier
+type TokenOptions struct {
+	// JWKSFile is a JSON Web Key Set holding the public keys tokens are signed with
+	JWKSFile string
+	// Issuer is required in the iss claim, which is not checked if empty
+	Issuer string
+	// Audience is required in the aud claim, which is not checked if empty
+	Audience string
+}
+
+// TokenVerifier verifies bearer JWTs against the keys of a local JWKS file.
+// The file is read again whenever it changes, so keys can be rotated without a restart.
+type TokenVerifier struct {
+	opts TokenOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	modTime time.Time
+	keys    jose.JSONWebKeySet
+}
+
+// tokenClaims are the claims of a token read by the verifier
+type tokenClaims struct {
+	jwt.Claims
+	// Scope is a space separated list of scopes, as in OAuth 2.0
+	Scope string `json:"scope"`
+}
+
+// NewTokenVerifier loads the JWKS file, failing if it cannot be read or holds no keys
+func NewTokenVerifier(opts TokenOptions) (*TokenVerifier, error) {
+	v := &TokenVerifier{opts: opts, now: time.Now}
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks the signature and claims of a token and returns the client named by its sub claim.
+// Tokens must expire, and are admin if their scope claim includes AdminScope.
+func (v *TokenVerifier) Verify(token string) (Client, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Client{}, fmt.Errorf("%w: malformed token: %v", ErrUnauthenticated, err)
+	}
+	keys := v.keySet()
+	var candidates []jose.JSONWebKey
+	if kid := parsed.Headers[0].KeyID; kid != "" {
+		candidates = keys.Key(kid)
+	} else {
+		candidates = keys.Keys
+	}
+
+	var claims tokenClaims
+	verified := false
+	for _, key := range candidates {
+		if err := parsed.Claims(key.Public(), &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Client{}, fmt.Errorf("%w: token is not signed by a known key", ErrUnauthenticated)
+	}
+
+	expected := jwt.Expected{Issuer: v.opts.Issuer, Time: v.now()}
+	if v.opts.Audience != "" {
+		expected.AnyAudience = jwt.Audience{v.opts.Audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, tokenLeeway); err != nil {
+		return Client{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	switch {
+	case claims.Expiry == nil:
+		return Client{}, fmt.Errorf("%w: token does not expire", ErrUnauthenticated)
+	case claims.Subject == "":
+		return Client{}, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	case !models.ValidClientID(claims.Subject):
+		// The subject names the account the client earns points in
+		return Client{}, fmt.Errorf("%w: token subject %q is not a valid client ID", ErrUnauthenticated, claims.Subject)
+	}
+	return Client{ID: claims.Subject, Admin: slices.Contains(strings.Fields(claims.Scope), AdminScope)}, nil
+}
+
+// keySet returns the current keys, reading the file again if it changed since it was last read.
+// The previous keys are kept if the changed file cannot be read, until it changes again.
+func (v *TokenVerifier) keySet() jose.JSONWebKeySet {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if info, err := os.Stat(v.opts.JWKSFile); err == nil && !info.ModTime().Equal(v.modTime) {
+		if err := v.load(); err != nil {
+			v.modTime = info.ModTime()
+			slog.Warn("failed to reload JWKS file, keeping the previous keys", "file", v.opts.JWKSFile, "error", err)
+		}
+	}
+	return v.keys
+}
+
+// load reads the JWKS file. The caller must hold mu once the verifier is shared.
+func (v *TokenVerifier) load() error {
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	raw, err := os.ReadFile(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return fmt.Errorf("failed to parse JWKS file %s: %w", v.opts.JWKSFile, err)
+	}
+	if len(keys.Keys) == 0 {
+		return fmt.Errorf("JWKS file %s holds no keys", v.opts.JWKSFile)
+	}
+	v.keys = keys
+	v.modTime = info.ModTime()
+	return nil
+}
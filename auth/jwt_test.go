@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+// signingKey is a private key the tests sign tokens with
+type signingKey struct {
+	id  string
+	key *ecdsa.PrivateKey
+}
+
+func newSigningKey(t *testing.T, id string) signingKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return signingKey{id: id, key: key}
+}
+
+// sign returns a token with the claims signed by the key
+func (k signingKey) sign(t *testing.T, claims any) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: k.key, KeyID: k.id}}, nil)
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+// writeJWKS writes the public keys to a JWKS file
+func writeJWKS(t *testing.T, path string, keys ...signingKey) {
+	var set jose.JSONWebKeySet
+	for _, k := range keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{Key: k.key.Public(), KeyID: k.id, Algorithm: string(jose.ES256), Use: "sig"})
+	}
+	raw, err := json.Marshal(set)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+}
+
+func TestTokenVerifier(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	key := newSigningKey(t, "k1")
+	writeJWKS(t, path, key)
+	verifier, err := NewTokenVerifier(TokenOptions{JWKSFile: path, Issuer: "https://issuer.example.com", Audience: "receipts"})
+	require.NoError(t, err)
+	verifier.now = func() time.Time { return now }
+
+	valid := jwt.Claims{
+		Issuer:   "https://issuer.example.com",
+		Subject:  "acme",
+		Audience: jwt.Audience{"receipts"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	client, err := verifier.Verify(key.sign(t, valid))
+	require.NoError(t, err)
+	require.Equal(t, Client{ID: "acme"}, client)
+
+	client, err = verifier.Verify(key.sign(t, tokenClaims{Claims: valid, Scope: "receipts:read " + AdminScope}))
+	require.NoError(t, err)
+	require.Equal(t, Client{ID: "acme", Admin: true}, client)
+
+	// Subjects of identity providers are client IDs too
+	provider := valid
+	provider.Subject = "auth0|123"
+	client, err = verifier.Verify(key.sign(t, provider))
+	require.NoError(t, err)
+	require.Equal(t, Client{ID: "auth0|123"}, client)
+
+	expired, wrongIssuer, wrongAudience, noExpiry, noSubject, badSubject := valid, valid, valid, valid, valid, valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-time.Hour))
+	wrongIssuer.Issuer = "https://other.example.com"
+	wrongAudience.Audience = jwt.Audience{"billing"}
+	noExpiry.Expiry = nil
+	noSubject.Subject = ""
+	badSubject.Subject = "acme/../globex"
+	for _, token := range []string{
+		"not a token",
+		key.sign(t, expired),
+		key.sign(t, wrongIssuer),
+		key.sign(t, wrongAudience),
+		key.sign(t, noExpiry),
+		key.sign(t, noSubject),
+		key.sign(t, badSubject),
+		// Signed by a key missing from the JWKS
+		newSigningKey(t, "k1").sign(t, valid),
+	} {
+		_, err := verifier.Verify(token)
+		require.ErrorIs(t, err, ErrUnauthenticated)
+	}
+}
+
+func TestTokenVerifierReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	oldKey, newKey := newSigningKey(t, "old"), newSigningKey(t, "new")
+	writeJWKS(t, path, oldKey)
+	verifier, err := NewTokenVerifier(TokenOptions{JWKSFile: path})
+	require.NoError(t, err)
+	claims := jwt.Claims{Subject: "acme", Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	_, err = verifier.Verify(newKey.sign(t, claims))
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	// Rotating the keys in the file takes effect without a restart
+	writeJWKS(t, path, newKey)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	_, err = verifier.Verify(newKey.sign(t, claims))
+	require.NoError(t, err)
+	_, err = verifier.Verify(oldKey.sign(t, claims))
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	// A broken file keeps the previous keys
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = verifier.Verify(newKey.sign(t, claims))
+	require.NoError(t, err)
+}
+
+func TestNewTokenVerifierErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewTokenVerifier(TokenOptions{JWKSFile: filepath.Join(dir, "missing.json")})
+	require.ErrorContains(t, err, "failed to read JWKS file")
+
+	empty := filepath.Join(dir, "empty.json")
+	require.NoError(t, os.WriteFile(empty, []byte(`{"keys": []}`), 0o644))
+	_, err = NewTokenVerifier(TokenOptions{JWKSFile: empty})
+	require.ErrorContains(t, err, "holds no keys")
+}
@@ -0,0 +1,410 @@
+// Package config loads the settings of the service from defaults, a config file,
+// environment variables and command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
+	"receipt-processor/tracing"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of each flag, like RECEIPT_READ_TIMEOUT for -read-timeout
+const EnvPrefix = "RECEIPT_"
+
+// Config holds every setting of the service
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Storage  Storage  `yaml:"storage"`
+	Rules    Rules    `yaml:"rules"`
+	Receipts Receipts `yaml:"receipts"`
+	CORS     CORS     `yaml:"cors"`
+	Log      Log      `yaml:"log"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Auth     Auth     `yaml:"auth"`
+	Features Features `yaml:"features"`
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Port int `yaml:"port"`
+	// Mode is the gin mode: debug, release or test
+	Mode              string        `yaml:"mode"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	// MaxBodyBytes limits the size of request bodies, zero means no limit
+	MaxBodyBytes    int64         `yaml:"maxBodyBytes"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+// Storage selects and configures the storage backend
+type Storage struct {
+	// Backend is memory, file or sql
+	Backend string `yaml:"backend"`
+	DataDir string `yaml:"dataDir"`
+	Fsync   string `yaml:"fsync"`
+	DSN     string `yaml:"dsn"`
+}
+
+// Rules selects the rule sets receipts are scored with
+type Rules struct {
+	// File is the current rule set, the built-in rules are used if empty
+	File string `yaml:"file"`
+	// HistoryDir holds older rule sets available for rescoring
+	HistoryDir string `yaml:"historyDir"`
+}
+
+// Receipts configures receipt processing
+type Receipts struct {
+	IdempotencyWindow time.Duration `yaml:"idempotencyWindow"`
+	Duplicates        string        `yaml:"duplicates"`
+	MaxBatchSize      int           `yaml:"maxBatchSize"`
+	// Workers process receipts submitted with async=true, zero disables async processing
+	Workers      int           `yaml:"workers"`
+	QueueSize    int           `yaml:"queueSize"`
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+}
+
+// CORS configures cross-origin requests
+type CORS struct {
+	// AllowedOrigins may send cross-origin requests, "*" allows every origin and none disables CORS
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+// Log configures the application log
+type Log struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+	// LogReceipts logs the contents of receipts, which are redacted by default
+	LogReceipts bool `yaml:"logReceipts"`
+}
+
+// Tracing configures OpenTelemetry tracing
+type Tracing struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP HTTP receiver, OTEL_EXPORTER_OTLP_ENDPOINT is used if empty
+	Endpoint string `yaml:"endpoint"`
+	// Insecure sends spans to the OTLP receiver over plain HTTP
+	Insecure bool `yaml:"insecure"`
+	// SampleRatio is the fraction of new traces recorded, from 0 to 1
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// Auth configures the authentication of API clients
+type Auth struct {
+	// Enabled requires an API key or bearer JWT on every request but the health checks,
+	// and scopes receipts to the client that submitted them
+	Enabled bool `yaml:"enabled"`
+	// JWKSFile holds the public keys bearer JWTs are signed with, JWTs are refused if empty
+	JWKSFile string `yaml:"jwksFile"`
+	// Issuer is required in the iss claim of JWTs, which is not checked if empty
+	Issuer string `yaml:"issuer"`
+	// Audience is required in the aud claim of JWTs, which is not checked if empty
+	Audience string `yaml:"audience"`
+}
+
+// Features turns optional parts of the service on or off
+type Features struct {
+	// Docs serves the Swagger UI at /docs
+	Docs bool `yaml:"docs"`
+	// Webhooks serves /webhooks and delivers receipt events
+	Webhooks bool `yaml:"webhooks"`
+	// Metrics serves Prometheus metrics at /metrics
+	Metrics bool `yaml:"metrics"`
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:              8080,
+			Mode:              "debug",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+			MaxBodyBytes:      1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Storage: Storage{Backend: "memory", DataDir: "data", Fsync: string(repo.SyncAlways), DSN: "receipts.db"},
+		Receipts: Receipts{
+			IdempotencyWindow: 24 * time.Hour,
+			Duplicates:        string(receiptSvc.DuplicateAllow),
+			MaxBatchSize:      100,
+			Workers:           4,
+			QueueSize:         100,
+			DrainTimeout:      30 * time.Second,
+		},
+		CORS:     CORS{AllowedOrigins: []string{"*"}},
+		Log:      Log{Level: "info", Format: "json"},
+		Tracing:  Tracing{Exporter: tracing.ExporterNone, SampleRatio: 1},
+		Features: Features{Docs: true, Webhooks: true, Metrics: true},
+	}
+}
+
+// Load builds the settings from the defaults, the config file, the environment and the command line
+// arguments, each overriding the ones before, and validates them. The config file is given by the
+// -config flag or the RECEIPT_CONFIG environment variable. The flags of the settings are added to fs,
+// which may hold flags of its own; those are parsed but not read from the environment.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	var file string
+	names := bind(fs, &cfg, &file)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// The flags were parsed to find the config file, apply them again over the file and the environment
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	if value, ok := lookupEnv(EnvPrefix + "CONFIG"); ok && given["config"] == "" {
+		file = value
+	}
+	cfg = Default()
+	if file != "" {
+		if err := cfg.readFile(file); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, name := range names {
+		if _, ok := given[name]; ok {
+			continue
+		}
+		env := EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if value, ok := lookupEnv(env); ok {
+			if err := fs.Set(name, value); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+	for name, value := range given {
+		if slices.Contains(names, name) {
+			if err := fs.Set(name, value); err != nil {
+				return Config{}, fmt.Errorf("invalid -%s: %w", name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// bind adds a flag to fs for each setting and for the config file, returning the names of the settings flags
+func bind(fs *flag.FlagSet, cfg *Config, file *string) []string {
+	fs.StringVar(file, "config", "", "YAML or JSON config file, overridden by environment variables and flags")
+	before := map[string]bool{}
+	fs.VisitAll(func(f *flag.Flag) {
+		before[f.Name] = true
+	})
+
+	fs.IntVar(&cfg.Server.Port, "port", cfg.Server.Port, "port the HTTP server listens on")
+	fs.StringVar(&cfg.Server.Mode, "mode", cfg.Server.Mode, "gin mode: debug, release or test")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "maximum duration for reading an entire request, 0 for no limit")
+	fs.DurationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", cfg.Server.ReadHeaderTimeout, "maximum duration for reading request headers, 0 to use -read-timeout")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "maximum duration before timing out the writing of a response, 0 for no limit")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "how long keep-alive connections wait for the next request, 0 to use -read-timeout")
+	fs.IntVar(&cfg.Server.MaxHeaderBytes, "max-header-bytes", cfg.Server.MaxHeaderBytes, "maximum size of request headers in bytes")
+	fs.Int64Var(&cfg.Server.MaxBodyBytes, "max-body-bytes", cfg.Server.MaxBodyBytes, "maximum size of request bodies in bytes, 0 for no limit")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "how long requests in progress may take to finish on shutdown")
+
+	fs.StringVar(&cfg.Storage.Backend, "store", cfg.Storage.Backend, "storage backend: memory, file or sql")
+	fs.StringVar(&cfg.Storage.DataDir, "data-dir", cfg.Storage.DataDir, "directory of the file storage backend")
+	fs.StringVar(&cfg.Storage.Fsync, "fsync", cfg.Storage.Fsync, "fsync policy of the file storage backend: always, interval or never")
+	fs.StringVar(&cfg.Storage.DSN, "dsn", cfg.Storage.DSN, "SQLite database of the sql storage backend")
+
+	fs.StringVar(&cfg.Rules.File, "rules", cfg.Rules.File, "YAML or JSON rule set file, the built-in rules are used if empty")
+	fs.StringVar(&cfg.Rules.HistoryDir, "rule-history", cfg.Rules.HistoryDir, "directory of older rule set files available for rescoring")
+
+	fs.DurationVar(&cfg.Receipts.IdempotencyWindow, "idempotency-window", cfg.Receipts.IdempotencyWindow, "how long responses are replayed for a repeated Idempotency-Key, 0 disables it")
+	fs.StringVar(&cfg.Receipts.Duplicates, "duplicates", cfg.Receipts.Duplicates, "handling of receipts submitted twice: allow, reject or return-existing")
+	fs.IntVar(&cfg.Receipts.MaxBatchSize, "max-batch-size", cfg.Receipts.MaxBatchSize, "maximum number of receipts accepted by POST /receipts/process:batch")
+	fs.IntVar(&cfg.Receipts.Workers, "workers", cfg.Receipts.Workers, "number of workers processing receipts submitted with async=true, 0 disables async processing")
+	fs.IntVar(&cfg.Receipts.QueueSize, "queue-size", cfg.Receipts.QueueSize, "number of receipts submitted with async=true that may wait for a worker")
+	fs.DurationVar(&cfg.Receipts.DrainTimeout, "drain-timeout", cfg.Receipts.DrainTimeout, "how long queued receipts may take to process on shutdown")
+
+	fs.Var((*listValue)(&cfg.CORS.AllowedOrigins), "cors-origins", "comma separated origins allowed to send cross-origin requests, * for every origin, empty to disable CORS")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "minimum level of log messages: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "format of log messages: json or text")
+	fs.BoolVar(&cfg.Log.LogReceipts, "log-receipts", cfg.Log.LogReceipts, "log the contents of receipts instead of redacting them")
+	fs.StringVar(&cfg.Tracing.Exporter, "trace-exporter", cfg.Tracing.Exporter, "where spans are exported: none, stdout or otlp")
+	fs.StringVar(&cfg.Tracing.Endpoint, "otlp-endpoint", cfg.Tracing.Endpoint, "host:port of the OTLP HTTP receiver, OTEL_EXPORTER_OTLP_ENDPOINT if empty")
+	fs.BoolVar(&cfg.Tracing.Insecure, "otlp-insecure", cfg.Tracing.Insecure, "send spans to the OTLP receiver over plain HTTP")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "trace-sample-ratio", cfg.Tracing.SampleRatio, "fraction of new traces recorded, from 0 to 1")
+	fs.BoolVar(&cfg.Auth.Enabled, "auth", cfg.Auth.Enabled, "require an API key or bearer JWT and scope receipts to their client")
+	fs.StringVar(&cfg.Auth.JWKSFile, "jwks-file", cfg.Auth.JWKSFile, "JWKS file of the public keys bearer JWTs are signed with, JWTs are refused if empty")
+	fs.StringVar(&cfg.Auth.Issuer, "jwt-issuer", cfg.Auth.Issuer, "issuer required in bearer JWTs, not checked if empty")
+	fs.StringVar(&cfg.Auth.Audience, "jwt-audience", cfg.Auth.Audience, "audience required in bearer JWTs, not checked if empty")
+	fs.BoolVar(&cfg.Features.Docs, "docs", cfg.Features.Docs, "serve the Swagger UI at /docs")
+	fs.BoolVar(&cfg.Features.Webhooks, "webhooks", cfg.Features.Webhooks, "serve /webhooks and deliver receipt events")
+	fs.BoolVar(&cfg.Features.Metrics, "metrics", cfg.Features.Metrics, "serve Prometheus metrics at /metrics")
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !before[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
+
+// readFile overrides the settings present in a .yaml, .yml or .json file. Unknown settings are errors.
+func (c *Config) readFile(path string) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .json", ext)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	// JSON is a subset of YAML, so one decoder reads both
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and returns an error listing each invalid one, or nil if they are all valid
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port %d is not a port from 1 to 65535", c.Server.Port)
+	}
+	if !slices.Contains([]string{"debug", "release", "test"}, c.Server.Mode) {
+		add("server.mode %q is not debug, release or test", c.Server.Mode)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+		{"receipts.idempotencyWindow", c.Receipts.IdempotencyWindow},
+		{"receipts.drainTimeout", c.Receipts.DrainTimeout},
+	} {
+		if d.value < 0 {
+			add("%s %s must not be negative", d.name, d.value)
+		}
+	}
+	if c.Server.MaxHeaderBytes < 1 {
+		add("server.maxHeaderBytes %d must be positive", c.Server.MaxHeaderBytes)
+	}
+	if c.Server.MaxBodyBytes < 0 {
+		add("server.maxBodyBytes %d must not be negative", c.Server.MaxBodyBytes)
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+	case "file":
+		if c.Storage.DataDir == "" {
+			add("storage.dataDir is required by the file backend")
+		}
+		if _, err := repo.ParseSyncPolicy(c.Storage.Fsync); err != nil {
+			add("storage.fsync: %w", err)
+		}
+	case "sql":
+		if c.Storage.DSN == "" {
+			add("storage.dsn is required by the sql backend")
+		}
+	default:
+		add("storage.backend %q is not memory, file or sql", c.Storage.Backend)
+	}
+
+	if _, err := receiptSvc.ParseDuplicateMode(c.Receipts.Duplicates); err != nil {
+		add("receipts.duplicates: %w", err)
+	}
+	if c.Receipts.MaxBatchSize < 1 {
+		add("receipts.maxBatchSize %d must be positive", c.Receipts.MaxBatchSize)
+	}
+	if c.Receipts.Workers < 0 {
+		add("receipts.workers %d must not be negative", c.Receipts.Workers)
+	}
+	if c.Receipts.Workers > 0 && c.Receipts.QueueSize < 1 {
+		add("receipts.queueSize %d must be positive when async processing is enabled", c.Receipts.QueueSize)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			add("cors.allowedOrigins %q is not * or an http or https origin", origin)
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		add("log.level %q is not debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		add("log.format %q is not text or json", c.Log.Format)
+	}
+
+	if !slices.Contains([]string{tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP}, c.Tracing.Exporter) {
+		add("tracing.exporter %q is not none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sampleRatio %g is not from 0 to 1", c.Tracing.SampleRatio)
+	}
+
+	if c.Auth.JWKSFile == "" && (c.Auth.Issuer != "" || c.Auth.Audience != "") {
+		add("auth.issuer and auth.audience require auth.jwksFile")
+	}
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel returns the minimum level of log messages
+func (l Log) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(l.Level))
+	return level
+}
+
+// listValue is a flag holding a comma separated list
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// load runs Load with a fresh flag set and the given environment
+func load(t *testing.T, args []string, env map[string]string) (Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, Default(), cfg)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  mode: release
+  readTimeout: 1m
+storage:
+  backend: sql
+  dsn: file.db
+cors:
+  allowedOrigins: [https://a.example.com, https://b.example.com]
+features:
+  docs: false
+`)
+	env := map[string]string{
+		"RECEIPT_CONFIG":       file,
+		"RECEIPT_PORT":         "9100",
+		"RECEIPT_DSN":          "env.db",
+		"RECEIPT_READ_TIMEOUT": "2m",
+	}
+
+	cfg, err := load(t, []string{"-port", "9200"}, env)
+	require.NoError(t, err)
+	// Flags override the environment, which overrides the file, which overrides the defaults
+	require.Equal(t, 9200, cfg.Server.Port)
+	require.Equal(t, "env.db", cfg.Storage.DSN)
+	require.Equal(t, 2*time.Minute, cfg.Server.ReadTimeout)
+	require.Equal(t, "release", cfg.Server.Mode)
+	require.Equal(t, "sql", cfg.Storage.Backend)
+	require.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORS.AllowedOrigins)
+	require.False(t, cfg.Features.Docs)
+	require.Equal(t, 30*time.Second, cfg.Server.WriteTimeout)
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	file := writeFile(t, "config.json", `{"receipts": {"workers": 0, "duplicates": "reject"}, "log": {"format": "text"}}`)
+
+	cfg, err := load(t, []string{"-config", file, "-cors-origins", ""}, map[string]string{"RECEIPT_CONFIG": "missing.yaml"})
+	require.NoError(t, err)
+	require.Equal(t, 0, cfg.Receipts.Workers)
+	require.Equal(t, "reject", cfg.Receipts.Duplicates)
+	require.Equal(t, "text", cfg.Log.Format)
+	require.Empty(t, cfg.CORS.AllowedOrigins)
+}
+
+func TestLoadKeepsOwnFlags(t *testing.T) {
+	fs := flag.NewFlagSet("rescore", flag.ContinueOnError)
+	version := fs.String("version", "", "rule set version")
+	cfg, err := Load(fs, []string{"-version", "2", "-store", "file"}, func(name string) (string, bool) {
+		// Flags of the command are not read from the environment
+		return "3", name == "RECEIPT_VERSION"
+	})
+	require.NoError(t, err)
+	require.Equal(t, "2", *version)
+	require.Equal(t, "file", cfg.Storage.Backend)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := load(t, nil, map[string]string{"RECEIPT_WORKERS": "many"})
+	require.ErrorContains(t, err, "invalid RECEIPT_WORKERS")
+
+	_, err = load(t, []string{"-config", writeFile(t, "config.yaml", "server:\n  prot: 80\n")}, nil)
+	require.ErrorContains(t, err, "field prot not found")
+
+	_, err = load(t, []string{"-config", writeFile(t, "config.toml", "")}, nil)
+	require.ErrorContains(t, err, "unsupported config file format")
+
+	_, err = load(t, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	require.ErrorContains(t, err, "failed to read config file")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 0
+	cfg.Server.Mode = "prod"
+	cfg.Server.WriteTimeout = -time.Second
+	cfg.Storage.Backend = "file"
+	cfg.Storage.Fsync = "sometimes"
+	cfg.Receipts.Duplicates = "maybe"
+	cfg.CORS.AllowedOrigins = []string{"example.com"}
+	cfg.Log.Level = "loud"
+	cfg.Tracing.Exporter = "jaeger"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Auth.Issuer = "https://issuer.example.com"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		"server.port 0",
+		`server.mode "prod"`,
+		"server.writeTimeout -1s",
+		`storage.fsync: unknown sync policy "sometimes"`,
+		`receipts.duplicates: unknown duplicate mode "maybe"`,
+		`cors.allowedOrigins "example.com"`,
+		`log.level "loud"`,
+		`tracing.exporter "jaeger"`,
+		"tracing.sampleRatio 2",
+		"auth.issuer and auth.audience require auth.jwksFile",
+	} {
+		require.ErrorContains(t, err, want)
+	}
+
+	require.NoError(t, Default().Validate())
+}
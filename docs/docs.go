@@ -15,9 +15,53 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/receipts/process": {
+        "/accounts/{id}": {
+            "get": {
+                "description": "Returns the balance of a loyalty account together with every ledger entry that makes it up, oldest first. The balance is the sum of the entry points.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts"
+                ],
+                "summary": "Retrieves the points balance and history of an account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Account retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/account.ExtGetAccountResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/redemptions": {
             "post": {
-                "description": "Receives a receipt in JSON format and processes it, returning a unique ID for the receipt.",
+                "description": "Debits the points from the account balance. The balance never goes negative, a redemption of more points than the balance fails.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,45 +69,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "receipts"
+                    "accounts"
                 ],
-                "summary": "Submits a receipt for processing and returns an ID",
+                "summary": "Spends points of an account",
                 "parameters": [
                     {
-                        "description": "Receipt data",
-                        "name": "receipt",
+                        "type": "string",
+                        "description": "Account ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Points to redeem",
+                        "name": "redemption",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.ExtReceipt"
+                            "$ref": "#/definitions/account.ExtRedeemRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Receipt processed successfully",
+                    "201": {
+                        "description": "Points redeemed",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ExtProcessReceiptResponse"
+                            "$ref": "#/definitions/account.ExtRedemptionResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body or points not positive",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Insufficient points",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Error processing receipt",
+                        "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/receipts/{id}/points": {
-            "get": {
-                "description": "Fetches the points linked to a receipt using its unique ID.",
+        "/accounts/{id}/redemptions/{redemptionId}/reversal": {
+            "post": {
+                "description": "Refunds the points of a redemption to its account. A redemption can be reversed once.",
                 "consumes": [
                     "application/json"
                 ],
@@ -71,107 +134,2403 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "receipts"
+                    "accounts"
                 ],
-                "summary": "Retrieves points associated with a receipt by ID",
+                "summary": "Reverses a redemption",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Receipt ID",
+                        "description": "Account ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Redemption ID",
+                        "name": "redemptionId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Points retrieved successfully",
+                        "description": "Redemption reversed",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ExtGetPointsResponse"
+                            "$ref": "#/definitions/account.ExtRedemptionResponse"
                         }
                     },
                     "404": {
-                        "description": "Receipt not found",
+                        "description": "Account or redemption not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Redemption already reversed",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/receipt.ErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.ExtReceipt": {
-            "type": "object",
-            "required": [
-                "items",
-                "purchaseDate",
-                "purchaseTime",
-                "retailer",
-                "total"
-            ],
-            "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Item"
+        },
+        "/admin/api-keys": {
+            "get": {
+                "description": "Returns every API key without the key itself, revoked ones included, oldest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Lists API keys",
+                "responses": {
+                    "200": {
+                        "description": "API keys retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/apikey.ExtListAPIKeysResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
-                },
-                "purchaseDate": {
-                    "type": "string"
-                },
-                "purchaseTime": {
-                    "type": "string"
-                },
-                "retailer": {
-                    "type": "string"
-                },
-                "total": {
-                    "type": "string"
                 }
-            }
-        },
-        "models.Item": {
-            "type": "object",
-            "required": [
-                "price",
-                "shortDescription"
-            ],
-            "properties": {
-                "price": {
-                    "type": "string"
-                },
-                "shortDescription": {
-                    "type": "string"
+            },
+            "post": {
+                "description": "Generates a key authenticating the client in the X-API-Key header. Receipts submitted with it are owned by the client, and only admin keys see the receipts of other clients. Only a hash of the key is stored, the key is returned here only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Creates an API key for a client",
+                "parameters": [
+                    {
+                        "description": "Client, name and admin flag of the key",
+                        "name": "apiKey",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apikey.ExtCreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "API key created, with the key",
+                        "schema": {
+                            "$ref": "#/definitions/apikey.ExtCreateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid API key, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "receipt.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "description": "Stops accepting the key. The key stays listed with the time it was revoked, and revoking it again changes nothing.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Revokes an API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "API key revoked",
+                        "schema": {
+                            "$ref": "#/definitions/apikey.ExtAPIKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "receipt.ExtGetPointsResponse": {
-            "type": "object",
-            "properties": {
-                "points": {
-                    "type": "integer"
+        "/admin/receipts/rescore": {
+            "post": {
+                "description": "Scores every receipt matching the filters with the given rule set version, the current one by default, and reports the change of points of each receipt. In dry-run mode nothing is changed. In apply mode receipts are updated and the difference is credited to or debited from their account; receipts whose account already spent the points they would lose are reported as failed and left unchanged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rescores stored receipts with a rule set version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule set version to score with, the current rule set if empty",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "dry-run",
+                        "description": "dry-run or apply",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts from this retailer, ignoring case",
+                        "name": "retailer",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or after this yyyy-mm-dd date",
+                        "name": "purchaseDateFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or before this yyyy-mm-dd date",
+                        "name": "purchaseDateTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only receipts awarded at least this many points",
+                        "name": "minPoints",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only receipts awarded at most this many points",
+                        "name": "maxPoints",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts scored with this rule set version",
+                        "name": "ruleVersion",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts matched to this canonical retailer, receipts that matched none if empty",
+                        "name": "retailerId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receipts rescored, see each result",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtRescoreResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters or unknown rule set version",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "receipt.ExtProcessReceiptResponse": {
-            "type": "object",
-            "properties": {
-                "id": {
+        "/campaigns": {
+            "get": {
+                "description": "Returns every campaign, past, running and upcoming, ordered by start date.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Lists promotional campaigns",
+                "responses": {
+                    "200": {
+                        "description": "Campaigns retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.ExtListCampaignsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Receipts purchased within the date window of a campaign that match its retailer and item matchers are awarded extra points on top of the base rules: their base points are multiplied by the multiplier and the bonus is added. Either may be omitted, not both.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Creates a promotional campaign",
+                "parameters": [
+                    {
+                        "description": "Campaign, the id is ignored",
+                        "name": "campaign",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Campaign"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Campaign created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Campaign"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid campaign, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/campaigns/{id}": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Retrieves a promotional campaign by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Campaign retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Campaign"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces every field of a campaign. Receipts already awarded keep the points of the terms they were awarded under.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Replaces a promotional campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Campaign, the id is ignored",
+                        "name": "campaign",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Campaign"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Campaign updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Campaign"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid campaign, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops awarding the campaign. Receipts already awarded keep its points.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Deletes a promotional campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Campaign deleted"
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Liveness probe. Succeeds as long as the server handles requests, without checking its dependencies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Reports that the process is alive",
+                "responses": {
+                    "200": {
+                        "description": "Server is alive",
+                        "schema": {
+                            "$ref": "#/definitions/health.ExtHealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Readiness probe. Checks that the store is reachable and the rule set is loaded, and reports the result of each check.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Reports whether the server can serve requests",
+                "responses": {
+                    "200": {
+                        "description": "Every check passed",
+                        "schema": {
+                            "$ref": "#/definitions/health.ExtReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A check failed",
+                        "schema": {
+                            "$ref": "#/definitions/health.ExtReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts": {
+            "get": {
+                "description": "Returns stored receipts ordered by ID, one page at a time. Pass the nextCursor of a response as cursor to get the following page, it is omitted on the last page.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Lists stored receipts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page to return",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Number of receipts per page, 1 to 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts from this retailer, ignoring case",
+                        "name": "retailer",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or after this yyyy-mm-dd date",
+                        "name": "purchaseDateFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts purchased on or before this yyyy-mm-dd date",
+                        "name": "purchaseDateTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only receipts awarded at least this many points",
+                        "name": "minPoints",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only receipts awarded at most this many points",
+                        "name": "maxPoints",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts scored with this rule set version",
+                        "name": "ruleVersion",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only receipts matched to this canonical retailer, receipts that matched none if empty",
+                        "name": "retailerId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receipts retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtListReceiptsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters, details lists every invalid parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/process": {
+            "post": {
+                "description": "Receives a receipt in JSON format and processes it, returning a unique ID for the receipt. With async=true the receipt is queued and processed in the background: the response is 202 with the ID and the pending status, and GET /receipts/{id} reports when it is processed or failed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Submits a receipt for processing and returns an ID",
+                "parameters": [
+                    {
+                        "description": "Receipt data",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ExtReceipt"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Queue the receipt instead of processing it before responding",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retrying with the same key returns the original response instead of processing the receipt again",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receipt processed successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtProcessReceiptResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Receipt queued for processing",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtProcessReceiptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "accountId is the account of another client",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Receipt was already submitted, or is still being processed in return-existing mode, or a request with the same Idempotency-Key is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtDuplicateReceiptResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body is larger than allowed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key was already used with a different receipt",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error processing receipt",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Processing queue is full or shutting down, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/process:batch": {
+            "post": {
+                "description": "Validates, scores and stores every receipt independently. Invalid or malformed receipts do not prevent the others from being processed, each result reports either the ID and points or the error of its receipt.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Submits several receipts for processing at once",
+                "parameters": [
+                    {
+                        "description": "Receipts",
+                        "name": "receipts",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ExtReceipt"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retrying with the same key returns the original response instead of processing the batch again",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Batch processed, see each result",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtBatchProcessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Request body is not an array of receipts",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Batch has more receipts than allowed, or the request body is larger than allowed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/score": {
+            "post": {
+                "description": "Validates a receipt and scores it with the current rules and running campaigns, returning the points and the rule by rule breakdown. Nothing is stored, no ID is assigned and no account is credited.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Previews the points of a receipt without storing it",
+                "parameters": [
+                    {
+                        "description": "Receipt data",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ExtReceipt"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receipt scored successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtScoreReceiptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body is larger than allowed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error scoring receipt",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}": {
+            "get": {
+                "description": "Returns the receipt as it was submitted together with the points it was awarded and the processed status. Receipts submitted with async=true that are still pending, or failed to process, are reported as an ExtReceiptStatusResponse with only their id, status and error.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Retrieves a stored receipt by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Receipt ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receipt retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtReceiptResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Receipt not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the receipt and reverses the points credited to its account. Its contents no longer count as a duplicate submission.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Deletes a stored receipt by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Receipt ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Receipt deleted"
+                    },
+                    "404": {
+                        "description": "Receipt not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The points of the receipt were already redeemed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/points": {
+            "get": {
+                "description": "Fetches the points linked to a receipt using its unique ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Retrieves points associated with a receipt by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Receipt ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Points retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtGetPointsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Receipt not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{id}/points/breakdown": {
+            "get": {
+                "description": "Lists every scoring rule with whether it matched, the receipt fields it looked at and the points it awarded. The points add up to the receipt's total, unless the rule set that awarded them is no longer known: the current rules are explained instead and recomputed is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "receipts"
+                ],
+                "summary": "Explains the points awarded to a receipt rule by rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Receipt ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Points breakdown retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/receipt.ExtGetPointsBreakdownResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Receipt not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/retailers": {
+            "get": {
+                "description": "Returns every registered retailer with its aliases, ordered by ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Lists canonical retailers",
+                "responses": {
+                    "200": {
+                        "description": "Retailers retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/retailer.ExtListRetailersResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Registers a retailer with an ID derived from its name, like target for \"Target\". Receipts are matched to it when their retailer name, ignoring case, punctuation, store numbers and small typos, is its name or one of its aliases. Stored receipts that matched no retailer and match the new one are assigned to it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Registers a canonical retailer",
+                "parameters": [
+                    {
+                        "description": "Retailer name and aliases",
+                        "name": "retailer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/retailer.ExtRegisterRetailerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Retailer registered",
+                        "schema": {
+                            "$ref": "#/definitions/models.Retailer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name or alias",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Retailer already registered, or a name already belongs to another retailer",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/retailers/unmatched": {
+            "get": {
+                "description": "Returns the retailer names of stored receipts that matched no registered retailer with their number of receipts, most frequent first. Register them or add them as aliases to group their receipts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Lists retailer names that matched no retailer",
+                "responses": {
+                    "200": {
+                        "description": "Unmatched names retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/retailer.ExtListUnmatchedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/retailers/{id}": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Retrieves a canonical retailer by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Retailer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retailer retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Retailer"
+                        }
+                    },
+                    "404": {
+                        "description": "Retailer not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/retailers/{id}/aliases": {
+            "post": {
+                "description": "Adds an alternative name receipts are matched to the retailer by. Stored receipts that matched no retailer and match the alias are assigned to it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Adds an alias to a retailer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Retailer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Alias to add",
+                        "name": "alias",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/retailer.ExtAddAliasRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Alias added",
+                        "schema": {
+                            "$ref": "#/definitions/models.Retailer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid alias",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Retailer not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Alias already belongs to another retailer",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/retailers/{id}/aliases/{alias}": {
+            "delete": {
+                "description": "Stops matching receipts to the retailer by the alias. Receipts already matched through it keep their retailer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retailers"
+                ],
+                "summary": "Removes an alias from a retailer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Retailer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Alias to remove",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Alias removed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Retailer"
+                        }
+                    },
+                    "404": {
+                        "description": "Retailer or alias not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Returns the version and commit the server was built from, and the version of the rule set it scores receipts with.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Retrieves the build version",
+                "responses": {
+                    "200": {
+                        "description": "Build information",
+                        "schema": {
+                            "$ref": "#/definitions/health.ExtVersionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "Returns every webhook without its secret, oldest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Lists webhooks",
+                "responses": {
+                    "200": {
+                        "description": "Webhooks retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ExtListWebhooksResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Posts a JSON event to the URL whenever a receipt is processed (receipt.processed), deleted (receipt.deleted) or rescored (receipt.rescored), limited to the given event types if any. Each request is signed: the X-Receipt-Signature header is \"sha256=\" followed by the hex HMAC-SHA256, keyed with the secret, of the X-Receipt-Timestamp header, a dot and the body. Failed requests are retried with exponential backoff, then kept as dead letters. The secret is only returned here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Subscribes a webhook to receipt events",
+                "parameters": [
+                    {
+                        "description": "URL, event types and optional secret",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ExtCreateWebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Webhook created, with its secret",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid webhook, details lists every invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters": {
+            "get": {
+                "description": "Returns the latest deliveries to any webhook that failed every attempt, newest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Lists dead letters",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Number of deliveries, 1 to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dead letters retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ExtListDeliveriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters/{id}/redeliver": {
+            "post": {
+                "description": "Attempts the delivery again with a fresh round of retries, keeping its earlier attempts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Redelivers a dead letter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Delivery pending",
+                        "schema": {
+                            "$ref": "#/definitions/models.Delivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery is not a dead letter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "description": "Returns the webhook without its secret.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Retrieves a webhook by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ExtWebhookResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops delivering events to the webhook and deletes its delivery history.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Deletes a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Webhook deleted"
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Returns the latest deliveries to the webhook, newest first, with the response status or error of every attempt.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Lists the deliveries to a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Number of deliveries, 1 to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deliveries retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ExtListDeliveriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "account.ExtGetAccountResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/account.ExtLedgerEntry"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "account.ExtLedgerEntry": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "receiptId": {
+                    "type": "string"
+                },
+                "redemptionId": {
+                    "type": "string"
+                },
+                "transactionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "account.ExtRedeemRequest": {
+            "type": "object",
+            "properties": {
+                "points": {
+                    "type": "integer"
+                }
+            }
+        },
+        "account.ExtRedemptionResponse": {
+            "type": "object",
+            "properties": {
+                "accountId": {
+                    "type": "string"
+                },
+                "balance": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "reversed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "apikey.ExtAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "clientId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.ExtCreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "clientId"
+            ],
+            "properties": {
+                "admin": {
+                    "description": "Admin keys may call the admin endpoints and see the receipts of every client",
+                    "type": "boolean"
+                },
+                "clientId": {
+                    "description": "ClientID is the client authenticated by the key, it owns the receipts submitted with it",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.ExtCreateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "clientId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.ExtListAPIKeysResponse": {
+            "type": "object",
+            "properties": {
+                "apiKeys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apikey.ExtAPIKeyResponse"
+                    }
+                }
+            }
+        },
+        "campaign.ExtListCampaignsResponse": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Campaign"
+                    }
+                }
+            }
+        },
+        "health.ExtHealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "health.ExtReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Checks maps each dependency checked to ok or unavailable, the reason a check failed is only logged",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "description": "Status is ready, or unavailable if a check failed",
+                    "type": "string",
+                    "example": "ready"
+                }
+            }
+        },
+        "health.ExtVersionResponse": {
+            "type": "object",
+            "properties": {
+                "commit": {
+                    "type": "string",
+                    "example": "c2e50e1"
+                },
+                "goVersion": {
+                    "type": "string",
+                    "example": "go1.22.5"
+                },
+                "ruleVersion": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.4.0"
+                }
+            }
+        },
+        "models.AppliedCampaign": {
+            "type": "object",
+            "properties": {
+                "bonus": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "multiplier": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "points": {
+                    "description": "Points is what the campaign added to the base points",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Campaign": {
+            "type": "object",
+            "properties": {
+                "bonus": {
+                    "description": "Bonus is a flat number of points added to matching receipts",
+                    "type": "integer"
+                },
+                "endDate": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "itemContains": {
+                    "description": "ItemContains matches receipts with an item whose description contains it ignoring case, any receipt if empty",
+                    "type": "string"
+                },
+                "multiplier": {
+                    "description": "Multiplier scales the base points, 2 doubles them; zero leaves them unchanged",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "retailer": {
+                    "description": "Retailer matches receipts from this retailer ignoring case, any retailer if empty",
+                    "type": "string"
+                },
+                "startDate": {
+                    "description": "StartDate and EndDate are the inclusive yyyy-mm-dd window of purchase dates",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DeliveryAttempt"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "event": {
+                    "$ref": "#/definitions/models.ReceiptEvent"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "webhookId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeliveryAttempt": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "statusCode": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "description": "RequestID identifies the request in the server log",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ExtItem": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "type": "string"
+                },
+                "shortDescription": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ExtReceipt": {
+            "type": "object",
+            "properties": {
+                "accountId": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ExtItem"
+                    }
+                },
+                "purchaseDate": {
+                    "type": "string"
+                },
+                "purchaseTime": {
+                    "type": "string"
+                },
+                "retailer": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReceiptEvent": {
+            "type": "object",
+            "properties": {
+                "accountId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "previousPoints": {
+                    "description": "PreviousPoints is the points of a rescored receipt before it was rescored",
+                    "type": "integer"
+                },
+                "receiptId": {
+                    "type": "string"
+                },
+                "ruleVersion": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Retailer": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "ID is derived from the name when the retailer is registered",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RuleResult": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "inputs": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "matched": {
+                    "type": "boolean"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UnmatchedRetailer": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "receipts": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Events are the event types delivered, every type if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Secret is the HMAC-SHA256 key of the signature of each delivery",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtBatchProcessResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/receipt.ExtBatchResult"
+                    }
+                }
+            }
+        },
+        "receipt.ExtBatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/models.ErrorResponse"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "points": {
+                    "description": "set for every processed receipt, even when it is worth 0 points",
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtDuplicateReceiptResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "description": "RequestID identifies the request in the server log",
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtGetPointsBreakdownResponse": {
+            "type": "object",
+            "properties": {
+                "points": {
+                    "type": "integer"
+                },
+                "recomputed": {
+                    "description": "Recomputed is true when the rule set that awarded the points is no longer known, the rules and\npoints are then those of the current rule set and may differ from GET /receipts/{id}/points",
+                    "type": "boolean"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RuleResult"
+                    }
+                }
+            }
+        },
+        "receipt.ExtGetPointsResponse": {
+            "type": "object",
+            "properties": {
+                "points": {
+                    "type": "integer"
+                }
+            }
+        },
+        "receipt.ExtListReceiptsResponse": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "receipts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/receipt.ExtReceiptResponse"
+                    }
+                }
+            }
+        },
+        "receipt.ExtProcessReceiptResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is set for receipts submitted with async=true",
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtReceiptResponse": {
+            "type": "object",
+            "properties": {
+                "accountId": {
+                    "type": "string"
+                },
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AppliedCampaign"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ExtItem"
+                    }
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "purchaseDate": {
+                    "type": "string"
+                },
+                "purchaseTime": {
+                    "type": "string"
+                },
+                "retailer": {
+                    "type": "string"
+                },
+                "retailerId": {
+                    "type": "string"
+                },
+                "ruleVersion": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtRescoreResponse": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/receipt.ExtRescoreResult"
+                    }
+                },
+                "totalDelta": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtRescoreResult": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "newPoints": {
+                    "type": "integer"
+                },
+                "oldPoints": {
+                    "type": "integer"
+                },
+                "oldVersion": {
+                    "type": "string"
+                },
+                "receiptId": {
+                    "type": "string"
+                }
+            }
+        },
+        "receipt.ExtScoreReceiptResponse": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AppliedCampaign"
+                    }
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "ruleVersion": {
+                    "type": "string"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RuleResult"
+                    }
+                }
+            }
+        },
+        "retailer.ExtAddAliasRequest": {
+            "type": "object",
+            "required": [
+                "alias"
+            ],
+            "properties": {
+                "alias": {
+                    "type": "string"
+                }
+            }
+        },
+        "retailer.ExtListRetailersResponse": {
+            "type": "object",
+            "properties": {
+                "retailers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Retailer"
+                    }
+                }
+            }
+        },
+        "retailer.ExtListUnmatchedResponse": {
+            "type": "object",
+            "properties": {
+                "retailers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UnmatchedRetailer"
+                    }
+                }
+            }
+        },
+        "retailer.ExtRegisterRetailerRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.ExtCreateWebhookRequest": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "events": {
+                    "description": "Events are the event types delivered, every type if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "description": "Secret signs the deliveries, a random one is generated if empty",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.ExtListDeliveriesResponse": {
+            "type": "object",
+            "properties": {
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Delivery"
+                    }
+                }
+            }
+        },
+        "webhook.ExtListWebhooksResponse": {
+            "type": "object",
+            "properties": {
+                "webhooks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webhook.ExtWebhookResponse"
+                    }
+                }
+            }
+        },
+        "webhook.ExtWebhookResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "url": {
                     "type": "string"
                 }
             }
@@ -0,0 +1,141 @@
+// Package logging sets up structured logging and carries the logger of each request,
+// tagged with its request ID, in its context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"receipt-processor/models"
+	"regexp"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID of a request, given by the client or generated, and is echoed in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the key of the request ID in the gin context
+const requestIDKey = "requestId"
+
+// validRequestID matches the request IDs accepted from clients, others are replaced by a generated one
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Options configures the application log
+type Options struct {
+	Level slog.Level
+	// JSON writes one JSON object per line instead of key=value text
+	JSON bool
+	// LogReceipts logs the contents of receipts, which are redacted otherwise
+	LogReceipts bool
+}
+
+// logReceipts is set by Setup, receipt contents are redacted until told otherwise
+var logReceipts atomic.Bool
+
+// Setup makes the default logger write to w with the given options. The standard
+// logger only reports failures, so its messages are logged as errors.
+func Setup(w io.Writer, opts Options) {
+	handlerOptions := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler = slog.NewTextHandler(w, handlerOptions)
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	}
+	slog.SetDefault(slog.New(handler))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	logReceipts.Store(opts.LogReceipts)
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware gives every request an ID and a logger tagged with it, and with the ID of its trace if it is
+// traced, then logs the request once it is handled, with the errors recorded by its handlers.
+// The ID is taken from the X-Request-ID header if it is a valid one, generated otherwise, and set on the response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		logger := slog.Default().With("request_id", id)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		// Handlers record the causes of unexpected failures with c.Error
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+		// Later middleware may have added attributes to the logger, like the authenticated client
+		FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery responds 500 to requests whose handler panicked and logs the panic with the request ID
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		FromContext(c.Request.Context()).Error("panic while handling request",
+			"panic", recovered, "stack", string(debug.Stack()))
+		c.Abort()
+		WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	})
+}
+
+// WriteError responds with an error tagged with the ID of the request
+func WriteError(c *gin.Context, status int, resp models.ErrorResponse) {
+	resp.RequestID = RequestID(c)
+	c.JSON(status, resp)
+}
+
+// RequestID returns the ID given to a request by Middleware
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Receipt describes a receipt for the log. Its contents are redacted, keeping only the number
+// of items, unless Setup was told to log receipts.
+func Receipt(key string, receipt models.ExtReceipt) slog.Attr {
+	if logReceipts.Load() {
+		return slog.Any(key, receipt)
+	}
+	return slog.Group(key, slog.Int("items", len(receipt.Items)), slog.String("contents", "redacted"))
+}
@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setup logs JSON to a buffer for the duration of the test
+func setup(t *testing.T, opts Options) *bytes.Buffer {
+	previous := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		logReceipts.Store(false)
+	})
+	var buf bytes.Buffer
+	opts.JSON = true
+	Setup(&buf, opts)
+	return &buf
+}
+
+// lines decodes the JSON log lines written to buf
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func newRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(Middleware(), Recovery())
+	router.GET("/receipts/:id", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("looking up receipt")
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+	router.GET("/failing", func(c *gin.Context) {
+		c.Error(errors.New("store unavailable"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	})
+	router.GET("/panicking", func(c *gin.Context) {
+		panic("unexpected")
+	})
+	return router
+}
+
+func TestMiddleware(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelInfo})
+
+	req := httptest.NewRequest("GET", "/receipts/a", nil)
+	req.Header.Set(RequestIDHeader, "client-id-1")
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+
+	require.Equal(t, "client-id-1", w.Header().Get(RequestIDHeader))
+	records := lines(t, buf)
+	require.Len(t, records, 2)
+	// Messages logged by the handler carry the request ID
+	require.Equal(t, "looking up receipt", records[0]["msg"])
+	require.Equal(t, "client-id-1", records[0]["request_id"])
+	require.Equal(t, "request", records[1]["msg"])
+	require.Equal(t, "client-id-1", records[1]["request_id"])
+	require.Equal(t, "/receipts/:id", records[1]["route"])
+	require.Equal(t, "/receipts/a", records[1]["path"])
+	require.Equal(t, float64(http.StatusOK), records[1]["status"])
+}
+
+func TestMiddlewareTraceID(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelInfo})
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	router := gin.New()
+	// Stands in for the tracing middleware
+	router.Use(func(c *gin.Context) {
+		span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+		c.Request = c.Request.WithContext(trace.ContextWithSpanContext(c.Request.Context(), span))
+	}, Middleware())
+	router.GET("/", func(c *gin.Context) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", lines(t, buf)[0]["trace_id"])
+}
+
+func TestMiddlewareKeepsLaterAttributes(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelInfo})
+
+	router := gin.New()
+	// Stands in for the authentication middleware
+	router.Use(Middleware(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.Request = c.Request.WithContext(NewContext(ctx, FromContext(ctx).With("client_id", "acme")))
+	})
+	router.GET("/", func(c *gin.Context) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	records := lines(t, buf)
+	require.Equal(t, "request", records[0]["msg"])
+	require.Equal(t, "acme", records[0]["client_id"])
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	setup(t, Options{Level: slog.LevelInfo})
+
+	for _, given := range []string{"", "not a valid id", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest("GET", "/receipts/a", nil)
+		req.Header.Set(RequestIDHeader, given)
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		require.NotEmpty(t, id)
+		require.NotEqual(t, given, id)
+	}
+}
+
+func TestMiddlewareLogsErrors(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelInfo})
+
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/failing", nil))
+
+	records := lines(t, buf)
+	require.Len(t, records, 1)
+	require.Equal(t, "ERROR", records[0]["level"])
+	require.Equal(t, []any{"store unavailable"}, records[0]["errors"])
+}
+
+func TestRecovery(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelInfo})
+
+	req := httptest.NewRequest("GET", "/panicking", nil)
+	req.Header.Set(RequestIDHeader, "client-id-2")
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.JSONEq(t, `{"error": "Internal server error", "requestId": "client-id-2"}`, w.Body.String())
+	records := lines(t, buf)
+	require.Len(t, records, 2)
+	require.Equal(t, "panic while handling request", records[0]["msg"])
+	require.Equal(t, "unexpected", records[0]["panic"])
+	require.Equal(t, "client-id-2", records[0]["request_id"])
+}
+
+func TestLevel(t *testing.T) {
+	buf := setup(t, Options{Level: slog.LevelWarn})
+
+	slog.Info("hidden")
+	slog.Warn("shown")
+	records := lines(t, buf)
+	require.Len(t, records, 1)
+	require.Equal(t, "shown", records[0]["msg"])
+}
+
+func TestReceipt(t *testing.T) {
+	receipt := models.ExtReceipt{
+		Retailer: "Target",
+		Total:    "6.49",
+		Items:    []models.ExtItem{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+	}
+
+	buf := setup(t, Options{Level: slog.LevelInfo})
+	slog.Info("receipt", Receipt("receipt", receipt))
+	require.Equal(t, map[string]any{"items": float64(1), "contents": "redacted"}, lines(t, buf)[0]["receipt"])
+	require.NotContains(t, buf.String(), "Target")
+
+	buf = setup(t, Options{Level: slog.LevelInfo, LogReceipts: true})
+	slog.Info("receipt", Receipt("receipt", receipt))
+	require.Contains(t, buf.String(), `"retailer":"Target"`)
+	require.Contains(t, buf.String(), "Mountain Dew 12PK")
+}
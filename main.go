@@ -4,10 +4,36 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"receipt-processor/config"
 	_ "receipt-processor/docs"
+	"receipt-processor/metrics"
+	"receipt-processor/models"
+	account_handler "receipt-processor/public/v1/account"
+	apikey_handler "receipt-processor/public/v1/apikey"
+	campaign_handler "receipt-processor/public/v1/campaign"
+	health_handler "receipt-processor/public/v1/health"
 	receipt_handler "receipt-processor/public/v1/receipt"
+	retailer_handler "receipt-processor/public/v1/retailer"
+	webhook_handler "receipt-processor/public/v1/webhook"
+	"receipt-processor/repo"
+	accountSvc "receipt-processor/services/account"
+	apikeySvc "receipt-processor/services/apikey"
+	campaignSvc "receipt-processor/services/campaign"
 	receiptSvc "receipt-processor/services/receipt"
+	retailerSvc "receipt-processor/services/retailer"
+	"receipt-processor/services/rules"
+	webhookSvc "receipt-processor/services/webhook"
+	"receipt-processor/tracing"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,19 +44,254 @@ import (
 
 // @host localhost:8080/
 func main() {
-	// Create a Gin router
-	router := gin.Default()
+	if len(os.Args) > 1 && os.Args[1] == "rescore" {
+		rescore(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		createAPIKey(os.Args[2:])
+		return
+	}
 
-	// Create an instance of the ReceiptService
-	receiptService := receiptSvc.NewReceiptService()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	setupLogging(cfg.Log)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
 
-	// Set up routes
-	receipt_handler.Register(router, receiptService)
+	// Create the storage, the metrics and a Gin router
+	store, ruleSet, options := openServices(cfg)
+	var m *metrics.Metrics
+	if cfg.Features.Metrics {
+		m = metrics.New(store)
+		options = append(options, receiptSvc.WithObserver(m))
+	}
+	router := newRouter(cfg, m)
+
+	// Create instances of the ReceiptService, AccountService, CampaignService, RetailerService and WebhookService
+	duplicateMode, err := receiptSvc.ParseDuplicateMode(cfg.Receipts.Duplicates)
+	if err != nil {
+		fatal("Invalid receipts.duplicates", "error", err)
+	}
+	retailerService := retailerSvc.NewRetailerService(store, store)
+	options = append(options,
+		receiptSvc.WithDuplicateDetection(duplicateMode),
+		receiptSvc.WithRetailerMatcher(retailerService))
+	var webhookService webhookSvc.WebhookService
+	if cfg.Features.Webhooks {
+		webhookService = webhookSvc.NewWebhookService(store)
+		if err := webhookService.ResumePending(); err != nil {
+			fatal("Failed to resume webhook deliveries", "error", err)
+		}
+		options = append(options, receiptSvc.WithEventPublisher(webhookService))
+	}
+	if cfg.Receipts.Workers > 0 {
+		options = append(options, receiptSvc.WithAsyncProcessing(cfg.Receipts.Workers, cfg.Receipts.QueueSize))
+	}
+	receiptService := receiptSvc.NewReceiptService(store, options...)
+	accountService := accountSvc.NewAccountService(store)
+	campaignService := campaignSvc.NewCampaignService(store)
+
+	// Set up routes. The health checks are always public, the other routes require credentials when authentication is enabled.
+	var api gin.IRouter = router
+	var apiKeyService apikeySvc.APIKeyService
+	if cfg.Auth.Enabled {
+		apiKeyService = apikeySvc.NewAPIKeyService(store)
+		authMiddleware, err := newAuthMiddleware(cfg.Auth, apiKeyService)
+		if err != nil {
+			fatal("Failed to set up authentication", "error", err)
+		}
+		api = router.Group("", authMiddleware)
+	}
+	health_handler.Register(router,
+		health_handler.BuildInfo{Version: version, Commit: buildCommit(), RuleVersion: ruleSet.Version},
+		health_handler.Check{Name: "store", Check: func(ctx context.Context) error { return pingStore(ctx, store) }},
+		health_handler.Check{Name: "rules", Check: func(context.Context) error { return checkRuleSet(ruleSet) }})
+	receiptOptions := []receipt_handler.Option{
+		receipt_handler.WithIdempotencyWindow(cfg.Receipts.IdempotencyWindow),
+		receipt_handler.WithMaxBatchSize(cfg.Receipts.MaxBatchSize),
+	}
+	if m != nil {
+		receiptOptions = append(receiptOptions, receipt_handler.WithValidationObserver(m))
+	}
+	receipt_handler.Register(api, receiptService, receiptOptions...)
+	account_handler.Register(api, accountService)
+	campaign_handler.Register(api, campaignService)
+	retailer_handler.Register(api, retailerService)
+	if webhookService != nil {
+		webhook_handler.Register(api, webhookService)
+	}
+	if apiKeyService != nil {
+		apikey_handler.Register(api, apiKeyService)
+	}
 
 	// Start the server
-	port := ":8080"
-	fmt.Printf("Server is running on port %s...\n", port)
-	if err := router.Run(port); err != nil {
-		panic(err)
+	srv := newServer(cfg.Server, router)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		slog.Info("Server is running", "port", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed", "error", err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM, finish the requests in progress, then process the queued receipts
+	// and finish the webhook requests in progress before flushing the store and the spans
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down, finishing requests in progress")
+	err = shutdown(srv, cfg.Server.ShutdownTimeout,
+		func() error {
+			drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Receipts.DrainTimeout)
+			defer cancel()
+			if err := receiptService.Drain(drainCtx); err != nil {
+				return fmt.Errorf("queued receipts were not processed: %w", err)
+			}
+			// Deliveries waiting for a retry are resumed on the next start
+			if webhookService != nil {
+				if err := webhookService.Close(drainCtx); err != nil {
+					return fmt.Errorf("webhook deliveries were not finished: %w", err)
+				}
+			}
+			return nil
+		},
+		func() error { return closeStore(store) },
+		func() error {
+			flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(flushCtx); err != nil {
+				return fmt.Errorf("spans were not exported: %w", err)
+			}
+			return nil
+		})
+	if err != nil {
+		fatal("Shutdown was not clean", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// rescore runs the rescore command, which reports or applies the points of stored receipts under a rule set version.
+// The server should be stopped while it runs with the file backend, whose files are not shared between processes.
+func rescore(args []string) {
+	fs := flag.NewFlagSet("rescore", flag.ExitOnError)
+	version := fs.String("version", "", "rule set version to score with, the current rule set if empty")
+	apply := fs.Bool("apply", false, "store the new points and adjust account balances, otherwise only report them")
+	retailer := fs.String("retailer", "", "only receipts from this retailer")
+	ruleVersion := fs.String("rule-version", "", "only receipts scored with this rule set version")
+	cfg, err := config.Load(fs, args, os.LookupEnv)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	setupLogging(cfg.Log)
+
+	store, _, options := openServices(cfg)
+	service := receiptSvc.NewReceiptService(store, options...)
+	filter := repo.ListQuery{Retailer: *retailer}
+	if *ruleVersion != "" {
+		filter.RuleVersion = ruleVersion
+	}
+	report, err := service.Rescore(context.Background(), receiptSvc.RescoreQuery{Version: *version, Filter: filter, Apply: *apply})
+	if err != nil {
+		fatal("Failed to rescore", "error", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RECEIPT\tVERSION\tOLD\tNEW\tDELTA\tERROR")
+	for _, result := range report.Results {
+		errText := ""
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%+d\t%s\n", result.ReceiptID, result.OldVersion, result.OldPoints, result.NewPoints, result.Delta, errText)
+	}
+	w.Flush()
+	mode := "dry run"
+	if report.Applied {
+		mode = "applied"
+	}
+	fmt.Printf("%s with rule set %q: %d changed, %d failed, total delta %+d\n", mode, report.Version, report.Changed, report.Failed, report.TotalDelta)
+	if err := closeStore(store); err != nil {
+		fatal("Failed to close store", "error", err)
+	}
+}
+
+// createAPIKey runs the apikey command, which creates an API key and prints it, for example the first admin key.
+// The server should be stopped while it runs with the file backend, whose files are not shared between processes.
+func createAPIKey(args []string) {
+	fs := flag.NewFlagSet("apikey", flag.ExitOnError)
+	client := fs.String("client", "", "client authenticated by the key, which owns the receipts submitted with it")
+	name := fs.String("name", "", "name of the key")
+	admin := fs.Bool("admin", false, "let the key call the admin endpoints and see the receipts of every client")
+	cfg, err := config.Load(fs, args, os.LookupEnv)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	setupLogging(cfg.Log)
+	if cfg.Storage.Backend == "memory" {
+		fatal("API keys cannot be created in the memory backend, which is lost when the command exits", "backend", cfg.Storage.Backend)
+	}
+
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		fatal("Failed to open store", "backend", cfg.Storage.Backend, "error", err)
+	}
+	created, key, err := apikeySvc.NewAPIKeyService(store).CreateAPIKey(models.APIKey{ClientID: *client, Name: *name, Admin: *admin})
+	if err != nil {
+		fatal("Failed to create API key", "error", err)
+	}
+	fmt.Printf("created api key %s for client %q, it is not shown again:\n%s\n", created.ID, created.ClientID, key)
+	if err := closeStore(store); err != nil {
+		fatal("Failed to close store", "error", err)
+	}
+}
+
+// openServices opens the storage and loads the rule sets of the configuration,
+// returning the current rule set with the options configuring the receipt service to use them
+func openServices(cfg config.Config) (repo.Store, *rules.RuleSet, []receiptSvc.Option) {
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		fatal("Failed to open store", "backend", cfg.Storage.Backend, "error", err)
+	}
+	ruleSet := rules.Default()
+	if cfg.Rules.File != "" {
+		if ruleSet, err = rules.Load(cfg.Rules.File); err != nil {
+			fatal("Failed to load rules", "file", cfg.Rules.File, "error", err)
+		}
+	}
+	options := []receiptSvc.Option{receiptSvc.WithRuleSet(ruleSet), receiptSvc.WithLedger(store), receiptSvc.WithCampaigns(store)}
+	if cfg.Rules.HistoryDir != "" {
+		history, err := rules.LoadDir(cfg.Rules.HistoryDir)
+		if err != nil {
+			fatal("Failed to load rule history", "dir", cfg.Rules.HistoryDir, "error", err)
+		}
+		options = append(options, receiptSvc.WithRuleHistory(history...))
+	}
+	return store, ruleSet, options
+}
+
+// openStore creates the configured storage backend
+func openStore(cfg config.Storage) (repo.Store, error) {
+	switch cfg.Backend {
+	case "memory":
+		return repo.NewMemoryStore(), nil
+	case "file":
+		policy, err := repo.ParseSyncPolicy(cfg.Fsync)
+		if err != nil {
+			return nil, err
+		}
+		return repo.OpenFileStore(cfg.DataDir, repo.FileStoreOptions{Sync: policy})
+	case "sql":
+		return repo.OpenSQLStore("sqlite", cfg.DSN)
 	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
 }
@@ -0,0 +1,145 @@
+// Package metrics exports Prometheus metrics about HTTP requests and receipt processing.
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"receipt-processor/models"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric
+const namespace = "receipt_processor"
+
+// unmatchedRoute labels requests that matched no route, keeping the label values bounded
+const unmatchedRoute = "unmatched"
+
+// ReceiptCounter counts stored receipts
+type ReceiptCounter interface {
+	Count() (int, error)
+}
+
+// Metrics records the metrics of the service in its own registry.
+// It observes receipt processing through the receipt service and handler observer interfaces.
+type Metrics struct {
+	registry           *prometheus.Registry
+	requests           *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	receiptsProcessed  prometheus.Counter
+	validationFailures *prometheus.CounterVec
+	pointsAwarded      prometheus.Histogram
+	ruleMatches        *prometheus.CounterVec
+}
+
+// New creates the metrics of the service, reporting the number of receipts in store when scraped
+func New(store ReceiptCounter) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to respond to HTTP requests by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		receiptsProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "receipts_processed_total",
+			Help:      "Receipts scored and stored.",
+		}),
+		validationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "validation_failures_total",
+			Help:      "Problems found in rejected receipts by field, item fields are counted together.",
+		}, []string{"field"}),
+		pointsAwarded: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "points_awarded",
+			Help:      "Points awarded to processed receipts.",
+			Buckets:   []float64{0, 10, 25, 50, 75, 100, 150, 200, 300, 500, 1000},
+		}),
+		ruleMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rule_matches_total",
+			Help:      "Processed receipts each scoring rule or campaign matched.",
+		}, []string{"rule"}),
+	}
+	storedReceipts := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stored_receipts",
+		Help:      "Receipts in store, NaN if the store could not be read.",
+	}, func() float64 {
+		count, err := store.Count()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(count)
+	})
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.receiptsProcessed,
+		m.validationFailures,
+		m.pointsAwarded,
+		m.ruleMatches,
+		storedReceipts,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times the requests of every route. Requests are labelled with the
+// route pattern, like /receipts/:id/points, rather than the path.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		m.requests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ReceiptProcessed counts a processed receipt, its points and the rules it matched
+func (m *Metrics) ReceiptProcessed(breakdown models.PointsBreakdown) {
+	m.receiptsProcessed.Inc()
+	m.pointsAwarded.Observe(float64(breakdown.Total))
+	for _, result := range breakdown.Rules {
+		if result.Matched {
+			m.ruleMatches.WithLabelValues(result.Rule).Inc()
+		}
+	}
+}
+
+// itemIndex matches the index of an item in a field name, like the 0 of items[0].price
+var itemIndex = regexp.MustCompile(`\[\d+\]`)
+
+// ReceiptRejected counts the problems of a rejected receipt by field
+func (m *Metrics) ReceiptRejected(errs models.ValidationErrors) {
+	for _, fieldErr := range errs {
+		m.validationFailures.WithLabelValues(itemIndex.ReplaceAllString(fieldErr.Field, "[]")).Inc()
+	}
+}
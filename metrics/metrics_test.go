@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// receiptCount is a store holding a fixed number of receipts, or failing to count them
+type receiptCount struct {
+	count int
+	err   error
+}
+
+func (r receiptCount) Count() (int, error) {
+	return r.count, r.err
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New(receiptCount{})
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/receipts/:id/points", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"points": 28})
+	})
+
+	for _, path := range []string{"/receipts/a/points", "/receipts/b/points", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are labelled with the route, not the path
+	require.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/receipts/:id/points", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")))
+	require.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestReceiptProcessed(t *testing.T) {
+	m := New(receiptCount{})
+
+	m.ReceiptProcessed(models.PointsBreakdown{Total: 28, Rules: []models.RuleResult{
+		{Rule: "retailer_alphanumeric", Matched: true, Points: 6},
+		{Rule: "round_total", Matched: false},
+	}})
+	m.ReceiptProcessed(models.PointsBreakdown{Total: 6, Rules: []models.RuleResult{
+		{Rule: "retailer_alphanumeric", Matched: true, Points: 6},
+	}})
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.receiptsProcessed))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.ruleMatches.WithLabelValues("retailer_alphanumeric")))
+	require.Equal(t, 1, testutil.CollectAndCount(m.ruleMatches))
+	require.NoError(t, testutil.CollectAndCompare(m.pointsAwarded, strings.NewReader(`
+# HELP receipt_processor_points_awarded Points awarded to processed receipts.
+# TYPE receipt_processor_points_awarded histogram
+receipt_processor_points_awarded_bucket{le="0"} 0
+receipt_processor_points_awarded_bucket{le="10"} 1
+receipt_processor_points_awarded_bucket{le="25"} 1
+receipt_processor_points_awarded_bucket{le="50"} 2
+receipt_processor_points_awarded_bucket{le="75"} 2
+receipt_processor_points_awarded_bucket{le="100"} 2
+receipt_processor_points_awarded_bucket{le="150"} 2
+receipt_processor_points_awarded_bucket{le="200"} 2
+receipt_processor_points_awarded_bucket{le="300"} 2
+receipt_processor_points_awarded_bucket{le="500"} 2
+receipt_processor_points_awarded_bucket{le="1000"} 2
+receipt_processor_points_awarded_bucket{le="+Inf"} 2
+receipt_processor_points_awarded_sum 34
+receipt_processor_points_awarded_count 2
+`)))
+}
+
+func TestReceiptRejected(t *testing.T) {
+	m := New(receiptCount{})
+
+	m.ReceiptRejected(models.ValidationErrors{{Field: "retailer"}, {Field: "items[0].price"}})
+	m.ReceiptRejected(models.ValidationErrors{{Field: "items[12].price"}})
+
+	// Item indexes are dropped to keep the label values bounded
+	require.Equal(t, 1.0, testutil.ToFloat64(m.validationFailures.WithLabelValues("retailer")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.validationFailures.WithLabelValues("items[].price")))
+	require.Equal(t, 2, testutil.CollectAndCount(m.validationFailures))
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(receiptCount{count: 3}).Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "receipt_processor_stored_receipts 3\n")
+	require.Contains(t, w.Body.String(), "go_goroutines")
+
+	w = httptest.NewRecorder()
+	New(receiptCount{err: errors.New("store unavailable")}).Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Contains(t, w.Body.String(), "receipt_processor_stored_receipts NaN\n")
+}
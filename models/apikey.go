@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// APIKey authenticates the requests of a client. Only a hash of the key is stored,
+// the key itself is returned once when it is created.
+type APIKey struct {
+	ID       string `json:"id"`
+	ClientID string `json:"clientId"`
+	Name     string `json:"name"`
+	// Admin keys may call the admin endpoints and see the receipts of every client
+	Admin bool `json:"admin"`
+	// Hash is the hex SHA-256 of the secret part of the key
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	// RevokedAt is when the key stopped being accepted, nil while it is active
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Revoked reports whether the key is no longer accepted
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Validate checks the client ID of a key and returns every problem found, or nil if it is valid.
+// The ID and hash are not checked.
+func (k APIKey) Validate() ValidationErrors {
+	var errs ValidationErrors
+	switch {
+	case k.ClientID == "":
+		errs = append(errs, FieldError{Field: "clientId", Code: CodeRequired, Message: "clientId is required"})
+	case !ValidClientID(k.ClientID):
+		errs = append(errs, FieldError{Field: "clientId", Code: CodePattern, Message: "clientId " + clientIDRule})
+	}
+	return errs
+}
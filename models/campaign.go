@@ -0,0 +1,129 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// MaxCampaignMultiplier bounds the multiplier of a campaign
+const MaxCampaignMultiplier = 100
+
+// Campaign is a time-bound promotion applied on top of the base scoring rules.
+// It awards receipts purchased within its date window that match its retailer and
+// item matchers, multiplying their base points, adding a flat bonus, or both.
+type Campaign struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// StartDate and EndDate are the inclusive yyyy-mm-dd window of purchase dates
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	// Retailer matches receipts from this retailer ignoring case, any retailer if empty
+	Retailer string `json:"retailer,omitempty"`
+	// RetailerID is the canonical retailer Retailer matches, resolved when receipts are scored.
+	// When both it and the RetailerID of a receipt are set, they are compared instead of the names.
+	RetailerID string `json:"-"`
+	// ItemContains matches receipts with an item whose description contains it ignoring case, any receipt if empty
+	ItemContains string `json:"itemContains,omitempty"`
+	// Multiplier scales the base points, 2 doubles them; zero leaves them unchanged
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Bonus is a flat number of points added to matching receipts
+	Bonus int64 `json:"bonus,omitempty"`
+}
+
+// AppliedCampaign records a campaign that awarded points to a receipt, with the terms it was awarded under
+type AppliedCampaign struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Bonus      int64   `json:"bonus,omitempty"`
+	// Points is what the campaign added to the base points
+	Points int64 `json:"points"`
+}
+
+// Matches reports whether a receipt qualifies for the campaign
+func (c Campaign) Matches(r Receipt) bool {
+	if r.PurchaseDate < c.StartDate || r.PurchaseDate > c.EndDate {
+		return false
+	}
+	if c.Retailer != "" && !c.matchesRetailer(r) {
+		return false
+	}
+	if c.ItemContains == "" {
+		return true
+	}
+	needle := strings.ToLower(c.ItemContains)
+	for _, item := range r.Items {
+		if strings.Contains(strings.ToLower(item.ShortDescription), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRetailer compares the canonical retailers of the campaign and the receipt when both are known, their names otherwise
+func (c Campaign) matchesRetailer(r Receipt) bool {
+	if c.RetailerID != "" && r.RetailerID != "" {
+		return c.RetailerID == r.RetailerID
+	}
+	return strings.EqualFold(r.Retailer, c.Retailer)
+}
+
+// Apply records the campaign as applied to a receipt awarded basePoints by the scoring rules
+func (c Campaign) Apply(basePoints int64) AppliedCampaign {
+	applied := AppliedCampaign{ID: c.ID, Name: c.Name, Multiplier: c.Multiplier, Bonus: c.Bonus}
+	applied.Points = applied.Rescale(basePoints)
+	return applied
+}
+
+// Rescale computes the points the campaign adds to basePoints, multiplied points are rounded half away from zero
+func (a AppliedCampaign) Rescale(basePoints int64) int64 {
+	points := a.Bonus
+	if a.Multiplier != 0 {
+		points += int64(math.Round(float64(basePoints) * (a.Multiplier - 1)))
+	}
+	return points
+}
+
+// Validate checks a campaign and returns every problem found, or nil if it is valid. The ID is not checked.
+func (c Campaign) Validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if strings.TrimSpace(c.Name) == "" {
+		add("name", CodeRequired, "name is required")
+	}
+
+	datesValid := true
+	for _, date := range []struct{ field, value string }{{"startDate", c.StartDate}, {"endDate", c.EndDate}} {
+		if date.value == "" {
+			add(date.field, CodeRequired, date.field+" is required")
+			datesValid = false
+		} else if _, err := time.Parse(time.DateOnly, date.value); err != nil {
+			add(date.field, CodeInvalidDate, date.field+" is not a calendar date in yyyy-mm-dd format")
+			datesValid = false
+		}
+	}
+	if datesValid && c.EndDate < c.StartDate {
+		add("endDate", CodeInvalidDate, "endDate is before startDate")
+	}
+
+	if c.Retailer != "" && !retailerPattern.MatchString(c.Retailer) {
+		add("retailer", CodePattern, "retailer may only contain letters, digits, spaces, '-' and '&'")
+	}
+	if c.ItemContains != "" && !descriptionPattern.MatchString(c.ItemContains) {
+		add("itemContains", CodePattern, "itemContains may only contain letters, digits, spaces and '-'")
+	}
+
+	switch {
+	case c.Multiplier < 0 || c.Multiplier > MaxCampaignMultiplier || math.IsNaN(c.Multiplier):
+		add("multiplier", CodeInvalidAmount, "multiplier must be between 0 and 100")
+	case c.Bonus < 0:
+		add("bonus", CodeInvalidAmount, "bonus must not be negative")
+	case c.Multiplier == 0 && c.Bonus == 0:
+		add("multiplier", CodeRequired, "a multiplier or a bonus is required")
+	}
+	return errs
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validCampaign() Campaign {
+	return Campaign{
+		Name:         "Gatorade weekend",
+		StartDate:    "2022-03-19",
+		EndDate:      "2022-03-20",
+		Retailer:     "m&m corner market",
+		ItemContains: "gatorade",
+		Multiplier:   2,
+		Bonus:        100,
+	}
+}
+
+func TestCampaignMatches(t *testing.T) {
+	receipt, err := validExtReceipt().ToReceipt("r1")
+	require.NoError(t, err)
+	require.True(t, validCampaign().Matches(receipt))
+
+	tests := map[string]func(*Campaign){
+		"before window":   func(c *Campaign) { c.StartDate = "2022-03-21"; c.EndDate = "2022-03-22" },
+		"after window":    func(c *Campaign) { c.StartDate = "2022-03-01"; c.EndDate = "2022-03-19" },
+		"other retailer":  func(c *Campaign) { c.Retailer = "Target" },
+		"no matched item": func(c *Campaign) { c.ItemContains = "Doritos" },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			campaign := validCampaign()
+			modify(&campaign)
+			require.False(t, campaign.Matches(receipt))
+		})
+	}
+}
+
+func TestCampaignMatchesRetailerID(t *testing.T) {
+	receipt, err := validExtReceipt().ToReceipt("r1")
+	require.NoError(t, err)
+
+	// The receipt names the retailer by an alias that matched the campaign retailer
+	receipt.Retailer = "M and M"
+	receipt.RetailerID = "m-and-m-corner-market"
+	campaign := validCampaign()
+	require.False(t, campaign.Matches(receipt))
+	campaign.RetailerID = "m-and-m-corner-market"
+	require.True(t, campaign.Matches(receipt))
+
+	// Different retailers do not match even under the same name
+	receipt.Retailer = campaign.Retailer
+	receipt.RetailerID = "m-and-m-corner-market-2"
+	require.False(t, campaign.Matches(receipt))
+}
+
+func TestCampaignApply(t *testing.T) {
+	applied := validCampaign().Apply(15)
+	require.Equal(t, int64(115), applied.Points)
+	require.Equal(t, int64(130), applied.Rescale(30))
+
+	// Multiplied points are rounded
+	require.Equal(t, int64(8), Campaign{Multiplier: 1.5}.Apply(15).Points)
+	require.Equal(t, int64(100), Campaign{Bonus: 100}.Apply(15).Points)
+}
+
+func TestValidateCampaign(t *testing.T) {
+	require.Empty(t, validCampaign().Validate())
+
+	tests := map[string]struct {
+		modify func(*Campaign)
+		field  string
+		code   string
+	}{
+		"missing name":       {func(c *Campaign) { c.Name = " " }, "name", CodeRequired},
+		"missing start date": {func(c *Campaign) { c.StartDate = "" }, "startDate", CodeRequired},
+		"impossible date":    {func(c *Campaign) { c.EndDate = "2022-02-30" }, "endDate", CodeInvalidDate},
+		"reversed window":    {func(c *Campaign) { c.EndDate = "2022-03-01" }, "endDate", CodeInvalidDate},
+		"retailer pattern":   {func(c *Campaign) { c.Retailer = "Target!" }, "retailer", CodePattern},
+		"negative bonus":     {func(c *Campaign) { c.Bonus = -1 }, "bonus", CodeInvalidAmount},
+		"huge multiplier":    {func(c *Campaign) { c.Multiplier = 1000 }, "multiplier", CodeInvalidAmount},
+		"nothing awarded":    {func(c *Campaign) { c.Multiplier = 0; c.Bonus = 0 }, "multiplier", CodeRequired},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			campaign := validCampaign()
+			tt.modify(&campaign)
+			errs := campaign.Validate()
+			require.Len(t, errs, 1)
+			require.Equal(t, tt.field, errs[0].Field)
+			require.Equal(t, tt.code, errs[0].Code)
+		})
+	}
+}
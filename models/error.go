@@ -0,0 +1,9 @@
+package models
+
+// Body of every error response of the API
+type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details,omitempty"`
+	// RequestID identifies the request in the server log
+	RequestID string `json:"requestId,omitempty"`
+}
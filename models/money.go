@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAmount is returned when a money amount cannot be parsed
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Money is an exact amount of dollars stored as integer cents
+type Money int64
+
+// ParseMoney parses a dollar amount with exactly two decimals and an optional leading "-", such as "6.49"
+// or "-1.50", so that it reads back every amount written by String
+func ParseMoney(s string) (Money, error) {
+	unsigned, negative := strings.CutPrefix(s, "-")
+	dollars, cents, found := strings.Cut(unsigned, ".")
+	if !found || !isDigits(dollars) || len(cents) != 2 || !isDigits(cents) {
+		return 0, fmt.Errorf("%w %q: must be a dollar amount with two decimals like 6.49", ErrInvalidAmount, s)
+	}
+
+	d, err := strconv.ParseInt(dollars, 10, 64)
+	if err != nil || d > (math.MaxInt64-99)/100 {
+		return 0, fmt.Errorf("%w %q: amount is too large", ErrInvalidAmount, s)
+	}
+	c, _ := strconv.ParseInt(cents, 10, 64)
+	if negative {
+		return Money(-(d*100 + c)), nil
+	}
+	return Money(d*100 + c), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents returns the amount in cents
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// String formats the amount with two decimals, such as "6.49"
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalText encodes the amount as a decimal string, so JSON carries "6.49" rather than 649
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText decodes an amount written by MarshalText
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
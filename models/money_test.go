@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		cents int64
+		valid bool
+	}{
+		{"0.00", 0, true},
+		{"6.49", 649, true},
+		{"12.00", 1200, true},
+		{"0012.25", 1225, true},
+		{"92233720368547757.99", 9223372036854775799, true},
+		{"0.1", 0, false},
+		{"0.100", 0, false},
+		{"1e3", 0, false},
+		{"1.5e2", 0, false},
+		{"-1.00", -100, true},
+		{"-0.05", -5, true},
+		{"-92233720368547757.99", -9223372036854775799, true},
+		{"--1.00", 0, false},
+		{"-", 0, false},
+		{"-.50", 0, false},
+		{"+1.00", 0, false},
+		{"1", 0, false},
+		{".99", 0, false},
+		{"1.", 0, false},
+		{"abc", 0, false},
+		{" 1.00", 0, false},
+		{"1,000.00", 0, false},
+		{"", 0, false},
+		{"92233720368547758.08", 0, false},
+		{"99999999999999999999.99", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			m, err := ParseMoney(tt.input)
+			if !tt.valid {
+				require.ErrorIs(t, err, ErrInvalidAmount)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.cents, m.Cents())
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	require.Equal(t, "0.00", Money(0).String())
+	require.Equal(t, "0.05", Money(5).String())
+	require.Equal(t, "35.35", Money(3535).String())
+	require.Equal(t, "-1.50", Money(-150).String())
+}
+
+func TestMoneyRoundTrip(t *testing.T) {
+	for _, m := range []Money{0, 5, 3535, -5, -150, -3535} {
+		parsed, err := ParseMoney(m.String())
+		require.NoError(t, err)
+		require.Equal(t, m, parsed)
+
+		text, err := m.MarshalText()
+		require.NoError(t, err)
+		var decoded Money
+		require.NoError(t, decoded.UnmarshalText(text))
+		require.Equal(t, m, decoded)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	item := Item{ShortDescription: "Gatorade", Price: 225}
+	raw, err := json.Marshal(item)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"shortDescription":"Gatorade","price":"2.25"}`, string(raw))
+
+	var decoded Item
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	require.Equal(t, item, decoded)
+
+	require.Error(t, json.Unmarshal([]byte(`{"price":"2.5"}`), &decoded))
+}
+
+func TestToReceipt(t *testing.T) {
+	ext := ExtReceipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []ExtItem{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:        "6.49",
+	}
+	receipt, err := ext.ToReceipt("id")
+	require.NoError(t, err)
+	require.Equal(t, "id", receipt.ID)
+	require.Equal(t, Money(649), receipt.Total)
+	require.Equal(t, Money(649), receipt.Items[0].Price)
+
+	ext.Items[0].Price = "6.4"
+	_, err = ext.ToReceipt("id")
+	require.ErrorIs(t, err, ErrInvalidAmount)
+	require.ErrorContains(t, err, "items[0].price")
+
+	// Receipt amounts are never negative
+	ext.Items[0].Price = "-6.49"
+	_, err = ext.ToReceipt("id")
+	require.ErrorIs(t, err, ErrInvalidAmount)
+	require.ErrorContains(t, err, "items[0].price")
+	ext.Items[0].Price = "6.49"
+	ext.Total = "-0.00"
+	_, err = ext.ToReceipt("id")
+	require.ErrorIs(t, err, ErrInvalidAmount)
+	require.ErrorContains(t, err, "total")
+}
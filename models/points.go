@@ -0,0 +1,19 @@
+package models
+
+// Outcome of a single scoring rule applied to a receipt
+type RuleResult struct {
+	Rule        string         `json:"rule"`
+	Description string         `json:"description"`
+	Matched     bool           `json:"matched"`
+	Inputs      map[string]any `json:"inputs"`
+	Points      int64          `json:"points"`
+}
+
+// Per-rule explanation of the points awarded to a receipt
+type PointsBreakdown struct {
+	Total int64        `json:"total"`
+	Rules []RuleResult `json:"rules"`
+	// Recomputed is set when the rule set that awarded the points is no longer known and the
+	// current rules explain them instead, so Total may differ from the points awarded
+	Recomputed bool `json:"recomputed,omitempty"`
+}
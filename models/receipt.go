@@ -1,26 +1,109 @@
 package models
 
-// External receipt structure sent by client
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// External receipt structure sent by client, checked with Validate
 type ExtReceipt struct {
-	Retailer     string `json:"retailer" binding:"required"`
-	PurchaseDate string `json:"purchaseDate" binding:"required"`
-	PurchaseTime string `json:"purchaseTime" binding:"required"`
-	Items        []Item `json:"items" binding:"required,dive"`
-	Total        string `json:"total" binding:"required"`
+	AccountID    string    `json:"accountId,omitempty"`
+	Retailer     string    `json:"retailer"`
+	PurchaseDate string    `json:"purchaseDate"`
+	PurchaseTime string    `json:"purchaseTime"`
+	Items        []ExtItem `json:"items"`
+	Total        string    `json:"total"`
+}
+
+// A single item purchased in a receipt sent by client
+type ExtItem struct {
+	ShortDescription string `json:"shortDescription"`
+	Price            string `json:"price"`
 }
 
-// Internal receipt structure used internally
+// Internal receipt structure used internally.
+// RetailerID is the canonical retailer the name was matched to, empty if it matched none.
 type Receipt struct {
 	ID           string
+	AccountID    string
 	Retailer     string
+	RetailerID   string
 	PurchaseDate string
 	PurchaseTime string
 	Items        []Item
-	Total        string
+	Total        Money
 }
 
 // A single item purchased in a receipt
 type Item struct {
-	ShortDescription string `json:"shortDescription" binding:"required"`
-	Price            string `json:"price" binding:"required"`
+	ShortDescription string `json:"shortDescription"`
+	Price            Money  `json:"price"`
+}
+
+// ToReceipt converts an external receipt into an internal receipt with the given ID.
+// It fails with ErrInvalidAmount if the total or an item price is malformed or negative.
+func (e ExtReceipt) ToReceipt(id string) (Receipt, error) {
+	total, err := parseAmount(e.Total)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("total: %w", err)
+	}
+
+	items := make([]Item, 0, len(e.Items))
+	for i, extItem := range e.Items {
+		price, err := parseAmount(extItem.Price)
+		if err != nil {
+			return Receipt{}, fmt.Errorf("items[%d].price: %w", i, err)
+		}
+		items = append(items, Item{
+			ShortDescription: extItem.ShortDescription,
+			Price:            price,
+		})
+	}
+
+	return Receipt{
+		ID:           id,
+		AccountID:    e.AccountID,
+		Retailer:     e.Retailer,
+		PurchaseDate: e.PurchaseDate,
+		PurchaseTime: e.PurchaseTime,
+		Items:        items,
+		Total:        total,
+	}, nil
+}
+
+// parseAmount parses the total or an item price of a receipt, which is never negative
+func parseAmount(s string) (Money, error) {
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("%w %q: must not be negative", ErrInvalidAmount, s)
+	}
+	return ParseMoney(s)
+}
+
+// Fingerprint is a canonical hash of the receipt contents, ignoring the ID, the
+// account, the matched retailer ID and surrounding whitespace, so the same purchase submitted twice has the same fingerprint
+func (r Receipt) Fingerprint() string {
+	canonical := struct {
+		Retailer     string   `json:"retailer"`
+		PurchaseDate string   `json:"purchaseDate"`
+		PurchaseTime string   `json:"purchaseTime"`
+		Items        [][2]any `json:"items"`
+		Total        int64    `json:"total"`
+	}{
+		Retailer:     strings.TrimSpace(r.Retailer),
+		PurchaseDate: r.PurchaseDate,
+		PurchaseTime: r.PurchaseTime,
+		Items:        make([][2]any, 0, len(r.Items)),
+		Total:        r.Total.Cents(),
+	}
+	for _, item := range r.Items {
+		canonical.Items = append(canonical.Items, [2]any{strings.TrimSpace(item.ShortDescription), item.Price.Cents()})
+	}
+
+	// Marshaling plain strings and integers cannot fail
+	raw, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
 }
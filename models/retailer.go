@@ -0,0 +1,16 @@
+package models
+
+// Retailer is a canonical retailer that receipts are grouped under.
+// Receipts are matched to it by its name or one of its aliases.
+type Retailer struct {
+	// ID is derived from the name when the retailer is registered
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// UnmatchedRetailer is a retailer name of stored receipts that matched no registered retailer
+type UnmatchedRetailer struct {
+	Name     string `json:"name"`
+	Receipts int    `json:"receipts"`
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Validation error codes reported in FieldError.Code
+const (
+	CodeRequired      = "required"
+	CodePattern       = "pattern"
+	CodeInvalidDate   = "invalid_date"
+	CodeInvalidTime   = "invalid_time"
+	CodeMinItems      = "min_items"
+	CodeInvalidAmount = "invalid_amount"
+	CodeTotalMismatch = "total_mismatch"
+	CodeMalformedJSON = "malformed_json"
+	CodeInvalidType   = "invalid_type"
+	CodeBodyTooLarge  = "body_too_large"
+)
+
+// A single problem with a field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Every problem found while validating a request
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fe := range v {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Patterns from the receipt-processor API specification
+var (
+	retailerPattern    = regexp.MustCompile(`^[\w\s\-&]+$`)
+	descriptionPattern = regexp.MustCompile(`^[\w\s\-]+$`)
+	amountPattern      = regexp.MustCompile(`^\d+\.\d{2}$`)
+	timePattern        = regexp.MustCompile(`^\d{2}:\d{2}$`)
+	// clientIDPattern matches the IDs of clients and of their accounts, which are named after them.
+	// Besides API key client IDs it admits JWT subjects such as "auth0|123" and email addresses.
+	clientIDPattern = regexp.MustCompile(`^[\w.@|+\-]{1,64}$`)
+)
+
+// clientIDRule describes clientIDPattern in validation messages
+const clientIDRule = "may only contain up to 64 letters, digits, '_', '-', '.', '@', '|' and '+'"
+
+// ValidClientID reports whether id may identify a client, and so the account of its points
+func ValidClientID(id string) bool {
+	return clientIDPattern.MatchString(id)
+}
+
+// Validate checks a receipt against the API specification and returns every problem found,
+// or nil if the receipt is valid
+func (e ExtReceipt) Validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if e.AccountID != "" && !ValidClientID(e.AccountID) {
+		add("accountId", CodePattern, "accountId %s", clientIDRule)
+	}
+
+	switch {
+	case e.Retailer == "":
+		add("retailer", CodeRequired, "retailer is required")
+	case !retailerPattern.MatchString(e.Retailer):
+		add("retailer", CodePattern, "retailer may only contain letters, digits, spaces, '-' and '&'")
+	}
+
+	if e.PurchaseDate == "" {
+		add("purchaseDate", CodeRequired, "purchaseDate is required")
+	} else if _, err := time.Parse(time.DateOnly, e.PurchaseDate); err != nil {
+		add("purchaseDate", CodeInvalidDate, "purchaseDate %q is not a calendar date in yyyy-mm-dd format", e.PurchaseDate)
+	}
+
+	if e.PurchaseTime == "" {
+		add("purchaseTime", CodeRequired, "purchaseTime is required")
+	} else if _, err := time.Parse("15:04", e.PurchaseTime); err != nil || !timePattern.MatchString(e.PurchaseTime) {
+		add("purchaseTime", CodeInvalidTime, "purchaseTime %q is not a 24-hour time in hh:mm format", e.PurchaseTime)
+	}
+
+	if len(e.Items) == 0 {
+		add("items", CodeMinItems, "at least one item is required")
+	}
+	var sum Money
+	amountsValid := true
+	for i, item := range e.Items {
+		field := fmt.Sprintf("items[%d].shortDescription", i)
+		switch {
+		case item.ShortDescription == "":
+			add(field, CodeRequired, "shortDescription is required")
+		case !descriptionPattern.MatchString(item.ShortDescription):
+			add(field, CodePattern, "shortDescription may only contain letters, digits, spaces and '-'")
+		}
+
+		field = fmt.Sprintf("items[%d].price", i)
+		price, ok := validateAmount(field, item.Price, add)
+		if ok && price > math.MaxInt64-sum {
+			add(field, CodeInvalidAmount, "sum of item prices is too large")
+			ok = false
+		}
+		amountsValid = amountsValid && ok
+		sum += price
+	}
+
+	total, ok := validateAmount("total", e.Total, add)
+	if ok && amountsValid && len(e.Items) > 0 && total != sum {
+		add("total", CodeTotalMismatch, "total %s does not match the sum of item prices %s", total, sum)
+	}
+
+	return errs
+}
+
+// validateAmount checks a dollar amount with two decimals, reporting whether it is valid
+func validateAmount(field, value string, add func(field, code, format string, args ...any)) (Money, bool) {
+	if value == "" {
+		add(field, CodeRequired, "%s is required", field[strings.LastIndex(field, ".")+1:])
+		return 0, false
+	}
+	if !amountPattern.MatchString(value) {
+		add(field, CodePattern, "%q is not a dollar amount with two decimals like 6.49", value)
+		return 0, false
+	}
+	amount, err := ParseMoney(value)
+	if err != nil {
+		add(field, CodeInvalidAmount, "%v", err)
+		return 0, false
+	}
+	return amount, true
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validExtReceipt() ExtReceipt {
+	return ExtReceipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []ExtItem{
+			{ShortDescription: "Gatorade", Price: "2.25"},
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+		},
+		Total: "8.74",
+	}
+}
+
+func TestValidateAcceptsValidReceipt(t *testing.T) {
+	require.Empty(t, validExtReceipt().Validate())
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		modify func(*ExtReceipt)
+		field  string
+		code   string
+	}{
+		"missing retailer":       {func(e *ExtReceipt) { e.Retailer = "" }, "retailer", CodeRequired},
+		"retailer pattern":       {func(e *ExtReceipt) { e.Retailer = "Target!" }, "retailer", CodePattern},
+		"missing date":           {func(e *ExtReceipt) { e.PurchaseDate = "" }, "purchaseDate", CodeRequired},
+		"slashed date":           {func(e *ExtReceipt) { e.PurchaseDate = "2022/13/45" }, "purchaseDate", CodeInvalidDate},
+		"impossible date":        {func(e *ExtReceipt) { e.PurchaseDate = "2022-02-30" }, "purchaseDate", CodeInvalidDate},
+		"missing time":           {func(e *ExtReceipt) { e.PurchaseTime = "" }, "purchaseTime", CodeRequired},
+		"impossible time":        {func(e *ExtReceipt) { e.PurchaseTime = "25:99" }, "purchaseTime", CodeInvalidTime},
+		"single digit hour":      {func(e *ExtReceipt) { e.PurchaseTime = "9:05" }, "purchaseTime", CodeInvalidTime},
+		"no items":               {func(e *ExtReceipt) { e.Items = nil }, "items", CodeMinItems},
+		"missing description":    {func(e *ExtReceipt) { e.Items[1].ShortDescription = "" }, "items[1].shortDescription", CodeRequired},
+		"description pattern":    {func(e *ExtReceipt) { e.Items[0].ShortDescription = "Gatorade®" }, "items[0].shortDescription", CodePattern},
+		"missing price":          {func(e *ExtReceipt) { e.Items[0].Price = "" }, "items[0].price", CodeRequired},
+		"price pattern":          {func(e *ExtReceipt) { e.Items[0].Price = "abc" }, "items[0].price", CodePattern},
+		"price one decimal":      {func(e *ExtReceipt) { e.Items[1].Price = "6.5" }, "items[1].price", CodePattern},
+		"price too large":        {func(e *ExtReceipt) { e.Items[0].Price = "99999999999999999999.00" }, "items[0].price", CodeInvalidAmount},
+		"missing total":          {func(e *ExtReceipt) { e.Total = "" }, "total", CodeRequired},
+		"negative total":         {func(e *ExtReceipt) { e.Total = "-8.74" }, "total", CodePattern},
+		"total not sum of items": {func(e *ExtReceipt) { e.Total = "8.75" }, "total", CodeTotalMismatch},
+		"account ID pattern":     {func(e *ExtReceipt) { e.AccountID = "alice smith" }, "accountId", CodePattern},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			receipt := validExtReceipt()
+			tt.modify(&receipt)
+			errs := receipt.Validate()
+			require.Len(t, errs, 1, "%v", errs)
+			require.Equal(t, tt.field, errs[0].Field)
+			require.Equal(t, tt.code, errs[0].Code)
+			require.NotEmpty(t, errs[0].Message)
+		})
+	}
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	errs := ExtReceipt{}.Validate()
+	fields := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	require.Equal(t, []string{"retailer", "purchaseDate", "purchaseTime", "items", "total"}, fields)
+	require.ErrorContains(t, errs, "retailer: retailer is required")
+}
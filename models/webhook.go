@@ -0,0 +1,94 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Types of the receipt events delivered to webhooks
+const (
+	EventReceiptProcessed = "receipt.processed"
+	EventReceiptDeleted   = "receipt.deleted"
+	EventReceiptRescored  = "receipt.rescored"
+)
+
+// EventTypes lists every event type a webhook can subscribe to
+var EventTypes = []string{EventReceiptProcessed, EventReceiptDeleted, EventReceiptRescored}
+
+// ReceiptEvent is a change to a stored receipt, delivered as JSON to the webhooks subscribed to its type
+type ReceiptEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ReceiptID   string    `json:"receiptId"`
+	AccountID   string    `json:"accountId,omitempty"`
+	Points      int64     `json:"points"`
+	RuleVersion string    `json:"ruleVersion,omitempty"`
+	// PreviousPoints is the points of a rescored receipt before it was rescored
+	PreviousPoints *int64 `json:"previousPoints,omitempty"`
+}
+
+// Webhook is a subscription to receipt events. Each event is posted to its URL signed with its secret.
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events are the event types delivered, every type if empty
+	Events []string `json:"events"`
+	// Secret is the HMAC-SHA256 key of the signature of each delivery
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Subscribes reports whether events of a type are delivered to the webhook
+func (w Webhook) Subscribes(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Validate checks the URL and event types of a webhook and returns every problem found,
+// or nil if it is valid. The ID and secret are not checked.
+func (w Webhook) Validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if w.URL == "" {
+		add("url", CodeRequired, "url is required")
+	} else if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("url", CodePattern, "url must be an absolute http or https URL")
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(EventTypes, event) {
+			add("events", CodePattern, "unknown event type "+event)
+		}
+	}
+	return errs
+}
+
+// Statuses of a delivery
+const (
+	// DeliveryPending deliveries are being attempted or wait for a retry
+	DeliveryPending = "pending"
+	// DeliverySucceeded deliveries were acknowledged with a 2xx response
+	DeliverySucceeded = "succeeded"
+	// DeliveryDead deliveries failed every attempt and are kept as dead letters
+	DeliveryDead = "dead"
+)
+
+// DeliveryAttempt is one request of a delivery, with the response status or the error that failed it
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Delivery is an event sent to a webhook, with every attempt made to send it
+type Delivery struct {
+	ID        string            `json:"id"`
+	WebhookID string            `json:"webhookId"`
+	Event     ReceiptEvent      `json:"event"`
+	Status    string            `json:"status"`
+	Attempts  []DeliveryAttempt `json:"attempts"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
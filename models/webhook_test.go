@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSubscribes(t *testing.T) {
+	all := Webhook{}
+	require.True(t, all.Subscribes(EventReceiptDeleted))
+
+	some := Webhook{Events: []string{EventReceiptProcessed}}
+	require.True(t, some.Subscribes(EventReceiptProcessed))
+	require.False(t, some.Subscribes(EventReceiptRescored))
+}
+
+func TestWebhookValidate(t *testing.T) {
+	require.Empty(t, Webhook{URL: "https://example.com/hooks", Events: EventTypes}.Validate())
+
+	tests := map[string]Webhook{
+		"url":    {URL: "example.com/hooks"},
+		"events": {URL: "http://localhost:9000", Events: []string{"receipt.created"}},
+	}
+	for field, w := range tests {
+		errs := w.Validate()
+		require.Len(t, errs, 1, field)
+		require.Equal(t, field, errs[0].Field)
+	}
+	require.Equal(t, CodeRequired, Webhook{}.Validate()[0].Code)
+}
@@ -0,0 +1,175 @@
+package account
+
+import (
+	"errors"
+	"net/http"
+	"receipt-processor/auth"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	accountSvc "receipt-processor/services/account"
+
+	"github.com/gin-gonic/gin"
+)
+
+var accountService accountSvc.AccountService
+
+// ownAccount responds 404 and returns false unless the client of the request may use the account.
+// A client's account is named after its ID, admins and requests without authentication use every account.
+func ownAccount(c *gin.Context, id string) bool {
+	client, ok := auth.FromContext(c.Request.Context())
+	if ok && !client.Admin && client.ID != id {
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Account not found"})
+		return false
+	}
+	return true
+}
+
+// Register router for the APIs
+func Register(router gin.IRouter, service accountSvc.AccountService) {
+	accountService = service
+
+	router.GET("/accounts/:id", GetAccount)
+	router.POST("/accounts/:id/redemptions", Redeem)
+	router.POST("/accounts/:id/redemptions/:redemptionId/reversal", ReverseRedemption)
+}
+
+// GetAccount godoc
+// @Summary Retrieves the points balance and history of an account
+// @Description Returns the balance of a loyalty account together with every ledger entry that makes it up, oldest first. The balance is the sum of the entry points.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID"
+// @Success 200 {object} ExtGetAccountResponse "Account retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Account not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /accounts/{id} [get]
+func GetAccount(c *gin.Context) {
+	id := c.Param("id")
+	if !ownAccount(c, id) {
+		return
+	}
+
+	account, err := accountService.GetAccount(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrAccountNotFound) {
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Account not found"})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	response := ExtGetAccountResponse{ID: account.ID, Balance: account.Balance, History: make([]ExtLedgerEntry, 0, len(account.Entries))}
+	for _, entry := range account.Entries {
+		response.History = append(response.History, ExtLedgerEntry{
+			ID:            entry.ID,
+			TransactionID: entry.TransactionID,
+			Kind:          entry.Kind,
+			ReceiptID:     entry.ReceiptID,
+			RedemptionID:  entry.RedemptionID,
+			Points:        entry.Points,
+			Balance:       entry.Balance,
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Redeem godoc
+// @Summary Spends points of an account
+// @Description Debits the points from the account balance. The balance never goes negative, a redemption of more points than the balance fails.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID"
+// @Param redemption body ExtRedeemRequest true "Points to redeem"
+// @Success 201 {object} ExtRedemptionResponse "Points redeemed"
+// @Failure 400 {object} models.ErrorResponse "Invalid request body or points not positive"
+// @Failure 404 {object} models.ErrorResponse "Account not found"
+// @Failure 422 {object} models.ErrorResponse "Insufficient points"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /accounts/{id}/redemptions [post]
+func Redeem(c *gin.Context) {
+	id := c.Param("id")
+	if !ownAccount(c, id) {
+		return
+	}
+
+	var request ExtRedeemRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	redemption, err := accountService.Redeem(id, request.Points)
+	if err != nil {
+		switch {
+		case errors.Is(err, accountSvc.ErrInvalidPoints):
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Points must be a positive whole number"})
+		case errors.Is(err, repo.ErrAccountNotFound):
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Account not found"})
+		case errors.Is(err, repo.ErrInsufficientFunds):
+			logging.WriteError(c, http.StatusUnprocessableEntity, models.ErrorResponse{Error: "Insufficient points"})
+		default:
+			c.Error(err)
+			logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newExtRedemptionResponse(redemption))
+}
+
+// ReverseRedemption godoc
+// @Summary Reverses a redemption
+// @Description Refunds the points of a redemption to its account. A redemption can be reversed once.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID"
+// @Param redemptionId path string true "Redemption ID"
+// @Success 200 {object} ExtRedemptionResponse "Redemption reversed"
+// @Failure 404 {object} models.ErrorResponse "Account or redemption not found"
+// @Failure 409 {object} models.ErrorResponse "Redemption already reversed"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /accounts/{id}/redemptions/{redemptionId}/reversal [post]
+func ReverseRedemption(c *gin.Context) {
+	id := c.Param("id")
+	redemptionID := c.Param("redemptionId")
+	if !ownAccount(c, id) {
+		return
+	}
+
+	redemption, err := accountService.ReverseRedemption(id, redemptionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrAccountNotFound):
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Account not found"})
+		case errors.Is(err, accountSvc.ErrRedemptionNotFound):
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Redemption not found"})
+		case errors.Is(err, accountSvc.ErrRedemptionReversed):
+			logging.WriteError(c, http.StatusConflict, models.ErrorResponse{Error: "Redemption already reversed"})
+		default:
+			c.Error(err)
+			logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newExtRedemptionResponse(redemption))
+}
+
+// newExtRedemptionResponse converts a redemption into its external format
+func newExtRedemptionResponse(redemption accountSvc.Redemption) ExtRedemptionResponse {
+	return ExtRedemptionResponse{
+		ID:        redemption.ID,
+		AccountID: redemption.AccountID,
+		Points:    redemption.Points,
+		Balance:   redemption.Balance,
+		Reversed:  redemption.Reversed,
+		CreatedAt: redemption.CreatedAt,
+	}
+}
@@ -0,0 +1,211 @@
+package account
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/auth"
+	"receipt-processor/repo"
+	accountSvc "receipt-processor/services/account"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MockAccountService is a mock implementation of the AccountService interface
+type MockAccountService struct {
+	mock.Mock
+}
+
+func (m *MockAccountService) GetAccount(id string) (accountSvc.Account, error) {
+	args := m.Called(id)
+	return args.Get(0).(accountSvc.Account), args.Error(1)
+}
+
+func (m *MockAccountService) Redeem(accountID string, points int64) (accountSvc.Redemption, error) {
+	args := m.Called(accountID, points)
+	return args.Get(0).(accountSvc.Redemption), args.Error(1)
+}
+
+func (m *MockAccountService) ReverseRedemption(accountID, redemptionID string) (accountSvc.Redemption, error) {
+	args := m.Called(accountID, redemptionID)
+	return args.Get(0).(accountSvc.Redemption), args.Error(1)
+}
+
+// AccountHandlerTestSuite defines the suite for handler tests
+type AccountHandlerTestSuite struct {
+	suite.Suite
+	mockService *MockAccountService
+	router      *gin.Engine
+}
+
+// SetupTest initializes the suite
+func (suite *AccountHandlerTestSuite) SetupTest() {
+	suite.mockService = new(MockAccountService)
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService)
+}
+
+func (suite *AccountHandlerTestSuite) TestGetAccount() {
+	createdAt := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	suite.mockService.On("GetAccount", "alice").Return(accountSvc.Account{
+		ID:      "alice",
+		Balance: 28,
+		Entries: []repo.LedgerEntry{
+			{ID: "e1", TransactionID: "t1", AccountID: "alice", Kind: repo.EntryCredit, ReceiptID: "r1", Points: 28, Balance: 28, CreatedAt: createdAt},
+		},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/accounts/alice", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtGetAccountResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(ExtGetAccountResponse{
+		ID:      "alice",
+		Balance: 28,
+		History: []ExtLedgerEntry{{ID: "e1", TransactionID: "t1", Kind: repo.EntryCredit, ReceiptID: "r1", Points: 28, Balance: 28, CreatedAt: createdAt}},
+	}, response)
+}
+
+func (suite *AccountHandlerTestSuite) TestGetAccountNotFound() {
+	suite.mockService.On("GetAccount", "nobody").Return(accountSvc.Account{}, repo.ErrAccountNotFound)
+
+	req := httptest.NewRequest("GET", "/accounts/nobody", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *AccountHandlerTestSuite) TestSystemAccountsNotFound() {
+	// The real service, over a ledger where the system accounts have entries
+	store := repo.NewMemoryStore()
+	_, err := store.Transfer(repo.Transfer{ID: "t1", Kind: repo.EntryCredit, From: repo.IssuedAccount, To: "alice", Points: 10})
+	suite.Require().NoError(err)
+	suite.router = gin.New()
+	Register(suite.router, accountSvc.NewAccountService(store))
+
+	for _, id := range []string{repo.IssuedAccount, repo.RedeemedAccount} {
+		for _, req := range []*http.Request{
+			httptest.NewRequest("GET", "/accounts/"+id, nil),
+			httptest.NewRequest("POST", "/accounts/"+id+"/redemptions", strings.NewReader(`{"points": 1000000}`)),
+			httptest.NewRequest("POST", "/accounts/"+id+"/redemptions/t1/reversal", nil),
+		} {
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+			suite.Equal(http.StatusNotFound, w.Code, req.URL.Path)
+		}
+	}
+	balance, err := store.Balance(repo.IssuedAccount)
+	suite.Require().NoError(err)
+	suite.Equal(int64(-10), balance)
+}
+
+func (suite *AccountHandlerTestSuite) TestAccountsOfClient() {
+	suite.router = gin.New()
+	// Stands in for the authentication middleware, with the client named by a header
+	suite.router.Use(func(c *gin.Context) {
+		client := auth.Client{ID: c.GetHeader("X-Client"), Admin: c.GetHeader("X-Admin") == "true"}
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), client))
+	})
+	Register(suite.router, suite.mockService)
+	suite.mockService.On("GetAccount", "bob").Return(accountSvc.Account{ID: "bob", Balance: 28}, nil)
+
+	serve := func(method, target, client string, admin bool) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"points": 5}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client", client)
+		req.Header.Set("X-Admin", strconv.FormatBool(admin))
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Alice cannot tell Bob's account from a missing one, and the service is never called for it
+	suite.Equal(http.StatusNotFound, serve("GET", "/accounts/bob", "alice", false))
+	suite.Equal(http.StatusNotFound, serve("POST", "/accounts/bob/redemptions", "alice", false))
+	suite.Equal(http.StatusNotFound, serve("POST", "/accounts/bob/redemptions/r1/reversal", "alice", false))
+	suite.mockService.AssertNotCalled(suite.T(), "Redeem", "bob", int64(5))
+	suite.mockService.AssertNotCalled(suite.T(), "ReverseRedemption", "bob", "r1")
+
+	suite.Equal(http.StatusOK, serve("GET", "/accounts/bob", "bob", false))
+	suite.Equal(http.StatusOK, serve("GET", "/accounts/bob", "ops", true))
+}
+
+func (suite *AccountHandlerTestSuite) TestGetAccountUnbalanced() {
+	suite.mockService.On("GetAccount", "alice").Return(accountSvc.Account{}, accountSvc.ErrUnbalancedLedger)
+
+	req := httptest.NewRequest("GET", "/accounts/alice", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusInternalServerError, w.Code)
+}
+
+func (suite *AccountHandlerTestSuite) TestRedeem() {
+	createdAt := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	suite.mockService.On("Redeem", "alice", int64(30)).Return(accountSvc.Redemption{
+		ID: "r1", AccountID: "alice", Points: 30, Balance: 70, CreatedAt: createdAt,
+	}, nil)
+
+	req := httptest.NewRequest("POST", "/accounts/alice/redemptions", strings.NewReader(`{"points": 30}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusCreated, w.Code)
+	var response ExtRedemptionResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(ExtRedemptionResponse{ID: "r1", AccountID: "alice", Points: 30, Balance: 70, CreatedAt: createdAt}, response)
+}
+
+func (suite *AccountHandlerTestSuite) TestRedeemErrors() {
+	suite.mockService.On("Redeem", "alice", int64(1000)).Return(accountSvc.Redemption{}, repo.ErrInsufficientFunds)
+	suite.mockService.On("Redeem", "alice", int64(-5)).Return(accountSvc.Redemption{}, accountSvc.ErrInvalidPoints)
+	suite.mockService.On("Redeem", "nobody", int64(5)).Return(accountSvc.Redemption{}, repo.ErrAccountNotFound)
+
+	tests := []struct {
+		path, body string
+		status     int
+	}{
+		{"/accounts/alice/redemptions", `{"points": 1000}`, http.StatusUnprocessableEntity},
+		{"/accounts/alice/redemptions", `{"points": -5}`, http.StatusBadRequest},
+		{"/accounts/alice/redemptions", `{"points": "many"}`, http.StatusBadRequest},
+		{"/accounts/nobody/redemptions", `{"points": 5}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Equal(tt.status, w.Code, tt.body)
+	}
+}
+
+func (suite *AccountHandlerTestSuite) TestReverseRedemption() {
+	suite.mockService.On("ReverseRedemption", "alice", "r1").Return(accountSvc.Redemption{
+		ID: "r1", AccountID: "alice", Points: 30, Balance: 100, Reversed: true,
+	}, nil)
+	suite.mockService.On("ReverseRedemption", "alice", "r2").Return(accountSvc.Redemption{}, accountSvc.ErrRedemptionReversed)
+	suite.mockService.On("ReverseRedemption", "alice", "r3").Return(accountSvc.Redemption{}, accountSvc.ErrRedemptionNotFound)
+
+	for redemptionID, status := range map[string]int{"r1": http.StatusOK, "r2": http.StatusConflict, "r3": http.StatusNotFound} {
+		req := httptest.NewRequest("POST", "/accounts/alice/redemptions/"+redemptionID+"/reversal", nil)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Equal(status, w.Code, redemptionID)
+	}
+}
+
+func TestAccountHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountHandlerTestSuite))
+}
@@ -0,0 +1,33 @@
+package account
+
+import "time"
+
+type ExtLedgerEntry struct {
+	ID            string    `json:"id"`
+	TransactionID string    `json:"transactionId"`
+	Kind          string    `json:"kind"`
+	ReceiptID     string    `json:"receiptId,omitempty"`
+	RedemptionID  string    `json:"redemptionId,omitempty"`
+	Points        int64     `json:"points"`
+	Balance       int64     `json:"balance"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type ExtGetAccountResponse struct {
+	ID      string           `json:"id"`
+	Balance int64            `json:"balance"`
+	History []ExtLedgerEntry `json:"history"`
+}
+
+type ExtRedeemRequest struct {
+	Points int64 `json:"points"`
+}
+
+type ExtRedemptionResponse struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"accountId"`
+	Points    int64     `json:"points"`
+	Balance   int64     `json:"balance"`
+	Reversed  bool      `json:"reversed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	apikeySvc "receipt-processor/services/apikey"
+
+	"github.com/gin-gonic/gin"
+)
+
+var apiKeyService apikeySvc.APIKeyService
+
+// Register router for the APIs
+func Register(router gin.IRouter, service apikeySvc.APIKeyService) {
+	apiKeyService = service
+
+	router.POST("/admin/api-keys", CreateAPIKey)
+	router.GET("/admin/api-keys", ListAPIKeys)
+	router.DELETE("/admin/api-keys/:id", RevokeAPIKey)
+}
+
+// CreateAPIKey godoc
+// @Summary Creates an API key for a client
+// @Description Generates a key authenticating the client in the X-API-Key header. Receipts submitted with it are owned by the client, and only admin keys see the receipts of other clients. Only a hash of the key is stored, the key is returned here only.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param apiKey body ExtCreateAPIKeyRequest true "Client, name and admin flag of the key"
+// @Success 201 {object} ExtCreateAPIKeyResponse "API key created, with the key"
+// @Failure 400 {object} models.ErrorResponse "Invalid API key, details lists every invalid field"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var request ExtCreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	created, key, err := apiKeyService.CreateAPIKey(models.APIKey{ClientID: request.ClientID, Name: request.Name, Admin: request.Admin})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ExtCreateAPIKeyResponse{ExtAPIKeyResponse: newAPIKeyResponse(created), Key: key})
+}
+
+// ListAPIKeys godoc
+// @Summary Lists API keys
+// @Description Returns every API key without the key itself, revoked ones included, oldest first.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Success 200 {object} ExtListAPIKeysResponse "API keys retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	keys, err := apiKeyService.ListAPIKeys()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response := ExtListAPIKeysResponse{APIKeys: make([]ExtAPIKeyResponse, 0, len(keys))}
+	for _, k := range keys {
+		response.APIKeys = append(response.APIKeys, newAPIKeyResponse(k))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revokes an API key
+// @Description Stops accepting the key. The key stays listed with the time it was revoked, and revoking it again changes nothing.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} ExtAPIKeyResponse "API key revoked"
+// @Failure 404 {object} models.ErrorResponse "API key not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	revoked, err := apiKeyService.RevokeAPIKey(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIKeyResponse(revoked))
+}
+
+// respondError maps a service error to its response
+func respondError(c *gin.Context, err error) {
+	var errs models.ValidationErrors
+	switch {
+	case errors.As(err, &errs):
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid API key", Details: errs})
+	case errors.Is(err, repo.ErrAPIKeyNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "API key not found"})
+	default:
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
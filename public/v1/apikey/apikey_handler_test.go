@@ -0,0 +1,134 @@
+package apikey
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/auth"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MockAPIKeyService is a mock implementation of the APIKeyService interface
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(k models.APIKey) (models.APIKey, string, error) {
+	args := m.Called(k)
+	return args.Get(0).(models.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys() ([]models.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(id string) (models.APIKey, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) VerifyKey(key string) (auth.Client, error) {
+	args := m.Called(key)
+	return args.Get(0).(auth.Client), args.Error(1)
+}
+
+// APIKeyHandlerTestSuite defines the suite for handler tests
+type APIKeyHandlerTestSuite struct {
+	suite.Suite
+	mockService *MockAPIKeyService
+	router      *gin.Engine
+	mockAPIKey  models.APIKey
+}
+
+// SetupTest initializes the suite
+func (suite *APIKeyHandlerTestSuite) SetupTest() {
+	suite.mockService = new(MockAPIKeyService)
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService)
+	suite.mockAPIKey = models.APIKey{
+		ID:        "k1",
+		ClientID:  "acme",
+		Name:      "checkout",
+		Hash:      "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func (suite *APIKeyHandlerTestSuite) serve(method, target string, body any) *httptest.ResponseRecorder {
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *APIKeyHandlerTestSuite) TestCreateAPIKey() {
+	suite.mockService.On("CreateAPIKey", models.APIKey{ClientID: "acme", Name: "checkout"}).Return(suite.mockAPIKey, "rpk_k1_secret", nil)
+
+	w := suite.serve("POST", "/admin/api-keys", ExtCreateAPIKeyRequest{ClientID: "acme", Name: "checkout"})
+
+	// The key is returned when it is created, never its hash
+	suite.Equal(http.StatusCreated, w.Code)
+	suite.JSONEq(`{"id":"k1","clientId":"acme","name":"checkout","admin":false,"createdAt":"2024-03-01T12:00:00Z","key":"rpk_k1_secret"}`, w.Body.String())
+}
+
+func (suite *APIKeyHandlerTestSuite) TestCreateAPIKeyInvalid() {
+	errs := models.ValidationErrors{{Field: "clientId", Code: models.CodePattern, Message: "clientId may only contain up to 64 letters, digits, '_', '-', '.', '@', '|' and '+'"}}
+	suite.mockService.On("CreateAPIKey", models.APIKey{ClientID: "a b"}).Return(models.APIKey{}, "", errs)
+
+	w := suite.serve("POST", "/admin/api-keys", ExtCreateAPIKeyRequest{ClientID: "a b"})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal([]models.FieldError(errs), response.Details)
+
+	suite.Equal(http.StatusBadRequest, suite.serve("POST", "/admin/api-keys", map[string]string{}).Code)
+}
+
+func (suite *APIKeyHandlerTestSuite) TestListAPIKeysHidesHash() {
+	suite.mockService.On("ListAPIKeys").Return([]models.APIKey{suite.mockAPIKey}, nil)
+
+	w := suite.serve("GET", "/admin/api-keys", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"apiKeys":[{"id":"k1","clientId":"acme","name":"checkout","admin":false,"createdAt":"2024-03-01T12:00:00Z"}]}`, w.Body.String())
+}
+
+func (suite *APIKeyHandlerTestSuite) TestRevokeAPIKey() {
+	revoked := suite.mockAPIKey
+	revokedAt := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	revoked.RevokedAt = &revokedAt
+	suite.mockService.On("RevokeAPIKey", "k1").Return(revoked, nil)
+	suite.mockService.On("RevokeAPIKey", "missing").Return(models.APIKey{}, fmt.Errorf("api key with id missing does not exist: %w", repo.ErrAPIKeyNotFound))
+	suite.mockService.On("RevokeAPIKey", "broken").Return(models.APIKey{}, errors.New("disk full"))
+
+	w := suite.serve("DELETE", "/admin/api-keys/k1", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtAPIKeyResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(&revokedAt, response.RevokedAt)
+
+	suite.Equal(http.StatusNotFound, suite.serve("DELETE", "/admin/api-keys/missing", nil).Code)
+	suite.Equal(http.StatusInternalServerError, suite.serve("DELETE", "/admin/api-keys/broken", nil).Code)
+}
+
+// TestAPIKeyHandlerTestSuite runs the test suite
+func TestAPIKeyHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyHandlerTestSuite))
+}
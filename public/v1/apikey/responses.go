@@ -0,0 +1,38 @@
+package apikey
+
+import (
+	"receipt-processor/models"
+	"time"
+)
+
+type ExtCreateAPIKeyRequest struct {
+	// ClientID is the client authenticated by the key, it owns the receipts submitted with it
+	ClientID string `json:"clientId" binding:"required"`
+	Name     string `json:"name"`
+	// Admin keys may call the admin endpoints and see the receipts of every client
+	Admin bool `json:"admin"`
+}
+
+// ExtAPIKeyResponse is an API key without its hash
+type ExtAPIKeyResponse struct {
+	ID        string     `json:"id"`
+	ClientID  string     `json:"clientId"`
+	Name      string     `json:"name"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ExtCreateAPIKeyResponse is a created API key with the key itself, which is only returned once
+type ExtCreateAPIKeyResponse struct {
+	ExtAPIKeyResponse
+	Key string `json:"key"`
+}
+
+type ExtListAPIKeysResponse struct {
+	APIKeys []ExtAPIKeyResponse `json:"apiKeys"`
+}
+
+func newAPIKeyResponse(k models.APIKey) ExtAPIKeyResponse {
+	return ExtAPIKeyResponse{ID: k.ID, ClientID: k.ClientID, Name: k.Name, Admin: k.Admin, CreatedAt: k.CreatedAt, RevokedAt: k.RevokedAt}
+}
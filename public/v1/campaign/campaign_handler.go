@@ -0,0 +1,149 @@
+package campaign
+
+import (
+	"errors"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	campaignSvc "receipt-processor/services/campaign"
+
+	"github.com/gin-gonic/gin"
+)
+
+var campaignService campaignSvc.CampaignService
+
+// Register router for the APIs
+func Register(router gin.IRouter, service campaignSvc.CampaignService) {
+	campaignService = service
+
+	router.POST("/campaigns", CreateCampaign)
+	router.GET("/campaigns", ListCampaigns)
+	router.GET("/campaigns/:id", GetCampaign)
+	router.PUT("/campaigns/:id", UpdateCampaign)
+	router.DELETE("/campaigns/:id", DeleteCampaign)
+}
+
+// CreateCampaign godoc
+// @Summary Creates a promotional campaign
+// @Description Receipts purchased within the date window of a campaign that match its retailer and item matchers are awarded extra points on top of the base rules: their base points are multiplied by the multiplier and the bonus is added. Either may be omitted, not both.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param campaign body models.Campaign true "Campaign, the id is ignored"
+// @Success 201 {object} models.Campaign "Campaign created"
+// @Failure 400 {object} models.ErrorResponse "Invalid campaign, details lists every invalid field"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /campaigns [post]
+func CreateCampaign(c *gin.Context) {
+	var campaign models.Campaign
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	created, err := campaignService.CreateCampaign(campaign)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListCampaigns godoc
+// @Summary Lists promotional campaigns
+// @Description Returns every campaign, past, running and upcoming, ordered by start date.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Success 200 {object} ExtListCampaignsResponse "Campaigns retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /campaigns [get]
+func ListCampaigns(c *gin.Context) {
+	campaigns, err := campaignService.ListCampaigns()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ExtListCampaignsResponse{Campaigns: campaigns})
+}
+
+// GetCampaign godoc
+// @Summary Retrieves a promotional campaign by ID
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param id path string true "Campaign ID"
+// @Success 200 {object} models.Campaign "Campaign retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Campaign not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /campaigns/{id} [get]
+func GetCampaign(c *gin.Context) {
+	campaign, err := campaignService.GetCampaign(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, campaign)
+}
+
+// UpdateCampaign godoc
+// @Summary Replaces a promotional campaign
+// @Description Replaces every field of a campaign. Receipts already awarded keep the points of the terms they were awarded under.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param id path string true "Campaign ID"
+// @Param campaign body models.Campaign true "Campaign, the id is ignored"
+// @Success 200 {object} models.Campaign "Campaign updated"
+// @Failure 400 {object} models.ErrorResponse "Invalid campaign, details lists every invalid field"
+// @Failure 404 {object} models.ErrorResponse "Campaign not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /campaigns/{id} [put]
+func UpdateCampaign(c *gin.Context) {
+	var campaign models.Campaign
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	updated, err := campaignService.UpdateCampaign(c.Param("id"), campaign)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteCampaign godoc
+// @Summary Deletes a promotional campaign
+// @Description Stops awarding the campaign. Receipts already awarded keep its points.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param id path string true "Campaign ID"
+// @Success 204 "Campaign deleted"
+// @Failure 404 {object} models.ErrorResponse "Campaign not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /campaigns/{id} [delete]
+func DeleteCampaign(c *gin.Context) {
+	if err := campaignService.DeleteCampaign(c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps a service error to its response
+func respondError(c *gin.Context, err error) {
+	var errs models.ValidationErrors
+	switch {
+	case errors.As(err, &errs):
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid campaign", Details: errs})
+	case errors.Is(err, repo.ErrCampaignNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Campaign not found"})
+	default:
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
@@ -0,0 +1,143 @@
+package campaign
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MockCampaignService is a mock implementation of the CampaignService interface
+type MockCampaignService struct {
+	mock.Mock
+}
+
+func (m *MockCampaignService) CreateCampaign(c models.Campaign) (models.Campaign, error) {
+	args := m.Called(c)
+	return args.Get(0).(models.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignService) GetCampaign(id string) (models.Campaign, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignService) ListCampaigns() ([]models.Campaign, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignService) UpdateCampaign(id string, c models.Campaign) (models.Campaign, error) {
+	args := m.Called(id, c)
+	return args.Get(0).(models.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignService) DeleteCampaign(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// CampaignHandlerTestSuite defines the suite for handler tests
+type CampaignHandlerTestSuite struct {
+	suite.Suite
+	mockService  *MockCampaignService
+	router       *gin.Engine
+	mockCampaign models.Campaign
+}
+
+// SetupTest initializes the suite
+func (suite *CampaignHandlerTestSuite) SetupTest() {
+	suite.mockService = new(MockCampaignService)
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService)
+	suite.mockCampaign = models.Campaign{
+		Name:       "Double points at Target",
+		StartDate:  "2022-01-01",
+		EndDate:    "2022-01-02",
+		Retailer:   "Target",
+		Multiplier: 2,
+	}
+}
+
+func (suite *CampaignHandlerTestSuite) serve(method, target string, body any) *httptest.ResponseRecorder {
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *CampaignHandlerTestSuite) TestCreateCampaign() {
+	created := suite.mockCampaign
+	created.ID = "c1"
+	suite.mockService.On("CreateCampaign", suite.mockCampaign).Return(created, nil)
+
+	w := suite.serve("POST", "/campaigns", suite.mockCampaign)
+
+	suite.Equal(http.StatusCreated, w.Code)
+	var response models.Campaign
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(created, response)
+}
+
+func (suite *CampaignHandlerTestSuite) TestCreateInvalidCampaign() {
+	suite.mockCampaign.Multiplier = 0
+	suite.mockService.On("CreateCampaign", suite.mockCampaign).Return(models.Campaign{}, suite.mockCampaign.Validate())
+
+	w := suite.serve("POST", "/campaigns", suite.mockCampaign)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Details, 1)
+	suite.Equal("multiplier", response.Details[0].Field)
+}
+
+func (suite *CampaignHandlerTestSuite) TestListCampaigns() {
+	suite.mockService.On("ListCampaigns").Return([]models.Campaign{}, nil)
+
+	w := suite.serve("GET", "/campaigns", nil)
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"campaigns":[]}`, w.Body.String())
+}
+
+func (suite *CampaignHandlerTestSuite) TestUpdateCampaign() {
+	updated := suite.mockCampaign
+	updated.ID = "c1"
+	suite.mockService.On("UpdateCampaign", "c1", suite.mockCampaign).Return(updated, nil)
+	suite.mockService.On("UpdateCampaign", "missing", suite.mockCampaign).Return(models.Campaign{}, repo.ErrCampaignNotFound)
+
+	w := suite.serve("PUT", "/campaigns/c1", suite.mockCampaign)
+	suite.Equal(http.StatusOK, w.Code)
+
+	w = suite.serve("PUT", "/campaigns/missing", suite.mockCampaign)
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *CampaignHandlerTestSuite) TestGetAndDeleteCampaignNotFound() {
+	suite.mockService.On("GetCampaign", "missing").Return(models.Campaign{}, repo.ErrCampaignNotFound)
+	suite.mockService.On("DeleteCampaign", "missing").Return(repo.ErrCampaignNotFound)
+	suite.mockService.On("DeleteCampaign", "c1").Return(nil)
+
+	suite.Equal(http.StatusNotFound, suite.serve("GET", "/campaigns/missing", nil).Code)
+	suite.Equal(http.StatusNotFound, suite.serve("DELETE", "/campaigns/missing", nil).Code)
+	suite.Equal(http.StatusNoContent, suite.serve("DELETE", "/campaigns/c1", nil).Code)
+}
+
+func TestCampaignHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(CampaignHandlerTestSuite))
+}
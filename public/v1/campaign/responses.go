@@ -0,0 +1,7 @@
+package campaign
+
+import "receipt-processor/models"
+
+type ExtListCampaignsResponse struct {
+	Campaigns []models.Campaign `json:"campaigns"`
+}
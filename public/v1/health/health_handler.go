@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"receipt-processor/logging"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each readiness check, so a hung dependency fails the probe instead of stalling it
+const checkTimeout = 2 * time.Second
+
+// Check is a dependency the service needs to serve requests
+type Check struct {
+	// Name identifies the dependency in the readiness response
+	Name string
+	// Check fails if the dependency is unavailable
+	Check func(ctx context.Context) error
+}
+
+// BuildInfo describes the running build
+type BuildInfo struct {
+	Version string
+	Commit  string
+	// RuleVersion is the version of the rule set receipts are scored with
+	RuleVersion string
+}
+
+var (
+	buildInfo BuildInfo
+	checks    []Check
+)
+
+// Register router for the APIs.
+// The routes are meant for probes and must not be put behind authentication or rate limiting.
+func Register(router gin.IRouter, info BuildInfo, readiness ...Check) {
+	buildInfo = info
+	checks = readiness
+
+	router.GET("/healthz", Healthz)
+	router.GET("/readyz", Readyz)
+	router.GET("/version", Version)
+}
+
+// Healthz godoc
+// @Summary Reports that the process is alive
+// @Description Liveness probe. Succeeds as long as the server handles requests, without checking its dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ExtHealthResponse "Server is alive"
+// @Router /healthz [get]
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtHealthResponse{Status: "ok"})
+}
+
+// Readyz godoc
+// @Summary Reports whether the server can serve requests
+// @Description Readiness probe. Checks that the store is reachable and the rule set is loaded, and reports the result of each check.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ExtReadinessResponse "Every check passed"
+// @Failure 503 {object} ExtReadinessResponse "A check failed"
+// @Router /readyz [get]
+func Readyz(c *gin.Context) {
+	response := ExtReadinessResponse{Status: "ready", Checks: make(map[string]string, len(checks))}
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+		err := check.Check(ctx)
+		cancel()
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("readiness check failed", "check", check.Name, "error", err)
+			response.Status = "unavailable"
+			response.Checks[check.Name] = "unavailable"
+			continue
+		}
+		response.Checks[check.Name] = "ok"
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+// Version godoc
+// @Summary Retrieves the build version
+// @Description Returns the version and commit the server was built from, and the version of the rule set it scores receipts with.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ExtVersionResponse "Build information"
+// @Router /version [get]
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtVersionResponse{
+		Version:     buildInfo.Version,
+		Commit:      buildInfo.Commit,
+		RuleVersion: buildInfo.RuleVersion,
+		GoVersion:   runtime.Version(),
+	})
+}
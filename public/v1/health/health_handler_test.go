@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+// HealthHandlerTestSuite defines the suite for handler tests
+type HealthHandlerTestSuite struct {
+	suite.Suite
+	router   *gin.Engine
+	storeErr error
+}
+
+// SetupTest initializes the suite
+func (suite *HealthHandlerTestSuite) SetupTest() {
+	suite.storeErr = nil
+	suite.router = gin.Default()
+	Register(suite.router, BuildInfo{Version: "1.4.0", Commit: "c2e50e1", RuleVersion: "2024-promo"},
+		Check{Name: "store", Check: func(ctx context.Context) error { return suite.storeErr }},
+		Check{Name: "rules", Check: func(ctx context.Context) error { return nil }})
+}
+
+func (suite *HealthHandlerTestSuite) serve(target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func (suite *HealthHandlerTestSuite) TestHealthz() {
+	w := suite.serve("/healthz")
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"status":"ok"}`, w.Body.String())
+}
+
+func (suite *HealthHandlerTestSuite) TestReadyz() {
+	w := suite.serve("/readyz")
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"status":"ready","checks":{"store":"ok","rules":"ok"}}`, w.Body.String())
+}
+
+func (suite *HealthHandlerTestSuite) TestReadyzUnavailable() {
+	suite.storeErr = errors.New("failed to reach database: connection refused")
+
+	w := suite.serve("/readyz")
+
+	suite.Equal(http.StatusServiceUnavailable, w.Code)
+	suite.JSONEq(`{"status":"unavailable","checks":{"store":"unavailable","rules":"ok"}}`, w.Body.String())
+}
+
+func (suite *HealthHandlerTestSuite) TestVersion() {
+	w := suite.serve("/version")
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"version":"1.4.0","commit":"c2e50e1","ruleVersion":"2024-promo","goVersion":"`+runtime.Version()+`"}`, w.Body.String())
+}
+
+func TestHealthHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthHandlerTestSuite))
+}
@@ -0,0 +1,19 @@
+package health
+
+type ExtHealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+type ExtReadinessResponse struct {
+	// Status is ready, or unavailable if a check failed
+	Status string `json:"status" example:"ready"`
+	// Checks maps each dependency checked to ok or unavailable, the reason a check failed is only logged
+	Checks map[string]string `json:"checks"`
+}
+
+type ExtVersionResponse struct {
+	Version     string `json:"version" example:"1.4.0"`
+	Commit      string `json:"commit" example:"c2e50e1"`
+	RuleVersion string `json:"ruleVersion" example:"1"`
+	GoVersion   string `json:"goVersion" example:"go1.22.5"`
+}
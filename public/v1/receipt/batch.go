@@ -0,0 +1,116 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	receiptSvc "receipt-processor/services/receipt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// processMethod dispatches custom methods such as POST /receipts/process:batch.
+// gin cannot match a literal colon, so they share the /receipts/process:method route.
+func processMethod(c *gin.Context) {
+	switch c.Param("method") {
+	case ":batch":
+		ProcessReceiptBatch(c)
+	default:
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Not Found"})
+	}
+}
+
+// ProcessReceiptBatch godoc
+// @Summary Submits several receipts for processing at once
+// @Description Validates, scores and stores every receipt independently. Invalid or malformed receipts do not prevent the others from being processed, each result reports either the ID and points or the error of its receipt.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param receipts body []models.ExtReceipt true "Receipts"
+// @Param Idempotency-Key header string false "Retrying with the same key returns the original response instead of processing the batch again"
+// @Success 200 {object} ExtBatchProcessResponse "Batch processed, see each result"
+// @Failure 400 {object} models.ErrorResponse "Request body is not an array of receipts"
+// @Failure 413 {object} models.ErrorResponse "Batch has more receipts than allowed, or the request body is larger than allowed"
+// @Router /receipts/process:batch [post]
+func ProcessReceiptBatch(c *gin.Context) {
+	// Receipts are decoded one by one so that a malformed receipt fails alone instead of the whole batch
+	var rawReceipts []json.RawMessage
+	if err := c.ShouldBindJSON(&rawReceipts); err != nil {
+		errs := models.ValidationErrors{decodeError(err)}
+		logging.WriteError(c, rejectionStatus(errs), models.ErrorResponse{Error: "Invalid batch", Details: errs})
+		return
+	}
+	if len(rawReceipts) == 0 {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Batch must contain at least one receipt"})
+		return
+	}
+	if len(rawReceipts) > settings.maxBatchSize {
+		logging.WriteError(c, http.StatusRequestEntityTooLarge,
+			models.ErrorResponse{Error: fmt.Sprintf("Batch may contain at most %d receipts", settings.maxBatchSize)})
+		return
+	}
+
+	response := ExtBatchProcessResponse{Results: make([]ExtBatchResult, len(rawReceipts))}
+	extReceipts := make([]models.ExtReceipt, 0, len(rawReceipts))
+	indexes := make([]int, 0, len(rawReceipts))
+	for i, raw := range rawReceipts {
+		var extReceipt models.ExtReceipt
+		if err := json.Unmarshal(raw, &extReceipt); err != nil {
+			errs := models.ValidationErrors{decodeError(err)}
+			observeRejection(errs)
+			response.Results[i] = ExtBatchResult{Index: i, Status: BatchStatusFailed, Error: &models.ErrorResponse{Error: "Invalid receipt", Details: errs}}
+			response.Failed++
+			continue
+		}
+		extReceipts = append(extReceipts, extReceipt)
+		indexes = append(indexes, i)
+	}
+
+	if len(extReceipts) > 0 {
+		for i, result := range receiptService.ProcessReceipts(c.Request.Context(), extReceipts) {
+			entry := ExtBatchResult{Index: indexes[i]}
+			if result.Err != nil {
+				var validationErrs models.ValidationErrors
+				if errors.As(result.Err, &validationErrs) {
+					observeRejection(validationErrs)
+				}
+				entry.Status = BatchStatusFailed
+				entry.Error = batchError(c, result.Err)
+				response.Failed++
+			} else {
+				entry.Status = BatchStatusProcessed
+				entry.ID = result.ID
+				entry.Points = &result.Points
+				response.Processed++
+			}
+			response.Results[entry.Index] = entry
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// batchError describes why a receipt of a batch failed. Unexpected errors are recorded on the request for the log.
+func batchError(c *gin.Context, err error) *models.ErrorResponse {
+	var validationErrs models.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return &models.ErrorResponse{Error: "Invalid receipt", Details: validationErrs}
+	}
+	if errors.Is(err, models.ErrInvalidAmount) {
+		return &models.ErrorResponse{Error: err.Error()}
+	}
+	if errors.Is(err, receiptSvc.ErrForeignAccount) {
+		return &models.ErrorResponse{Error: "accountId must be the ID of the authenticated client"}
+	}
+	var duplicate *receiptSvc.DuplicateReceiptError
+	if errors.As(err, &duplicate) && duplicate.Pending {
+		return &models.ErrorResponse{Error: "Receipt was already submitted as " + duplicate.ExistingID + " and is still being processed, retry later"}
+	}
+	if errors.As(err, &duplicate) {
+		return &models.ErrorResponse{Error: "Receipt was already submitted as " + duplicate.ExistingID}
+	}
+	c.Error(err)
+	return &models.ErrorResponse{Error: "Error processing receipt"}
+}
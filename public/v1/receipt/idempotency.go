@@ -0,0 +1,159 @@
+package receipt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"receipt-processor/auth"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader lets a client retry a request without it being applied twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyEntry is the outcome of the first request made with a key
+type idempotencyEntry struct {
+	bodyHash    [32]byte
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyCache remembers responses by Idempotency-Key for a time window
+type idempotencyCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	now     func() time.Time
+	entries map[string]*idempotencyEntry
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin returns the entry of a key seen within the window, or claims the key and returns nil
+func (ic *idempotencyCache) begin(key string, bodyHash [32]byte) *idempotencyEntry {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := ic.now()
+	if entry, ok := ic.entries[key]; ok && now.Before(entry.expires) {
+		copied := *entry
+		return &copied
+	}
+
+	// Drop expired keys while holding the lock anyway
+	for k, entry := range ic.entries {
+		if !now.Before(entry.expires) {
+			delete(ic.entries, k)
+		}
+	}
+	ic.entries[key] = &idempotencyEntry{bodyHash: bodyHash, expires: now.Add(ic.window)}
+	return nil
+}
+
+// finish records the response of a claimed key, or forgets the key if the request
+// failed on the server side so that a retry is processed again
+func (ic *idempotencyCache) finish(key string, status int, contentType string, body []byte) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	entry, ok := ic.entries[key]
+	if !ok {
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		delete(ic.entries, key)
+		return
+	}
+	entry.done = true
+	entry.status = status
+	entry.contentType = contentType
+	entry.body = body
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent replays the original response when a request is repeated with the same
+// Idempotency-Key header within the window. Requests without the header are not affected.
+func idempotent(cache *idempotencyCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		// Keys are scoped to the authenticated client, so a client never gets the response of another.
+		// Header values hold no newline, which keeps the scoped keys of different clients apart.
+		if client, ok := auth.FromContext(c.Request.Context()); ok {
+			key = client.ID + "\n" + key
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		bodyHash := sha256.Sum256(body)
+
+		if entry := cache.begin(key, bodyHash); entry != nil {
+			switch {
+			case entry.bodyHash != bodyHash:
+				logging.WriteError(c, http.StatusUnprocessableEntity,
+					models.ErrorResponse{Error: "Idempotency-Key was already used with a different request body"})
+				c.Abort()
+			case !entry.done:
+				logging.WriteError(c, http.StatusConflict,
+					models.ErrorResponse{Error: "A request with this Idempotency-Key is still being processed"})
+				c.Abort()
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(entry.status, entry.contentType, entry.body)
+				c.Abort()
+			}
+			return
+		}
+
+		// Release the key if a handler panics, so the client can retry
+		finished := false
+		defer func() {
+			if !finished {
+				cache.finish(key, http.StatusInternalServerError, "", nil)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		cache.finish(key, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		finished = true
+	}
+}
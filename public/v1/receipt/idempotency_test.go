@@ -0,0 +1,75 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/auth"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyCacheExpires(t *testing.T) {
+	now := time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC)
+	cache := newIdempotencyCache(time.Hour)
+	cache.now = func() time.Time { return now }
+	hash := sha256.Sum256([]byte("body"))
+
+	require.Nil(t, cache.begin("key", hash))
+
+	// A second request while the first is running sees an unfinished entry
+	entry := cache.begin("key", hash)
+	require.NotNil(t, entry)
+	require.False(t, entry.done)
+
+	cache.finish("key", http.StatusOK, "application/json", []byte(`{"id":"a"}`))
+	entry = cache.begin("key", hash)
+	require.True(t, entry.done)
+	require.Equal(t, `{"id":"a"}`, string(entry.body))
+
+	// After the window the key can be used again
+	now = now.Add(time.Hour)
+	require.Nil(t, cache.begin("key", hash))
+}
+
+func TestIdempotencyCacheForgetsServerErrors(t *testing.T) {
+	cache := newIdempotencyCache(time.Hour)
+	hash := sha256.Sum256([]byte("body"))
+
+	require.Nil(t, cache.begin("key", hash))
+	cache.finish("key", http.StatusInternalServerError, "application/json", nil)
+
+	// The retry is processed again
+	require.Nil(t, cache.begin("key", hash))
+}
+
+func TestIdempotencyKeysOfClient(t *testing.T) {
+	router := gin.New()
+	calls := 0
+	// Stands in for the authentication middleware, with the client named by a header
+	router.Use(func(c *gin.Context) {
+		client := auth.Client{ID: c.GetHeader("X-Client")}
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), client))
+	}, idempotent(newIdempotencyCache(time.Hour)))
+	router.POST("/", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"call": calls})
+	})
+	post := func(client string) string {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		req.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	require.JSONEq(t, `{"call": 1}`, post("acme"))
+	require.JSONEq(t, `{"call": 1}`, post("acme"))
+	// Another client using the same key does not get the response of the first
+	require.JSONEq(t, `{"call": 2}`, post("globex"))
+}
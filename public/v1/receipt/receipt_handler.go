@@ -3,63 +3,126 @@ package receipt
 import (
 	"errors"
 	"net/http"
+	"receipt-processor/logging"
 	"receipt-processor/models"
 	"receipt-processor/repo"
 	receiptSvc "receipt-processor/services/receipt"
+	"strconv"
+	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-var receiptService receiptSvc.ReceiptService
+var (
+	receiptService receiptSvc.ReceiptService
+	settings       handlerConfig
+)
+
+// handlerConfig holds the settings applied by Register options
+type handlerConfig struct {
+	idempotencyWindow time.Duration
+	maxBatchSize      int
+	validation        ValidationObserver
+}
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// ValidationObserver is told about every rejected receipt, for example to export metrics
+type ValidationObserver interface {
+	// ReceiptRejected is called with every problem found in a receipt that failed validation
+	ReceiptRejected(errs models.ValidationErrors)
+}
+
+// Option customizes the routes set up by Register
+type Option func(*handlerConfig)
+
+// WithIdempotencyWindow sets how long a response is replayed for a repeated Idempotency-Key.
+// Defaults to 24 hours, zero disables Idempotency-Key support.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(hc *handlerConfig) {
+		hc.idempotencyWindow = window
+	}
+}
+
+// WithMaxBatchSize sets how many receipts POST /receipts/process:batch accepts. Defaults to 100.
+func WithMaxBatchSize(n int) Option {
+	return func(hc *handlerConfig) {
+		hc.maxBatchSize = n
+	}
+}
+
+// WithValidationObserver tells the observer about every receipt that fails validation,
+// whether submitted alone, in a batch or for scoring
+func WithValidationObserver(o ValidationObserver) Option {
+	return func(hc *handlerConfig) {
+		hc.validation = o
+	}
 }
 
 // Register router for the APIs
-func Register(router *gin.Engine, service receiptSvc.ReceiptService) {
+func Register(router gin.IRouter, service receiptSvc.ReceiptService, opts ...Option) {
 	receiptService = service
-	router.Use(cors.Default())
-
-	// Swagger for API docs
-	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	settings = handlerConfig{idempotencyWindow: 24 * time.Hour, maxBatchSize: 100}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	// Repeated requests with the same Idempotency-Key get the original response
+	withIdempotency := func(c *gin.Context) { c.Next() }
+	if settings.idempotencyWindow > 0 {
+		withIdempotency = idempotent(newIdempotencyCache(settings.idempotencyWindow))
+	}
 
 	// Define API routes
-	router.POST("/receipts/process", ProcessReceipt)
+	router.POST("/receipts/process", withIdempotency, ProcessReceipt)
+	router.POST("/receipts/process:method", withIdempotency, processMethod)
+	router.POST("/receipts/score", ScoreReceipt)
+	router.GET("/receipts", ListReceipts)
+	router.GET("/receipts/:id", GetReceipt)
+	router.DELETE("/receipts/:id", DeleteReceipt)
 	router.GET("/receipts/:id/points", GetPoints)
-
-	// Custom 404 handler
-	router.NoRoute(func(c *gin.Context) {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found"})
-	})
+	router.GET("/receipts/:id/points/breakdown", GetPointsBreakdown)
+	router.POST("/admin/receipts/rescore", RescoreReceipts)
 }
 
 // ProcessReceipt godoc
 // @Summary Submits a receipt for processing and returns an ID
-// @Description Receives a receipt in JSON format and processes it, returning a unique ID for the receipt.
+// @Description Receives a receipt in JSON format and processes it, returning a unique ID for the receipt. With async=true the receipt is queued and processed in the background: the response is 202 with the ID and the pending status, and GET /receipts/{id} reports when it is processed or failed.
 // @Tags receipts
 // @Accept json
 // @Produce json
 // @Param receipt body models.ExtReceipt true "Receipt data"
+// @Param async query bool false "Queue the receipt instead of processing it before responding"
+// @Param Idempotency-Key header string false "Retrying with the same key returns the original response instead of processing the receipt again"
 // @Success 200 {object} ExtProcessReceiptResponse "Receipt processed successfully"
-// @Failure 400 {object} ErrorResponse "Invalid request body"
-// @Failure 500 {object} ErrorResponse "Error processing receipt"
+// @Success 202 {object} ExtProcessReceiptResponse "Receipt queued for processing"
+// @Failure 400 {object} models.ErrorResponse "Invalid request body, details lists every invalid field"
+// @Failure 403 {object} models.ErrorResponse "accountId is the account of another client"
+// @Failure 413 {object} models.ErrorResponse "Request body is larger than allowed"
+// @Failure 409 {object} ExtDuplicateReceiptResponse "Receipt was already submitted, or is still being processed in return-existing mode, or a request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} models.ErrorResponse "Idempotency-Key was already used with a different receipt"
+// @Failure 500 {object} models.ErrorResponse "Error processing receipt"
+// @Failure 503 {object} models.ErrorResponse "Processing queue is full or shutting down, retry later"
 // @Router /receipts/process [post]
 func ProcessReceipt(c *gin.Context) {
 	var extReceipt models.ExtReceipt
 
-	// Parse JSON body
-	if err := c.ShouldBindJSON(&extReceipt); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
+	if err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "async must be true or false"})
+		return
+	}
+
+	// Parse and validate JSON body
+	if errs := bindReceipt(c, &extReceipt); len(errs) > 0 {
+		logging.WriteError(c, rejectionStatus(errs), models.ErrorResponse{Error: "Invalid receipt", Details: errs})
 		return
 	}
 
-	id, err := receiptService.ProcessReceipt(extReceipt)
+	if async {
+		submitReceipt(c, extReceipt)
+		return
+	}
+	id, err := receiptService.ProcessReceipt(c.Request.Context(), extReceipt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Error processing receipt"})
+		respondProcessError(c, err)
 		return
 	}
 
@@ -67,6 +130,95 @@ func ProcessReceipt(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// submitReceipt queues a receipt for background processing
+func submitReceipt(c *gin.Context, extReceipt models.ExtReceipt) {
+	job, err := receiptService.SubmitReceipt(c.Request.Context(), extReceipt)
+	if err != nil {
+		switch {
+		case errors.Is(err, receiptSvc.ErrAsyncDisabled):
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Asynchronous processing is disabled"})
+		case errors.Is(err, receiptSvc.ErrQueueFull), errors.Is(err, receiptSvc.ErrDraining):
+			c.Header("Retry-After", "1")
+			logging.WriteError(c, http.StatusServiceUnavailable, models.ErrorResponse{Error: "Processing queue is unavailable, retry later"})
+		default:
+			respondProcessError(c, err)
+		}
+		return
+	}
+
+	// A duplicate in return-existing mode may already be processed
+	status := http.StatusOK
+	if job.Status == receiptSvc.JobPending {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, ExtProcessReceiptResponse{ID: job.ID, Status: string(job.Status)})
+}
+
+// respondProcessError maps an error of processing or submitting a receipt to its response
+func respondProcessError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrInvalidAmount) {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, receiptSvc.ErrForeignAccount) {
+		logging.WriteError(c, http.StatusForbidden, models.ErrorResponse{Error: "accountId must be the ID of the authenticated client"})
+		return
+	}
+	var duplicate *receiptSvc.DuplicateReceiptError
+	if errors.As(err, &duplicate) {
+		if duplicate.Pending {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusConflict, ExtDuplicateReceiptResponse{Error: "Receipt was already submitted and is still being processed, retry later", ID: duplicate.ExistingID, RequestID: logging.RequestID(c)})
+			return
+		}
+		c.JSON(http.StatusConflict, ExtDuplicateReceiptResponse{Error: "Receipt was already submitted", ID: duplicate.ExistingID, RequestID: logging.RequestID(c)})
+		return
+	}
+	c.Error(err)
+	logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Error processing receipt"})
+}
+
+// ScoreReceipt godoc
+// @Summary Previews the points of a receipt without storing it
+// @Description Validates a receipt and scores it with the current rules and running campaigns, returning the points and the rule by rule breakdown. Nothing is stored, no ID is assigned and no account is credited.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param receipt body models.ExtReceipt true "Receipt data"
+// @Success 200 {object} ExtScoreReceiptResponse "Receipt scored successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid request body, details lists every invalid field"
+// @Failure 413 {object} models.ErrorResponse "Request body is larger than allowed"
+// @Failure 500 {object} models.ErrorResponse "Error scoring receipt"
+// @Router /receipts/score [post]
+func ScoreReceipt(c *gin.Context) {
+	var extReceipt models.ExtReceipt
+
+	// Parse and validate JSON body
+	if errs := bindReceipt(c, &extReceipt); len(errs) > 0 {
+		logging.WriteError(c, rejectionStatus(errs), models.ErrorResponse{Error: "Invalid receipt", Details: errs})
+		return
+	}
+
+	result, err := receiptService.ScoreReceipt(c.Request.Context(), extReceipt)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidAmount) {
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Error scoring receipt"})
+		return
+	}
+
+	response := ExtScoreReceiptResponse{
+		Points:      result.Breakdown.Total,
+		RuleVersion: result.RuleVersion,
+		Rules:       result.Breakdown.Rules,
+		Campaigns:   result.Campaigns,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // GetPoints godoc
 // @Summary Retrieves points associated with a receipt by ID
 // @Description Fetches the points linked to a receipt using its unique ID.
@@ -75,21 +227,22 @@ func ProcessReceipt(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Receipt ID"
 // @Success 200 {object} ExtGetPointsResponse "Points retrieved successfully"
-// @Failure 404 {object} ErrorResponse "Receipt not found"
-// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 404 {object} models.ErrorResponse "Receipt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /receipts/{id}/points [get]
 func GetPoints(c *gin.Context) {
 	id := c.Param("id")
 
-	points, err := receiptService.GetPoints(id)
+	points, err := receiptService.GetPoints(c.Request.Context(), id)
 	if err != nil {
 		// Check if the error is a "not found" error
 		if errors.Is(err, repo.ErrNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Receipt not found"})
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Receipt not found"})
 			return
 		}
 		// Handle all other errors as internal server errors
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error"})
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
 		return
 	}
 
@@ -97,3 +250,158 @@ func GetPoints(c *gin.Context) {
 	response := ExtGetPointsResponse{Points: points}
 	c.JSON(http.StatusOK, response)
 }
+
+// GetPointsBreakdown godoc
+// @Summary Explains the points awarded to a receipt rule by rule
+// @Description Lists every scoring rule with whether it matched, the receipt fields it looked at and the points it awarded. The points add up to the receipt's total, unless the rule set that awarded them is no longer known: the current rules are explained instead and recomputed is set.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param id path string true "Receipt ID"
+// @Success 200 {object} ExtGetPointsBreakdownResponse "Points breakdown retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Receipt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /receipts/{id}/points/breakdown [get]
+func GetPointsBreakdown(c *gin.Context) {
+	id := c.Param("id")
+
+	breakdown, err := receiptService.GetPointsBreakdown(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Receipt not found"})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	response := ExtGetPointsBreakdownResponse{Points: breakdown.Total, Rules: breakdown.Rules, Recomputed: breakdown.Recomputed}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetReceipt godoc
+// @Summary Retrieves a stored receipt by ID
+// @Description Returns the receipt as it was submitted together with the points it was awarded and the processed status. Receipts submitted with async=true that are still pending, or failed to process, are reported as an ExtReceiptStatusResponse with only their id, status and error.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param id path string true "Receipt ID"
+// @Success 200 {object} ExtReceiptResponse "Receipt retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Receipt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /receipts/{id} [get]
+func GetReceipt(c *gin.Context) {
+	id := c.Param("id")
+
+	receiptData, err := receiptService.GetReceipt(c.Request.Context(), id)
+	if errors.Is(err, repo.ErrNotFound) {
+		// The receipt may have been submitted for background processing
+		var job receiptSvc.Job
+		if job, err = receiptService.GetJob(c.Request.Context(), id); err == nil {
+			respondJob(c, job)
+			return
+		}
+	}
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Receipt not found"})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newExtReceiptResponse(receiptData))
+}
+
+// respondJob reports a receipt submitted for background processing
+func respondJob(c *gin.Context, job receiptSvc.Job) {
+	switch job.Status {
+	case receiptSvc.JobProcessed:
+		c.JSON(http.StatusOK, newExtReceiptResponse(job.Data))
+	case receiptSvc.JobFailed:
+		c.JSON(http.StatusOK, ExtReceiptStatusResponse{ID: job.ID, Status: string(job.Status), Error: "Error processing receipt"})
+	default:
+		c.JSON(http.StatusOK, ExtReceiptStatusResponse{ID: job.ID, Status: string(job.Status)})
+	}
+}
+
+// ListReceipts godoc
+// @Summary Lists stored receipts
+// @Description Returns stored receipts ordered by ID, one page at a time. Pass the nextCursor of a response as cursor to get the following page, it is omitted on the last page.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param cursor query string false "Cursor of the page to return"
+// @Param limit query int false "Number of receipts per page, 1 to 100" default(20)
+// @Param retailer query string false "Only receipts from this retailer, ignoring case"
+// @Param purchaseDateFrom query string false "Only receipts purchased on or after this yyyy-mm-dd date"
+// @Param purchaseDateTo query string false "Only receipts purchased on or before this yyyy-mm-dd date"
+// @Param minPoints query int false "Only receipts awarded at least this many points"
+// @Param maxPoints query int false "Only receipts awarded at most this many points"
+// @Param ruleVersion query string false "Only receipts scored with this rule set version"
+// @Param retailerId query string false "Only receipts matched to this canonical retailer, receipts that matched none if empty"
+// @Success 200 {object} ExtListReceiptsResponse "Receipts retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid query parameters, details lists every invalid parameter"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /receipts [get]
+func ListReceipts(c *gin.Context) {
+	query, errs := parseListQuery(c)
+	if len(errs) > 0 {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid query parameters", Details: errs})
+		return
+	}
+
+	page, err := receiptService.ListReceipts(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, receiptSvc.ErrInvalidCursor) {
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid query parameters", Details: []models.FieldError{
+				{Field: "cursor", Code: models.CodePattern, Message: "cursor was not returned by a previous listing"},
+			}})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	response := ExtListReceiptsResponse{Receipts: make([]ExtReceiptResponse, 0, len(page.Receipts)), NextCursor: page.NextCursor}
+	for _, receiptData := range page.Receipts {
+		response.Receipts = append(response.Receipts, newExtReceiptResponse(receiptData))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteReceipt godoc
+// @Summary Deletes a stored receipt by ID
+// @Description Removes the receipt and reverses the points credited to its account. Its contents no longer count as a duplicate submission.
+// @Tags receipts
+// @Accept json
+// @Produce json
+// @Param id path string true "Receipt ID"
+// @Success 204 "Receipt deleted"
+// @Failure 404 {object} models.ErrorResponse "Receipt not found"
+// @Failure 409 {object} models.ErrorResponse "The points of the receipt were already redeemed"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /receipts/{id} [delete]
+func DeleteReceipt(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := receiptService.DeleteReceipt(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Receipt not found"})
+			return
+		}
+		if errors.Is(err, repo.ErrInsufficientFunds) {
+			logging.WriteError(c, http.StatusConflict, models.ErrorResponse{Error: "The points of the receipt were already redeemed"})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
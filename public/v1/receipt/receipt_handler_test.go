@@ -2,12 +2,17 @@ package receipt
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"receipt-processor/logging"
 	"receipt-processor/models"
 	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -20,16 +25,66 @@ type MockReceiptService struct {
 	mock.Mock
 }
 
-func (m *MockReceiptService) ProcessReceipt(extReceipt models.ExtReceipt) (string, error) {
+func (m *MockReceiptService) ProcessReceipt(ctx context.Context, extReceipt models.ExtReceipt) (string, error) {
 	args := m.Called(extReceipt)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockReceiptService) GetPoints(id string) (int64, error) {
+func (m *MockReceiptService) SubmitReceipt(ctx context.Context, extReceipt models.ExtReceipt) (receiptSvc.Job, error) {
+	args := m.Called(extReceipt)
+	return args.Get(0).(receiptSvc.Job), args.Error(1)
+}
+
+func (m *MockReceiptService) GetJob(ctx context.Context, id string) (receiptSvc.Job, error) {
+	args := m.Called(id)
+	return args.Get(0).(receiptSvc.Job), args.Error(1)
+}
+
+func (m *MockReceiptService) Drain(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockReceiptService) ScoreReceipt(ctx context.Context, extReceipt models.ExtReceipt) (receiptSvc.ScoreResult, error) {
+	args := m.Called(extReceipt)
+	return args.Get(0).(receiptSvc.ScoreResult), args.Error(1)
+}
+
+func (m *MockReceiptService) GetPoints(ctx context.Context, id string) (int64, error) {
 	args := m.Called(id)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockReceiptService) GetPointsBreakdown(ctx context.Context, id string) (models.PointsBreakdown, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.PointsBreakdown), args.Error(1)
+}
+
+func (m *MockReceiptService) ProcessReceipts(ctx context.Context, extReceipts []models.ExtReceipt) []receiptSvc.BatchResult {
+	args := m.Called(extReceipts)
+	return args.Get(0).([]receiptSvc.BatchResult)
+}
+
+func (m *MockReceiptService) GetReceipt(ctx context.Context, id string) (repo.ReceiptData, error) {
+	args := m.Called(id)
+	return args.Get(0).(repo.ReceiptData), args.Error(1)
+}
+
+func (m *MockReceiptService) ListReceipts(ctx context.Context, q receiptSvc.ListQuery) (receiptSvc.ReceiptPage, error) {
+	args := m.Called(q)
+	return args.Get(0).(receiptSvc.ReceiptPage), args.Error(1)
+}
+
+func (m *MockReceiptService) DeleteReceipt(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockReceiptService) Rescore(ctx context.Context, q receiptSvc.RescoreQuery) (receiptSvc.RescoreReport, error) {
+	args := m.Called(q)
+	return args.Get(0).(receiptSvc.RescoreReport), args.Error(1)
+}
+
 // ReceiptHandlerTestSuite defines the suite for handler tests
 type ReceiptHandlerTestSuite struct {
 	suite.Suite
@@ -40,9 +95,6 @@ type ReceiptHandlerTestSuite struct {
 
 // SetupTest initializes the suite
 func (suite *ReceiptHandlerTestSuite) SetupTest() {
-	// Reset the storage
-	repo.Receipts = make(map[string]repo.ReceiptData)
-
 	// Initialize the mock service
 	suite.mockService = new(MockReceiptService)
 
@@ -57,7 +109,7 @@ func (suite *ReceiptHandlerTestSuite) SetupTest() {
 		Retailer:     "Target",
 		PurchaseDate: "2022-01-01",
 		PurchaseTime: "13:01",
-		Items: []models.Item{
+		Items: []models.ExtItem{
 			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
 			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
 			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
@@ -87,6 +139,163 @@ func (suite *ReceiptHandlerTestSuite) TestProcessReceipt() {
 	suite.mockService.AssertCalled(suite.T(), "ProcessReceipt", suite.mockExtReceipt)
 }
 
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptAsync() {
+	suite.mockService.On("SubmitReceipt", suite.mockExtReceipt).
+		Return(receiptSvc.Job{ID: "mock-receipt-id", Status: receiptSvc.JobPending}, nil).Once()
+
+	req := httptest.NewRequest("POST", "/receipts/process?async=true", generateJSONBody(suite.mockExtReceipt))
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusAccepted, w.Code)
+	suite.JSONEq(`{"id":"mock-receipt-id","status":"pending"}`, w.Body.String())
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipt", mock.Anything)
+
+	// Backpressure when the queue is full
+	suite.mockService.On("SubmitReceipt", suite.mockExtReceipt).Return(receiptSvc.Job{}, receiptSvc.ErrQueueFull).Once()
+	req = httptest.NewRequest("POST", "/receipts/process?async=true", generateJSONBody(suite.mockExtReceipt))
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusServiceUnavailable, w.Code)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+
+	req = httptest.NewRequest("POST", "/receipts/process?async=maybe", generateJSONBody(suite.mockExtReceipt))
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptInvalidReceipt() {
+	// Break several fields at once
+	suite.mockExtReceipt.PurchaseDate = "2022/13/45"
+	suite.mockExtReceipt.PurchaseTime = "25:99"
+	suite.mockExtReceipt.Items[0].Price = "abc"
+
+	// Create a request
+	req := httptest.NewRequest("POST", "/receipts/process", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal([]models.FieldError{
+		{Field: "purchaseDate", Code: models.CodeInvalidDate, Message: `purchaseDate "2022/13/45" is not a calendar date in yyyy-mm-dd format`},
+		{Field: "purchaseTime", Code: models.CodeInvalidTime, Message: `purchaseTime "25:99" is not a 24-hour time in hh:mm format`},
+		{Field: "items[0].price", Code: models.CodePattern, Message: `"abc" is not a dollar amount with two decimals like 6.49`},
+	}, response.Details)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipt", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptMalformedJSON() {
+	// Create a request
+	req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader([]byte(`{"retailer": 42}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Details, 1)
+	suite.Equal("retailer", response.Details[0].Field)
+	suite.Equal(models.CodeInvalidType, response.Details[0].Code)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipt", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptBodyTooLarge() {
+	// Create a request of unknown length, limited like the server limits bodies
+	req := httptest.NewRequest("POST", "/receipts/process", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(w, req.Body, 16)
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Details, 1)
+	suite.Equal(models.CodeBodyTooLarge, response.Details[0].Code)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipt", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptIdempotencyKey() {
+	// Set up mock expectations, a second call would mint another ID
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).Return("first-id", nil).Once()
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).Return("second-id", nil).Once()
+
+	send := func(receipt models.ExtReceipt) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/receipts/process", generateJSONBody(receipt))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "retry-key")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The retry replays the original response
+	first := send(suite.mockExtReceipt)
+	retry := send(suite.mockExtReceipt)
+	suite.Equal(http.StatusOK, first.Code)
+	suite.Equal(http.StatusOK, retry.Code)
+	suite.Equal(first.Body.String(), retry.Body.String())
+	suite.Contains(retry.Body.String(), "first-id")
+	suite.Equal("true", retry.Header().Get("Idempotent-Replayed"))
+	suite.mockService.AssertNumberOfCalls(suite.T(), "ProcessReceipt", 1)
+
+	// Reusing the key for another receipt is rejected
+	other := suite.mockExtReceipt
+	other.Retailer = "Walgreens"
+	suite.Equal(http.StatusUnprocessableEntity, send(other).Code)
+	suite.mockService.AssertNumberOfCalls(suite.T(), "ProcessReceipt", 1)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptDuplicate() {
+	// Set up mock expectations
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).
+		Return("", &receiptSvc.DuplicateReceiptError{ExistingID: "existing-id"})
+
+	// Create a request
+	req := httptest.NewRequest("POST", "/receipts/process", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusConflict, w.Code)
+	suite.Contains(w.Body.String(), `"id":"existing-id"`)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptDuplicatePending() {
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).
+		Return("", &receiptSvc.DuplicateReceiptError{ExistingID: "existing-id", Pending: true})
+
+	req := httptest.NewRequest("POST", "/receipts/process", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	// The receipt duplicated is still being stored, so the client may retry
+	suite.Equal(http.StatusConflict, w.Code)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+	suite.Contains(w.Body.String(), `"id":"existing-id"`)
+}
+
 func (suite *ReceiptHandlerTestSuite) TestGetPoints() {
 	// Set up mock expectations
 	mockID := "mock-receipt-id"
@@ -106,7 +315,469 @@ func (suite *ReceiptHandlerTestSuite) TestGetPoints() {
 	suite.mockService.AssertCalled(suite.T(), "GetPoints", mockID)
 }
 
+func (suite *ReceiptHandlerTestSuite) TestScoreReceipt() {
+	// Set up mock expectations
+	result := receiptSvc.ScoreResult{
+		RuleVersion: "1",
+		Breakdown: models.PointsBreakdown{
+			Total: 6,
+			Rules: []models.RuleResult{{Rule: "retailer_alphanumeric", Matched: true, Inputs: map[string]any{"retailer": "Target"}, Points: 6}},
+		},
+	}
+	suite.mockService.On("ScoreReceipt", suite.mockExtReceipt).Return(result, nil)
+
+	// Create a request
+	req := httptest.NewRequest("POST", "/receipts/score", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtScoreReceiptResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(int64(6), response.Points)
+	suite.Equal("1", response.RuleVersion)
+	suite.Len(response.Rules, 1)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipt", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestScoreReceiptInvalidReceipt() {
+	suite.mockExtReceipt.PurchaseDate = "2022/13/45"
+
+	req := httptest.NewRequest("POST", "/receipts/score", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Contains(w.Body.String(), "purchaseDate")
+	suite.mockService.AssertNotCalled(suite.T(), "ScoreReceipt", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetPointsBreakdown() {
+	// Set up mock expectations
+	mockID := "mock-receipt-id"
+	mockBreakdown := models.PointsBreakdown{
+		Total: 6,
+		Rules: []models.RuleResult{
+			{Rule: "retailer_alphanumeric", Matched: true, Inputs: map[string]any{"retailer": "Target"}, Points: 6},
+			{Rule: "round_dollar_total", Matched: false, Inputs: map[string]any{"total": "35.35"}, Points: 0},
+		},
+	}
+	suite.mockService.On("GetPointsBreakdown", mockID).Return(mockBreakdown, nil)
+
+	// Create a request
+	req := httptest.NewRequest("GET", "/receipts/"+mockID+"/points/breakdown", nil)
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtGetPointsBreakdownResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(int64(6), response.Points)
+	suite.Len(response.Rules, 2)
+	suite.Equal("retailer_alphanumeric", response.Rules[0].Rule)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetPointsBreakdownRecomputed() {
+	// Set up mock expectations
+	mockID := "mock-receipt-id"
+	mockBreakdown := models.PointsBreakdown{Total: 6, Recomputed: true}
+	suite.mockService.On("GetPointsBreakdown", mockID).Return(mockBreakdown, nil)
+
+	// Create a request
+	req := httptest.NewRequest("GET", "/receipts/"+mockID+"/points/breakdown", nil)
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtGetPointsBreakdownResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.True(response.Recomputed)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetPointsBreakdownNotFound() {
+	// Set up mock expectations
+	mockID := "missing-id"
+	suite.mockService.On("GetPointsBreakdown", mockID).Return(models.PointsBreakdown{}, repo.ErrNotFound)
+
+	// Create a request
+	req := httptest.NewRequest("GET", "/receipts/"+mockID+"/points/breakdown", nil)
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
 // generateJSONBody creates an io.Reader containing the JSON body for testing
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptBatch() {
+	invalid := suite.mockExtReceipt
+	invalid.Retailer = ""
+	batch := []models.ExtReceipt{suite.mockExtReceipt, invalid}
+	suite.mockService.On("ProcessReceipts", batch).Return([]receiptSvc.BatchResult{
+		{ID: "mock-receipt-id", Points: 28},
+		{Err: invalid.Validate()},
+	})
+
+	// Create a request
+	body, _ := json.Marshal(batch)
+	req := httptest.NewRequest("POST", "/receipts/process:batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtBatchProcessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(1, response.Processed)
+	suite.Equal(1, response.Failed)
+	points := int64(28)
+	suite.Equal(ExtBatchResult{Index: 0, Status: BatchStatusProcessed, ID: "mock-receipt-id", Points: &points}, response.Results[0])
+	suite.Equal(BatchStatusFailed, response.Results[1].Status)
+	suite.Require().NotNil(response.Results[1].Error)
+	suite.Equal("retailer", response.Results[1].Error.Details[0].Field)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptBatchMalformedEntry() {
+	free := suite.mockExtReceipt
+	free.Retailer = "Free"
+	suite.mockService.On("ProcessReceipts", []models.ExtReceipt{suite.mockExtReceipt, free}).Return([]receiptSvc.BatchResult{
+		{ID: "mock-receipt-id", Points: 28},
+		{ID: "free-receipt-id", Points: 0},
+	})
+
+	// The second receipt has a wrongly typed field, the others are valid
+	valid, _ := json.Marshal(suite.mockExtReceipt)
+	freeBody, _ := json.Marshal(free)
+	body := fmt.Sprintf(`[%s, {"retailer": "Target", "items": "x"}, %s]`, valid, freeBody)
+	req := httptest.NewRequest("POST", "/receipts/process:batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtBatchProcessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(2, response.Processed)
+	suite.Equal(1, response.Failed)
+	suite.Require().Len(response.Results, 3)
+	points, noPoints := int64(28), int64(0)
+	suite.Equal(ExtBatchResult{Index: 0, Status: BatchStatusProcessed, ID: "mock-receipt-id", Points: &points}, response.Results[0])
+	suite.Equal(1, response.Results[1].Index)
+	suite.Equal(BatchStatusFailed, response.Results[1].Status)
+	suite.Require().NotNil(response.Results[1].Error)
+	suite.Equal("items", response.Results[1].Error.Details[0].Field)
+	suite.Equal(models.CodeInvalidType, response.Results[1].Error.Details[0].Code)
+	suite.Equal(ExtBatchResult{Index: 2, Status: BatchStatusProcessed, ID: "free-receipt-id", Points: &noPoints}, response.Results[2])
+
+	// Receipts worth no points still report them
+	suite.Contains(w.Body.String(), `"id":"free-receipt-id","points":0`)
+}
+
+// rejectionRecorder keeps the problems of rejected receipts
+type rejectionRecorder struct {
+	rejected []models.ValidationErrors
+}
+
+func (r *rejectionRecorder) ReceiptRejected(errs models.ValidationErrors) {
+	r.rejected = append(r.rejected, errs)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestErrorResponseRequestID() {
+	suite.router = gin.New()
+	suite.router.Use(logging.Middleware())
+	Register(suite.router, suite.mockService)
+	suite.mockService.On("GetPoints", "mock-receipt-id").Return(int64(0), errors.New("store unavailable"))
+	suite.mockService.On("GetPoints", "missing").Return(int64(0), repo.ErrNotFound)
+
+	req := httptest.NewRequest("GET", "/receipts/mock-receipt-id/points", nil)
+	req.Header.Set(logging.RequestIDHeader, "client-request-1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusInternalServerError, w.Code)
+	suite.JSONEq(`{"error": "Internal server error", "requestId": "client-request-1"}`, w.Body.String())
+
+	// Generated IDs are returned in the header and the body alike
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, httptest.NewRequest("GET", "/receipts/missing/points", nil))
+	var resp models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	suite.NotEmpty(resp.RequestID)
+	suite.Equal(w.Header().Get(logging.RequestIDHeader), resp.RequestID)
+
+	// Duplicates are reported with the request ID too
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).
+		Return("", &receiptSvc.DuplicateReceiptError{ExistingID: "existing-id"})
+	req = httptest.NewRequest("POST", "/receipts/process", generateJSONBody(suite.mockExtReceipt))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logging.RequestIDHeader, "client-request-2")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusConflict, w.Code)
+	suite.JSONEq(`{"error": "Receipt was already submitted", "id": "existing-id", "requestId": "client-request-2"}`, w.Body.String())
+}
+
+func (suite *ReceiptHandlerTestSuite) TestValidationObserver() {
+	recorder := &rejectionRecorder{}
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService, WithValidationObserver(recorder))
+	invalid := suite.mockExtReceipt
+	invalid.Retailer = ""
+	batch := []models.ExtReceipt{suite.mockExtReceipt, invalid}
+	suite.mockService.On("ProcessReceipt", suite.mockExtReceipt).Return("mock-receipt-id", nil)
+	suite.mockService.On("ProcessReceipts", batch).Return([]receiptSvc.BatchResult{
+		{ID: "mock-receipt-id", Points: 28},
+		{Err: invalid.Validate()},
+	})
+
+	for _, request := range []struct {
+		target string
+		body   any
+	}{
+		{"/receipts/process", suite.mockExtReceipt},
+		{"/receipts/process", invalid},
+		{"/receipts/score", invalid},
+		{"/receipts/process:batch", batch},
+	} {
+		body, _ := json.Marshal(request.body)
+		req := httptest.NewRequest("POST", request.target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		suite.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Every invalid receipt is reported once, valid ones are not
+	suite.Require().Len(recorder.rejected, 3)
+	for _, errs := range recorder.rejected {
+		suite.Equal("retailer", errs[0].Field)
+	}
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptBatchTooLarge() {
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService, WithMaxBatchSize(1))
+
+	// Create a request
+	body, _ := json.Marshal([]models.ExtReceipt{suite.mockExtReceipt, suite.mockExtReceipt})
+	req := httptest.NewRequest("POST", "/receipts/process:batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Serve the request
+	suite.router.ServeHTTP(w, req)
+
+	// Assertions
+	suite.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipts", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptBatchEmpty() {
+	req := httptest.NewRequest("POST", "/receipts/process:batch", bytes.NewBufferString("[]"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.mockService.AssertNotCalled(suite.T(), "ProcessReceipts", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestProcessReceiptUnknownMethod() {
+	req := httptest.NewRequest("POST", "/receipts/process:unknown", bytes.NewBufferString("[]"))
+	w := httptest.NewRecorder()
+
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetReceipt() {
+	receipt, _ := suite.mockExtReceipt.ToReceipt("mock-receipt-id")
+	suite.mockService.On("GetReceipt", "mock-receipt-id").Return(repo.ReceiptData{Receipt: receipt, Point: 28}, nil)
+
+	req := httptest.NewRequest("GET", "/receipts/mock-receipt-id", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtReceiptResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(ExtReceiptResponse{
+		ID:           "mock-receipt-id",
+		Retailer:     suite.mockExtReceipt.Retailer,
+		PurchaseDate: suite.mockExtReceipt.PurchaseDate,
+		PurchaseTime: suite.mockExtReceipt.PurchaseTime,
+		Items:        suite.mockExtReceipt.Items,
+		Total:        suite.mockExtReceipt.Total,
+		Points:       28,
+		Status:       "processed",
+	}, response)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetReceiptPending() {
+	suite.mockService.On("GetReceipt", mock.Anything).Return(repo.ReceiptData{}, repo.ErrNotFound)
+	suite.mockService.On("GetJob", "pending-id").Return(receiptSvc.Job{ID: "pending-id", Status: receiptSvc.JobPending}, nil)
+	suite.mockService.On("GetJob", "failed-id").
+		Return(receiptSvc.Job{ID: "failed-id", Status: receiptSvc.JobFailed, Err: errors.New("disk full")}, nil)
+
+	req := httptest.NewRequest("GET", "/receipts/pending-id", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"id":"pending-id","status":"pending"}`, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/receipts/failed-id", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"id":"failed-id","status":"failed","error":"Error processing receipt"}`, w.Body.String())
+}
+
+func (suite *ReceiptHandlerTestSuite) TestGetReceiptNotFound() {
+	suite.mockService.On("GetReceipt", "missing-id").Return(repo.ReceiptData{}, repo.ErrNotFound)
+	suite.mockService.On("GetJob", "missing-id").Return(receiptSvc.Job{}, repo.ErrNotFound)
+
+	req := httptest.NewRequest("GET", "/receipts/missing-id", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestListReceipts() {
+	receipt, _ := suite.mockExtReceipt.ToReceipt("mock-receipt-id")
+	receipt.RetailerID = "target"
+	minPoints := int64(10)
+	retailerID := "target"
+	query := receiptSvc.ListQuery{
+		Cursor: "abc",
+		Limit:  5,
+		Filter: repo.ListQuery{Retailer: "Target", PurchasedFrom: "2022-01-01", MinPoints: &minPoints, RetailerID: &retailerID},
+	}
+	suite.mockService.On("ListReceipts", query).Return(receiptSvc.ReceiptPage{
+		Receipts:   []repo.ReceiptData{{Receipt: receipt, Point: 28}},
+		NextCursor: "next",
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/receipts?cursor=abc&limit=5&retailer=Target&purchaseDateFrom=2022-01-01&minPoints=10&retailerId=target", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtListReceiptsResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal("next", response.NextCursor)
+	suite.Require().Len(response.Receipts, 1)
+	suite.Equal("mock-receipt-id", response.Receipts[0].ID)
+	suite.Equal("target", response.Receipts[0].RetailerID)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestListReceiptsInvalidQuery() {
+	req := httptest.NewRequest("GET", "/receipts?limit=0&purchaseDateTo=2022-02-30&maxPoints=many", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	var fields []string
+	for _, detail := range response.Details {
+		fields = append(fields, detail.Field)
+	}
+	suite.Equal([]string{"limit", "purchaseDateTo", "maxPoints"}, fields)
+	suite.mockService.AssertNotCalled(suite.T(), "ListReceipts", mock.Anything)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestListReceiptsInvalidCursor() {
+	suite.mockService.On("ListReceipts", mock.Anything).Return(receiptSvc.ReceiptPage{}, receiptSvc.ErrInvalidCursor)
+
+	req := httptest.NewRequest("GET", "/receipts?cursor=bogus", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestDeleteReceipt() {
+	suite.mockService.On("DeleteReceipt", "mock-receipt-id").Return(nil)
+	suite.mockService.On("DeleteReceipt", "missing-id").Return(repo.ErrNotFound)
+	suite.mockService.On("DeleteReceipt", "redeemed-id").Return(repo.ErrInsufficientFunds)
+
+	req := httptest.NewRequest("DELETE", "/receipts/mock-receipt-id", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/receipts/missing-id", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/receipts/redeemed-id", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusConflict, w.Code)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestRescoreReceipts() {
+	version := "1"
+	query := receiptSvc.RescoreQuery{Version: "2", Apply: true, Filter: repo.ListQuery{Retailer: "Target", RuleVersion: &version}}
+	suite.mockService.On("Rescore", query).Return(receiptSvc.RescoreReport{
+		Version: "2",
+		Applied: true,
+		Changed: 1,
+		Failed:  1,
+		Results: []receiptSvc.RescoreResult{
+			{ReceiptID: "a", OldVersion: "1", OldPoints: 28, NewPoints: 40, Delta: 12},
+			{ReceiptID: "b", OldVersion: "1", OldPoints: 28, NewPoints: 10, Delta: -18, Err: repo.ErrInsufficientFunds},
+		},
+		TotalDelta: 12,
+	}, nil)
+
+	req := httptest.NewRequest("POST", "/admin/receipts/rescore?version=2&mode=apply&retailer=Target&ruleVersion=1", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtRescoreResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(RescoreApply, response.Mode)
+	suite.Equal(int64(12), response.TotalDelta)
+	suite.Require().Len(response.Results, 2)
+	suite.Empty(response.Results[0].Error)
+	suite.NotEmpty(response.Results[1].Error)
+}
+
+func (suite *ReceiptHandlerTestSuite) TestRescoreReceiptsInvalid() {
+	suite.mockService.On("Rescore", receiptSvc.RescoreQuery{Version: "missing"}).
+		Return(receiptSvc.RescoreReport{}, receiptSvc.ErrUnknownRuleVersion)
+
+	for _, target := range []string{"/admin/receipts/rescore?mode=yolo", "/admin/receipts/rescore?version=missing"} {
+		req := httptest.NewRequest("POST", target, nil)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Equal(http.StatusBadRequest, w.Code, target)
+	}
+}
+
 func generateJSONBody(extReceipt models.ExtReceipt) io.Reader {
 	body, _ := json.Marshal(extReceipt)
 	return bytes.NewReader(body) // Return an io.Reader
@@ -0,0 +1,90 @@
+package receipt
+
+import (
+	"errors"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Modes of POST /admin/receipts/rescore
+const (
+	RescoreDryRun = "dry-run"
+	RescoreApply  = "apply"
+)
+
+// RescoreReceipts godoc
+// @Summary Rescores stored receipts with a rule set version
+// @Description Scores every receipt matching the filters with the given rule set version, the current one by default, and reports the change of points of each receipt. In dry-run mode nothing is changed. In apply mode receipts are updated and the difference is credited to or debited from their account; receipts whose account already spent the points they would lose are reported as failed and left unchanged.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param version query string false "Rule set version to score with, the current rule set if empty"
+// @Param mode query string false "dry-run or apply" default(dry-run)
+// @Param retailer query string false "Only receipts from this retailer, ignoring case"
+// @Param purchaseDateFrom query string false "Only receipts purchased on or after this yyyy-mm-dd date"
+// @Param purchaseDateTo query string false "Only receipts purchased on or before this yyyy-mm-dd date"
+// @Param minPoints query int false "Only receipts awarded at least this many points"
+// @Param maxPoints query int false "Only receipts awarded at most this many points"
+// @Param ruleVersion query string false "Only receipts scored with this rule set version"
+// @Param retailerId query string false "Only receipts matched to this canonical retailer, receipts that matched none if empty"
+// @Success 200 {object} ExtRescoreResponse "Receipts rescored, see each result"
+// @Failure 400 {object} models.ErrorResponse "Invalid query parameters or unknown rule set version"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/receipts/rescore [post]
+func RescoreReceipts(c *gin.Context) {
+	filter, errs := parseListFilter(c)
+	mode := c.DefaultQuery("mode", RescoreDryRun)
+	if mode != RescoreDryRun && mode != RescoreApply {
+		errs = append(errs, models.FieldError{Field: "mode", Code: models.CodePattern, Message: `mode must be "dry-run" or "apply"`})
+	}
+	if len(errs) > 0 {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid query parameters", Details: errs})
+		return
+	}
+
+	report, err := receiptService.Rescore(c.Request.Context(), receiptSvc.RescoreQuery{
+		Version: c.Query("version"),
+		Filter:  filter,
+		Apply:   mode == RescoreApply,
+	})
+	if err != nil {
+		if errors.Is(err, receiptSvc.ErrUnknownRuleVersion) {
+			logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Unknown rule set version"})
+			return
+		}
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	response := ExtRescoreResponse{
+		Version:    report.Version,
+		Mode:       mode,
+		Changed:    report.Changed,
+		Failed:     report.Failed,
+		TotalDelta: report.TotalDelta,
+		Results:    make([]ExtRescoreResult, 0, len(report.Results)),
+	}
+	for _, result := range report.Results {
+		entry := ExtRescoreResult{
+			ReceiptID:  result.ReceiptID,
+			OldVersion: result.OldVersion,
+			OldPoints:  result.OldPoints,
+			NewPoints:  result.NewPoints,
+			Delta:      result.Delta,
+		}
+		if result.Err != nil {
+			entry.Error = "Points could not be applied"
+			if errors.Is(result.Err, repo.ErrInsufficientFunds) {
+				entry.Error = "The account already spent the points the receipt would lose"
+			}
+		}
+		response.Results = append(response.Results, entry)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -1,9 +1,126 @@
 package receipt
 
+import (
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
+)
+
 type ExtProcessReceiptResponse struct {
 	ID string `json:"id"`
+	// Status is set for receipts submitted with async=true
+	Status string `json:"status,omitempty"`
+}
+
+type ExtDuplicateReceiptResponse struct {
+	Error string `json:"error"`
+	ID    string `json:"id"`
+	// RequestID identifies the request in the server log
+	RequestID string `json:"requestId,omitempty"`
 }
 
 type ExtGetPointsResponse struct {
 	Points int64 `json:"points"`
 }
+
+type ExtGetPointsBreakdownResponse struct {
+	Points int64               `json:"points"`
+	Rules  []models.RuleResult `json:"rules"`
+	// Recomputed is true when the rule set that awarded the points is no longer known, the rules and
+	// points are then those of the current rule set and may differ from GET /receipts/{id}/points
+	Recomputed bool `json:"recomputed,omitempty"`
+}
+
+type ExtScoreReceiptResponse struct {
+	Points      int64                    `json:"points"`
+	RuleVersion string                   `json:"ruleVersion"`
+	Rules       []models.RuleResult      `json:"rules"`
+	Campaigns   []models.AppliedCampaign `json:"campaigns,omitempty"`
+}
+
+const (
+	BatchStatusProcessed = "processed"
+	BatchStatusFailed    = "failed"
+)
+
+type ExtBatchResult struct {
+	Index  int                   `json:"index"`
+	Status string                `json:"status"`
+	ID     string                `json:"id,omitempty"`
+	Points *int64                `json:"points,omitempty"` // set for every processed receipt, even when it is worth 0 points
+	Error  *models.ErrorResponse `json:"error,omitempty"`
+}
+
+type ExtBatchProcessResponse struct {
+	Processed int              `json:"processed"`
+	Failed    int              `json:"failed"`
+	Results   []ExtBatchResult `json:"results"`
+}
+
+type ExtReceiptResponse struct {
+	ID           string                   `json:"id"`
+	Status       string                   `json:"status"`
+	AccountID    string                   `json:"accountId,omitempty"`
+	Retailer     string                   `json:"retailer"`
+	RetailerID   string                   `json:"retailerId,omitempty"`
+	PurchaseDate string                   `json:"purchaseDate"`
+	PurchaseTime string                   `json:"purchaseTime"`
+	Items        []models.ExtItem         `json:"items"`
+	Total        string                   `json:"total"`
+	Points       int64                    `json:"points"`
+	RuleVersion  string                   `json:"ruleVersion,omitempty"`
+	Campaigns    []models.AppliedCampaign `json:"campaigns,omitempty"`
+}
+
+// ExtReceiptStatusResponse reports a receipt submitted with async=true that is not processed
+type ExtReceiptStatusResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ExtListReceiptsResponse struct {
+	Receipts   []ExtReceiptResponse `json:"receipts"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// newExtReceiptResponse converts stored receipt data into its external format
+func newExtReceiptResponse(data repo.ReceiptData) ExtReceiptResponse {
+	r := data.Receipt
+	items := make([]models.ExtItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		items = append(items, models.ExtItem{ShortDescription: item.ShortDescription, Price: item.Price.String()})
+	}
+	return ExtReceiptResponse{
+		ID:           r.ID,
+		Status:       string(receiptSvc.JobProcessed),
+		AccountID:    r.AccountID,
+		Retailer:     r.Retailer,
+		RetailerID:   r.RetailerID,
+		PurchaseDate: r.PurchaseDate,
+		PurchaseTime: r.PurchaseTime,
+		Items:        items,
+		Total:        r.Total.String(),
+		Points:       data.Point,
+		RuleVersion:  data.RuleVersion,
+		Campaigns:    data.Campaigns,
+	}
+}
+
+type ExtRescoreResult struct {
+	ReceiptID  string `json:"receiptId"`
+	OldVersion string `json:"oldVersion"`
+	OldPoints  int64  `json:"oldPoints"`
+	NewPoints  int64  `json:"newPoints"`
+	Delta      int64  `json:"delta"`
+	Error      string `json:"error,omitempty"`
+}
+
+type ExtRescoreResponse struct {
+	Version    string             `json:"version"`
+	Mode       string             `json:"mode"`
+	Changed    int                `json:"changed"`
+	Failed     int                `json:"failed"`
+	TotalDelta int64              `json:"totalDelta"`
+	Results    []ExtRescoreResult `json:"results"`
+}
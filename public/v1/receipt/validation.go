@@ -0,0 +1,155 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindReceipt decodes the JSON body into a receipt and validates it,
+// returning every problem found as field-level errors
+func bindReceipt(c *gin.Context, extReceipt *models.ExtReceipt) models.ValidationErrors {
+	var errs models.ValidationErrors
+	if err := c.ShouldBindJSON(extReceipt); err != nil {
+		errs = models.ValidationErrors{decodeError(err)}
+	} else {
+		errs = extReceipt.Validate()
+	}
+	observeRejection(errs)
+	return errs
+}
+
+// observeRejection tells the validation observer about the problems of a rejected receipt, if there are any
+func observeRejection(errs models.ValidationErrors) {
+	if settings.validation != nil && len(errs) > 0 {
+		settings.validation.ReceiptRejected(errs)
+	}
+}
+
+// decodeError describes why a request body could not be decoded
+func decodeError(err error) models.FieldError {
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return models.FieldError{
+			Field:   "body",
+			Code:    models.CodeBodyTooLarge,
+			Message: fmt.Sprintf("request body is larger than %d bytes", sizeErr.Limit),
+		}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return models.FieldError{
+			Field:   field,
+			Code:    models.CodeInvalidType,
+			Message: fmt.Sprintf("expected %s but got %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	return models.FieldError{
+		Field:   "body",
+		Code:    models.CodeMalformedJSON,
+		Message: "request body is not valid JSON: " + err.Error(),
+	}
+}
+
+// rejectionStatus is the status of a response rejecting a request body for errs: 413 when the
+// body was too large to read, 400 otherwise
+func rejectionStatus(errs models.ValidationErrors) int {
+	for _, err := range errs {
+		if err.Code == models.CodeBodyTooLarge {
+			return http.StatusRequestEntityTooLarge
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// maxPageSize is the largest limit accepted when listing receipts
+const maxPageSize = 100
+
+// parseListQuery reads the paging and filter parameters of GET /receipts,
+// returning every invalid parameter as field-level errors
+func parseListQuery(c *gin.Context) (receiptSvc.ListQuery, models.ValidationErrors) {
+	var errs models.ValidationErrors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, models.FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	q := receiptSvc.ListQuery{Cursor: c.Query("cursor"), Limit: receiptSvc.DefaultPageSize}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			add("limit", models.CodePattern, "limit %q is not a number from 1 to %d", value, maxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	filter, filterErrs := parseListFilter(c)
+	q.Filter = filter
+	errs = append(errs, filterErrs...)
+
+	return q, errs
+}
+
+// parseListFilter reads the receipt filter parameters shared by listing and rescoring
+func parseListFilter(c *gin.Context) (repo.ListQuery, models.ValidationErrors) {
+	var errs models.ValidationErrors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, models.FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var filter repo.ListQuery
+	filter.Retailer = c.Query("retailer")
+	if version, ok := c.GetQuery("ruleVersion"); ok {
+		filter.RuleVersion = &version
+	}
+	if retailerID, ok := c.GetQuery("retailerId"); ok {
+		filter.RetailerID = &retailerID
+	}
+	for _, date := range []struct {
+		field string
+		dest  *string
+	}{
+		{"purchaseDateFrom", &filter.PurchasedFrom},
+		{"purchaseDateTo", &filter.PurchasedTo},
+	} {
+		value := c.Query(date.field)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			add(date.field, models.CodeInvalidDate, "%s %q is not a calendar date in yyyy-mm-dd format", date.field, value)
+		}
+		*date.dest = value
+	}
+
+	for _, points := range []struct {
+		field string
+		dest  **int64
+	}{
+		{"minPoints", &filter.MinPoints},
+		{"maxPoints", &filter.MaxPoints},
+	} {
+		value := c.Query(points.field)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			add(points.field, models.CodePattern, "%s %q is not a whole number", points.field, value)
+		}
+		*points.dest = &n
+	}
+
+	return filter, errs
+}
@@ -0,0 +1,20 @@
+package retailer
+
+import "receipt-processor/models"
+
+type ExtRegisterRetailerRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Aliases []string `json:"aliases"`
+}
+
+type ExtAddAliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+type ExtListRetailersResponse struct {
+	Retailers []models.Retailer `json:"retailers"`
+}
+
+type ExtListUnmatchedResponse struct {
+	Retailers []models.UnmatchedRetailer `json:"retailers"`
+}
@@ -0,0 +1,177 @@
+package retailer
+
+import (
+	"errors"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	retailerSvc "receipt-processor/services/retailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+var retailerService retailerSvc.RetailerService
+
+// Register router for the APIs
+func Register(router gin.IRouter, service retailerSvc.RetailerService) {
+	retailerService = service
+
+	router.POST("/retailers", RegisterRetailer)
+	router.GET("/retailers", ListRetailers)
+	router.GET("/retailers/unmatched", ListUnmatched)
+	router.GET("/retailers/:id", GetRetailer)
+	router.POST("/retailers/:id/aliases", AddAlias)
+	router.DELETE("/retailers/:id/aliases/:alias", RemoveAlias)
+}
+
+// RegisterRetailer godoc
+// @Summary Registers a canonical retailer
+// @Description Registers a retailer with an ID derived from its name, like target for "Target". Receipts are matched to it when their retailer name, ignoring case, punctuation, store numbers and small typos, is its name or one of its aliases. Stored receipts that matched no retailer and match the new one are assigned to it.
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Param retailer body ExtRegisterRetailerRequest true "Retailer name and aliases"
+// @Success 201 {object} models.Retailer "Retailer registered"
+// @Failure 400 {object} models.ErrorResponse "Invalid name or alias"
+// @Failure 409 {object} models.ErrorResponse "Retailer already registered, or a name already belongs to another retailer"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers [post]
+func RegisterRetailer(c *gin.Context) {
+	var request ExtRegisterRetailerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	retailer, err := retailerService.RegisterRetailer(request.Name, request.Aliases)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, retailer)
+}
+
+// ListRetailers godoc
+// @Summary Lists canonical retailers
+// @Description Returns every registered retailer with its aliases, ordered by ID.
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Success 200 {object} ExtListRetailersResponse "Retailers retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers [get]
+func ListRetailers(c *gin.Context) {
+	retailers, err := retailerService.ListRetailers()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ExtListRetailersResponse{Retailers: retailers})
+}
+
+// ListUnmatched godoc
+// @Summary Lists retailer names that matched no retailer
+// @Description Returns the retailer names of stored receipts that matched no registered retailer with their number of receipts, most frequent first. Register them or add them as aliases to group their receipts.
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Success 200 {object} ExtListUnmatchedResponse "Unmatched names retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers/unmatched [get]
+func ListUnmatched(c *gin.Context) {
+	unmatched, err := retailerService.ListUnmatched()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ExtListUnmatchedResponse{Retailers: unmatched})
+}
+
+// GetRetailer godoc
+// @Summary Retrieves a canonical retailer by ID
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Param id path string true "Retailer ID"
+// @Success 200 {object} models.Retailer "Retailer retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Retailer not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers/{id} [get]
+func GetRetailer(c *gin.Context) {
+	retailer, err := retailerService.GetRetailer(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, retailer)
+}
+
+// AddAlias godoc
+// @Summary Adds an alias to a retailer
+// @Description Adds an alternative name receipts are matched to the retailer by. Stored receipts that matched no retailer and match the alias are assigned to it.
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Param id path string true "Retailer ID"
+// @Param alias body ExtAddAliasRequest true "Alias to add"
+// @Success 200 {object} models.Retailer "Alias added"
+// @Failure 400 {object} models.ErrorResponse "Invalid alias"
+// @Failure 404 {object} models.ErrorResponse "Retailer not found"
+// @Failure 409 {object} models.ErrorResponse "Alias already belongs to another retailer"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers/{id}/aliases [post]
+func AddAlias(c *gin.Context) {
+	var request ExtAddAliasRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	retailer, err := retailerService.AddAlias(c.Param("id"), request.Alias)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, retailer)
+}
+
+// RemoveAlias godoc
+// @Summary Removes an alias from a retailer
+// @Description Stops matching receipts to the retailer by the alias. Receipts already matched through it keep their retailer.
+// @Tags retailers
+// @Accept json
+// @Produce json
+// @Param id path string true "Retailer ID"
+// @Param alias path string true "Alias to remove"
+// @Success 200 {object} models.Retailer "Alias removed"
+// @Failure 404 {object} models.ErrorResponse "Retailer or alias not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /retailers/{id}/aliases/{alias} [delete]
+func RemoveAlias(c *gin.Context) {
+	retailer, err := retailerService.RemoveAlias(c.Param("id"), c.Param("alias"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, retailer)
+}
+
+// respondError maps a service error to its response
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, retailerSvc.ErrInvalidName):
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Retailer names must contain letters"})
+	case errors.Is(err, retailerSvc.ErrRetailerExists):
+		logging.WriteError(c, http.StatusConflict, models.ErrorResponse{Error: "Retailer already registered"})
+	case errors.Is(err, retailerSvc.ErrAliasTaken):
+		logging.WriteError(c, http.StatusConflict, models.ErrorResponse{Error: "Name already belongs to another retailer"})
+	case errors.Is(err, repo.ErrRetailerNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Retailer not found"})
+	case errors.Is(err, retailerSvc.ErrAliasNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Alias not found"})
+	default:
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
@@ -0,0 +1,145 @@
+package retailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	retailerSvc "receipt-processor/services/retailer"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MockRetailerService is a mock implementation of the RetailerService interface
+type MockRetailerService struct {
+	mock.Mock
+}
+
+func (m *MockRetailerService) RegisterRetailer(name string, aliases []string) (models.Retailer, error) {
+	args := m.Called(name, aliases)
+	return args.Get(0).(models.Retailer), args.Error(1)
+}
+
+func (m *MockRetailerService) GetRetailer(id string) (models.Retailer, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.Retailer), args.Error(1)
+}
+
+func (m *MockRetailerService) ListRetailers() ([]models.Retailer, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Retailer), args.Error(1)
+}
+
+func (m *MockRetailerService) AddAlias(id, alias string) (models.Retailer, error) {
+	args := m.Called(id, alias)
+	return args.Get(0).(models.Retailer), args.Error(1)
+}
+
+func (m *MockRetailerService) RemoveAlias(id, alias string) (models.Retailer, error) {
+	args := m.Called(id, alias)
+	return args.Get(0).(models.Retailer), args.Error(1)
+}
+
+func (m *MockRetailerService) ListUnmatched() ([]models.UnmatchedRetailer, error) {
+	args := m.Called()
+	return args.Get(0).([]models.UnmatchedRetailer), args.Error(1)
+}
+
+func (m *MockRetailerService) Match(name string) (string, error) {
+	args := m.Called(name)
+	return args.String(0), args.Error(1)
+}
+
+// RetailerHandlerTestSuite defines the suite for handler tests
+type RetailerHandlerTestSuite struct {
+	suite.Suite
+	mockService  *MockRetailerService
+	router       *gin.Engine
+	mockRetailer models.Retailer
+}
+
+// SetupTest initializes the suite
+func (suite *RetailerHandlerTestSuite) SetupTest() {
+	suite.mockService = new(MockRetailerService)
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService)
+	suite.mockRetailer = models.Retailer{ID: "target", Name: "Target", Aliases: []string{"Tgt"}}
+}
+
+func (suite *RetailerHandlerTestSuite) serve(method, target string, body any) *httptest.ResponseRecorder {
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *RetailerHandlerTestSuite) TestRegisterRetailer() {
+	suite.mockService.On("RegisterRetailer", "Target", []string{"Tgt"}).Return(suite.mockRetailer, nil)
+
+	w := suite.serve("POST", "/retailers", ExtRegisterRetailerRequest{Name: "Target", Aliases: []string{"Tgt"}})
+
+	suite.Equal(http.StatusCreated, w.Code)
+	var response models.Retailer
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(suite.mockRetailer, response)
+}
+
+func (suite *RetailerHandlerTestSuite) TestRegisterRetailerErrors() {
+	suite.mockService.On("RegisterRetailer", "Target", []string(nil)).
+		Return(models.Retailer{}, fmt.Errorf("retailer with id target: %w", retailerSvc.ErrRetailerExists))
+	suite.mockService.On("RegisterRetailer", "###", []string(nil)).Return(models.Retailer{}, retailerSvc.ErrInvalidName)
+
+	suite.Equal(http.StatusConflict, suite.serve("POST", "/retailers", ExtRegisterRetailerRequest{Name: "Target"}).Code)
+	suite.Equal(http.StatusBadRequest, suite.serve("POST", "/retailers", ExtRegisterRetailerRequest{Name: "###"}).Code)
+	suite.Equal(http.StatusBadRequest, suite.serve("POST", "/retailers", map[string]string{}).Code)
+}
+
+func (suite *RetailerHandlerTestSuite) TestListRetailers() {
+	suite.mockService.On("ListRetailers").Return([]models.Retailer{suite.mockRetailer}, nil)
+	suite.mockService.On("ListUnmatched").Return([]models.UnmatchedRetailer{{Name: "Walgreens", Receipts: 2}}, nil)
+
+	w := suite.serve("GET", "/retailers", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"retailers":[{"id":"target","name":"Target","aliases":["Tgt"]}]}`, w.Body.String())
+
+	w = suite.serve("GET", "/retailers/unmatched", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"retailers":[{"name":"Walgreens","receipts":2}]}`, w.Body.String())
+}
+
+func (suite *RetailerHandlerTestSuite) TestGetRetailerNotFound() {
+	suite.mockService.On("GetRetailer", "missing").Return(models.Retailer{}, repo.ErrRetailerNotFound)
+
+	suite.Equal(http.StatusNotFound, suite.serve("GET", "/retailers/missing", nil).Code)
+}
+
+func (suite *RetailerHandlerTestSuite) TestAliases() {
+	suite.mockService.On("AddAlias", "target", "Target Stores").Return(suite.mockRetailer, nil)
+	suite.mockService.On("AddAlias", "target", "Walmart").Return(models.Retailer{}, retailerSvc.ErrAliasTaken)
+	suite.mockService.On("RemoveAlias", "target", "Tgt").Return(suite.mockRetailer, nil)
+	suite.mockService.On("RemoveAlias", "target", "Walmart").Return(models.Retailer{}, retailerSvc.ErrAliasNotFound)
+	suite.mockService.On("RemoveAlias", "missing", "Tgt").Return(models.Retailer{}, repo.ErrRetailerNotFound)
+
+	suite.Equal(http.StatusOK, suite.serve("POST", "/retailers/target/aliases", ExtAddAliasRequest{Alias: "Target Stores"}).Code)
+	suite.Equal(http.StatusConflict, suite.serve("POST", "/retailers/target/aliases", ExtAddAliasRequest{Alias: "Walmart"}).Code)
+	suite.Equal(http.StatusOK, suite.serve("DELETE", "/retailers/target/aliases/Tgt", nil).Code)
+	suite.Equal(http.StatusNotFound, suite.serve("DELETE", "/retailers/target/aliases/Walmart", nil).Code)
+	suite.Equal(http.StatusNotFound, suite.serve("DELETE", "/retailers/missing/aliases/Tgt", nil).Code)
+}
+
+func TestRetailerHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(RetailerHandlerTestSuite))
+}
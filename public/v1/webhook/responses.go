@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"receipt-processor/models"
+	"time"
+)
+
+type ExtCreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Events are the event types delivered, every type if empty
+	Events []string `json:"events"`
+	// Secret signs the deliveries, a random one is generated if empty
+	Secret string `json:"secret"`
+}
+
+// ExtWebhookResponse is a webhook without its secret, which is only returned when it is created
+type ExtWebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ExtListWebhooksResponse struct {
+	Webhooks []ExtWebhookResponse `json:"webhooks"`
+}
+
+type ExtListDeliveriesResponse struct {
+	Deliveries []models.Delivery `json:"deliveries"`
+}
+
+func newWebhookResponse(w models.Webhook) ExtWebhookResponse {
+	return ExtWebhookResponse{ID: w.ID, URL: w.URL, Events: w.Events, CreatedAt: w.CreatedAt}
+}
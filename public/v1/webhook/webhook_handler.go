@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	webhookSvc "receipt-processor/services/webhook"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var webhookService webhookSvc.WebhookService
+
+// Register router for the APIs
+func Register(router gin.IRouter, service webhookSvc.WebhookService) {
+	webhookService = service
+
+	router.POST("/webhooks", CreateWebhook)
+	router.GET("/webhooks", ListWebhooks)
+	router.GET("/webhooks/dead-letters", ListDeadLetters)
+	router.POST("/webhooks/dead-letters/:id/redeliver", Redeliver)
+	router.GET("/webhooks/:id", GetWebhook)
+	router.DELETE("/webhooks/:id", DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", ListDeliveries)
+}
+
+// CreateWebhook godoc
+// @Summary Subscribes a webhook to receipt events
+// @Description Posts a JSON event to the URL whenever a receipt is processed (receipt.processed), deleted (receipt.deleted) or rescored (receipt.rescored), limited to the given event types if any. Each request is signed: the X-Receipt-Signature header is "sha256=" followed by the hex HMAC-SHA256, keyed with the secret, of the X-Receipt-Timestamp header, a dot and the body. Failed requests are retried with exponential backoff, then kept as dead letters. The secret is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body ExtCreateWebhookRequest true "URL, event types and optional secret"
+// @Success 201 {object} models.Webhook "Webhook created, with its secret"
+// @Failure 400 {object} models.ErrorResponse "Invalid webhook, details lists every invalid field"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var request ExtCreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	created, err := webhookService.CreateWebhook(models.Webhook{URL: request.URL, Events: request.Events, Secret: request.Secret})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListWebhooks godoc
+// @Summary Lists webhooks
+// @Description Returns every webhook without its secret, oldest first.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {object} ExtListWebhooksResponse "Webhooks retrieved successfully"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks [get]
+func ListWebhooks(c *gin.Context) {
+	webhooks, err := webhookService.ListWebhooks()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response := ExtListWebhooksResponse{Webhooks: make([]ExtWebhookResponse, 0, len(webhooks))}
+	for _, w := range webhooks {
+		response.Webhooks = append(response.Webhooks, newWebhookResponse(w))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWebhook godoc
+// @Summary Retrieves a webhook by ID
+// @Description Returns the webhook without its secret.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} ExtWebhookResponse "Webhook retrieved successfully"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	w, err := webhookService.GetWebhook(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(w))
+}
+
+// DeleteWebhook godoc
+// @Summary Deletes a webhook
+// @Description Stops delivering events to the webhook and deletes its delivery history.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 204 "Webhook deleted"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	if err := webhookService.DeleteWebhook(c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary Lists the deliveries to a webhook
+// @Description Returns the latest deliveries to the webhook, newest first, with the response status or error of every attempt.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param limit query int false "Number of deliveries, 1 to 100" default(20)
+// @Success 200 {object} ExtListDeliveriesResponse "Deliveries retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid limit"
+// @Failure 404 {object} models.ErrorResponse "Webhook not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks/{id}/deliveries [get]
+func ListDeliveries(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	deliveries, err := webhookService.ListDeliveries(c.Param("id"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ExtListDeliveriesResponse{Deliveries: deliveries})
+}
+
+// ListDeadLetters godoc
+// @Summary Lists dead letters
+// @Description Returns the latest deliveries to any webhook that failed every attempt, newest first.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param limit query int false "Number of deliveries, 1 to 100" default(20)
+// @Success 200 {object} ExtListDeliveriesResponse "Dead letters retrieved successfully"
+// @Failure 400 {object} models.ErrorResponse "Invalid limit"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks/dead-letters [get]
+func ListDeadLetters(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	deliveries, err := webhookService.ListDeadLetters(limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ExtListDeliveriesResponse{Deliveries: deliveries})
+}
+
+// Redeliver godoc
+// @Summary Redelivers a dead letter
+// @Description Attempts the delivery again with a fresh round of retries, keeping its earlier attempts.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Delivery ID"
+// @Success 202 {object} models.Delivery "Delivery pending"
+// @Failure 404 {object} models.ErrorResponse "Delivery not found"
+// @Failure 409 {object} models.ErrorResponse "Delivery is not a dead letter"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /webhooks/dead-letters/{id}/redeliver [post]
+func Redeliver(c *gin.Context) {
+	delivery, err := webhookService.Redeliver(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// Default and largest number of deliveries listed
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parseLimit reads the limit parameter of the delivery lists, responding with an error if it is invalid
+func parseLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultPageSize, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid query parameters", Details: []models.FieldError{{
+			Field:   "limit",
+			Code:    models.CodePattern,
+			Message: fmt.Sprintf("limit %q is not a number from 1 to %d", value, maxPageSize),
+		}}})
+		return 0, false
+	}
+	return limit, true
+}
+
+// respondError maps a service error to its response
+func respondError(c *gin.Context, err error) {
+	var errs models.ValidationErrors
+	switch {
+	case errors.As(err, &errs):
+		logging.WriteError(c, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid webhook", Details: errs})
+	case errors.Is(err, repo.ErrWebhookNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Webhook not found"})
+	case errors.Is(err, repo.ErrDeliveryNotFound):
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Delivery not found"})
+	case errors.Is(err, webhookSvc.ErrNotDead):
+		logging.WriteError(c, http.StatusConflict, models.ErrorResponse{Error: "Delivery is not a dead letter"})
+	default:
+		c.Error(err)
+		logging.WriteError(c, http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
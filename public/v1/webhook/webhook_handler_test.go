@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	webhookSvc "receipt-processor/services/webhook"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MockWebhookService is a mock implementation of the WebhookService interface
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) CreateWebhook(w models.Webhook) (models.Webhook, error) {
+	args := m.Called(w)
+	return args.Get(0).(models.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) GetWebhook(id string) (models.Webhook, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) ListWebhooks() ([]models.Webhook, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) DeleteWebhook(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) ListDeliveries(webhookID string, limit int) ([]models.Delivery, error) {
+	args := m.Called(webhookID, limit)
+	return args.Get(0).([]models.Delivery), args.Error(1)
+}
+
+func (m *MockWebhookService) ListDeadLetters(limit int) ([]models.Delivery, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]models.Delivery), args.Error(1)
+}
+
+func (m *MockWebhookService) Redeliver(deliveryID string) (models.Delivery, error) {
+	args := m.Called(deliveryID)
+	return args.Get(0).(models.Delivery), args.Error(1)
+}
+
+func (m *MockWebhookService) Publish(event models.ReceiptEvent) {
+	m.Called(event)
+}
+
+func (m *MockWebhookService) ResumePending() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) Close(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// WebhookHandlerTestSuite defines the suite for handler tests
+type WebhookHandlerTestSuite struct {
+	suite.Suite
+	mockService  *MockWebhookService
+	router       *gin.Engine
+	mockWebhook  models.Webhook
+	mockDelivery models.Delivery
+}
+
+// SetupTest initializes the suite
+func (suite *WebhookHandlerTestSuite) SetupTest() {
+	suite.mockService = new(MockWebhookService)
+	suite.router = gin.Default()
+	Register(suite.router, suite.mockService)
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	suite.mockWebhook = models.Webhook{
+		ID:        "w1",
+		URL:       "https://example.com/hook",
+		Events:    []string{models.EventReceiptProcessed},
+		Secret:    "s3cret",
+		CreatedAt: createdAt,
+	}
+	suite.mockDelivery = models.Delivery{
+		ID:        "d1",
+		WebhookID: "w1",
+		Event:     models.ReceiptEvent{ID: "e1", Type: models.EventReceiptProcessed, CreatedAt: createdAt, ReceiptID: "r1", Points: 28},
+		Status:    models.DeliveryDead,
+		Attempts:  []models.DeliveryAttempt{{At: createdAt, StatusCode: http.StatusInternalServerError, Error: "unexpected status 500"}},
+		CreatedAt: createdAt,
+	}
+}
+
+func (suite *WebhookHandlerTestSuite) serve(method, target string, body any) *httptest.ResponseRecorder {
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *WebhookHandlerTestSuite) TestCreateWebhook() {
+	suite.mockService.On("CreateWebhook", models.Webhook{URL: suite.mockWebhook.URL, Events: suite.mockWebhook.Events}).Return(suite.mockWebhook, nil)
+
+	w := suite.serve("POST", "/webhooks", ExtCreateWebhookRequest{URL: suite.mockWebhook.URL, Events: suite.mockWebhook.Events})
+
+	// The secret is returned when the webhook is created
+	suite.Equal(http.StatusCreated, w.Code)
+	var response models.Webhook
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(suite.mockWebhook, response)
+}
+
+func (suite *WebhookHandlerTestSuite) TestCreateWebhookInvalid() {
+	errs := models.ValidationErrors{{Field: "url", Code: models.CodePattern, Message: "url must be an absolute http or https URL"}}
+	suite.mockService.On("CreateWebhook", models.Webhook{URL: "ftp://example.com"}).Return(models.Webhook{}, errs)
+
+	w := suite.serve("POST", "/webhooks", ExtCreateWebhookRequest{URL: "ftp://example.com"})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal([]models.FieldError(errs), response.Details)
+
+	suite.Equal(http.StatusBadRequest, suite.serve("POST", "/webhooks", map[string]string{}).Code)
+}
+
+func (suite *WebhookHandlerTestSuite) TestGetWebhooksHidesSecret() {
+	suite.mockService.On("ListWebhooks").Return([]models.Webhook{suite.mockWebhook}, nil)
+	suite.mockService.On("GetWebhook", "w1").Return(suite.mockWebhook, nil)
+	suite.mockService.On("GetWebhook", "missing").Return(models.Webhook{}, repo.ErrWebhookNotFound)
+
+	w := suite.serve("GET", "/webhooks", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"webhooks":[{"id":"w1","url":"https://example.com/hook","events":["receipt.processed"],"createdAt":"2024-03-01T12:00:00Z"}]}`, w.Body.String())
+
+	w = suite.serve("GET", "/webhooks/w1", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.NotContains(w.Body.String(), "s3cret")
+
+	suite.Equal(http.StatusNotFound, suite.serve("GET", "/webhooks/missing", nil).Code)
+}
+
+func (suite *WebhookHandlerTestSuite) TestDeleteWebhook() {
+	suite.mockService.On("DeleteWebhook", "w1").Return(nil)
+	suite.mockService.On("DeleteWebhook", "missing").Return(fmt.Errorf("webhook with id missing does not exist: %w", repo.ErrWebhookNotFound))
+
+	suite.Equal(http.StatusNoContent, suite.serve("DELETE", "/webhooks/w1", nil).Code)
+	suite.Equal(http.StatusNotFound, suite.serve("DELETE", "/webhooks/missing", nil).Code)
+}
+
+func (suite *WebhookHandlerTestSuite) TestListDeliveries() {
+	suite.mockService.On("ListDeliveries", "w1", 20).Return([]models.Delivery{suite.mockDelivery}, nil)
+	suite.mockService.On("ListDeliveries", "w1", 5).Return([]models.Delivery{}, nil)
+	suite.mockService.On("ListDeadLetters", 20).Return([]models.Delivery{suite.mockDelivery}, nil)
+
+	w := suite.serve("GET", "/webhooks/w1/deliveries", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	var response ExtListDeliveriesResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal([]models.Delivery{suite.mockDelivery}, response.Deliveries)
+
+	w = suite.serve("GET", "/webhooks/w1/deliveries?limit=5", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"deliveries":[]}`, w.Body.String())
+	suite.Equal(http.StatusBadRequest, suite.serve("GET", "/webhooks/w1/deliveries?limit=500", nil).Code)
+
+	w = suite.serve("GET", "/webhooks/dead-letters", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal([]models.Delivery{suite.mockDelivery}, response.Deliveries)
+}
+
+func (suite *WebhookHandlerTestSuite) TestRedeliver() {
+	pending := suite.mockDelivery
+	pending.Status = models.DeliveryPending
+	suite.mockService.On("Redeliver", "d1").Return(pending, nil)
+	suite.mockService.On("Redeliver", "d2").Return(models.Delivery{}, fmt.Errorf("delivery with id d2 is succeeded: %w", webhookSvc.ErrNotDead))
+	suite.mockService.On("Redeliver", "missing").Return(models.Delivery{}, repo.ErrDeliveryNotFound)
+
+	w := suite.serve("POST", "/webhooks/dead-letters/d1/redeliver", nil)
+	suite.Equal(http.StatusAccepted, w.Code)
+	var response models.Delivery
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Equal(models.DeliveryPending, response.Status)
+
+	suite.Equal(http.StatusConflict, suite.serve("POST", "/webhooks/dead-letters/d2/redeliver", nil).Code)
+	suite.Equal(http.StatusNotFound, suite.serve("POST", "/webhooks/dead-letters/missing/redeliver", nil).Code)
+}
+
+func TestWebhookHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookHandlerTestSuite))
+}
@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"errors"
+	"receipt-processor/models"
+	"sort"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyStore keeps the API keys clients authenticate with.
+// Implementations must be safe for concurrent use.
+type APIKeyStore interface {
+	// GetAPIKey retrieves a key by ID, returning ErrAPIKeyNotFound if it does not exist.
+	GetAPIKey(id string) (models.APIKey, error)
+	// PutAPIKey updates or inserts a key by its ID.
+	PutAPIKey(k models.APIKey) error
+	// ListAPIKeys returns every key, revoked ones included, ordered by creation time, then ID.
+	ListAPIKeys() ([]models.APIKey, error)
+}
+
+// sortedAPIKeys copies the map values into a slice ordered by creation time, then ID
+func sortedAPIKeys(keys map[string]models.APIKey) []models.APIKey {
+	list := make([]models.APIKey, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"errors"
+	"receipt-processor/models"
+	"sort"
+)
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// CampaignStore keeps promotional campaigns.
+// Implementations must be safe for concurrent use.
+type CampaignStore interface {
+	// GetCampaign retrieves a campaign by ID, returning ErrCampaignNotFound if it does not exist.
+	GetCampaign(id string) (models.Campaign, error)
+	// PutCampaign updates or inserts a campaign by its ID.
+	PutCampaign(c models.Campaign) error
+	// DeleteCampaign removes a campaign by ID, returning ErrCampaignNotFound if it does not exist.
+	DeleteCampaign(id string) error
+	// ListCampaigns returns every campaign ordered by start date, then ID.
+	ListCampaigns() ([]models.Campaign, error)
+}
+
+// sortedCampaigns copies the map values into a slice ordered by start date, then ID
+func sortedCampaigns(campaigns map[string]models.Campaign) []models.Campaign {
+	list := make([]models.Campaign, 0, len(campaigns))
+	for _, c := range campaigns {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].StartDate != list[j].StartDate {
+			return list[i].StartDate < list[j].StartDate
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
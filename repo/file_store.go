@@ -0,0 +1,931 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"receipt-processor/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	walFileName              = "receipts.wal"
+	snapshotFileName         = "receipts.snapshot"
+	ledgerSnapshotFileName   = "ledger.snapshot"
+	campaignSnapshotFileName = "campaigns.snapshot"
+	retailerSnapshotFileName = "retailers.snapshot"
+	webhookSnapshotFileName  = "webhooks.snapshot"
+	apiKeySnapshotFileName   = "apikeys.snapshot"
+)
+
+// SyncPolicy controls when the write-ahead log is flushed to disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs the log before every write is acknowledged.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs the log periodically in the background.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// ParseSyncPolicy converts a string such as "always" into a SyncPolicy.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case SyncAlways, SyncInterval, SyncNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown sync policy %q", s)
+}
+
+// FileStoreOptions configures a FileStore.
+type FileStoreOptions struct {
+	// Sync is the fsync policy of the write-ahead log. Defaults to SyncAlways.
+	Sync SyncPolicy
+	// SyncInterval is how often the log is flushed under SyncInterval. Defaults to one second.
+	SyncInterval time.Duration
+	// CompactEvery is the number of log records after which the log is compacted into a snapshot.
+	// Defaults to 1000; a negative value disables compaction.
+	CompactEvery int
+}
+
+const (
+	opPut      = "put"
+	opDelete   = "delete"
+	opEntry    = "entry"
+	opTransfer = "transfer"
+	// opPutCampaign and opDeleteCampaign log changes to campaigns, keyed by campaign ID
+	opPutCampaign    = "put_campaign"
+	opDeleteCampaign = "delete_campaign"
+	// opPutRetailer logs a change to the retailer registry, keyed by retailer ID
+	opPutRetailer = "put_retailer"
+	// opPutWebhook and opDeleteWebhook log changes to webhooks, keyed by webhook ID
+	opPutWebhook    = "put_webhook"
+	opDeleteWebhook = "delete_webhook"
+	// opPutDelivery logs a change to a webhook delivery, keyed by delivery ID
+	opPutDelivery = "put_delivery"
+	// opDeleteDeliveries logs the deletion of the webhook deliveries listed in IDs
+	opDeleteDeliveries = "delete_deliveries"
+	// opPutAPIKey logs a change to an API key, keyed by key ID
+	opPutAPIKey = "put_api_key"
+)
+
+// walRecord is a single entry of the write-ahead log
+type walRecord struct {
+	Op   string       `json:"op"`
+	ID   string       `json:"id"`
+	IDs  []string     `json:"ids,omitempty"`
+	Data *ReceiptData `json:"data,omitempty"`
+	// Entry is a single ledger entry logged before transfers existed
+	Entry *LedgerEntry `json:"entry,omitempty"`
+	// Entries are the ledger entries of a transfer, logged together so they are applied together
+	Entries  []LedgerEntry    `json:"entries,omitempty"`
+	Campaign *models.Campaign `json:"campaign,omitempty"`
+	Retailer *models.Retailer `json:"retailer,omitempty"`
+	Webhook  *models.Webhook  `json:"webhook,omitempty"`
+	Delivery *models.Delivery `json:"delivery,omitempty"`
+	APIKey   *models.APIKey   `json:"apiKey,omitempty"`
+}
+
+// webhookSnapshot is the snapshot of webhooks and their deliveries
+type webhookSnapshot struct {
+	Webhooks   map[string]models.Webhook  `json:"webhooks"`
+	Deliveries map[string]models.Delivery `json:"deliveries"`
+}
+
+// logFile is the open write-ahead log, an *os.File outside of tests
+type logFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// FileStore is a durable Store. Every write is appended to a write-ahead log
+// before it is applied in memory, the log is periodically compacted into a snapshot,
+// and both are replayed when the store is opened.
+type FileStore struct {
+	mu      sync.RWMutex
+	dir     string
+	opts    FileStoreOptions
+	wal     logFile
+	records int
+	// failed is set when a failed write could not be rolled back, every later write is rejected
+	// since it would follow bytes that fail to replay
+	failed   error
+	receipts map[string]ReceiptData
+	ledger   ledger
+	// id -> Campaign
+	campaigns map[string]models.Campaign
+	// id -> Retailer
+	retailers map[string]models.Retailer
+	// id -> Webhook
+	webhooks map[string]models.Webhook
+	// id -> Delivery
+	deliveries map[string]models.Delivery
+	// id -> APIKey
+	apiKeys map[string]models.APIKey
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenFileStore opens, or creates, a FileStore in dir and recovers its contents.
+func OpenFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if opts.Sync == "" {
+		opts.Sync = SyncAlways
+	}
+	if _, err := ParseSyncPolicy(string(opts.Sync)); err != nil {
+		return nil, err
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if opts.CompactEvery == 0 {
+		opts.CompactEvery = 1000
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	s := &FileStore{
+		dir:        dir,
+		opts:       opts,
+		receipts:   make(map[string]ReceiptData),
+		ledger:     newLedger(),
+		campaigns:  make(map[string]models.Campaign),
+		retailers:  make(map[string]models.Retailer),
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.Delivery),
+		apiKeys:    make(map[string]models.APIKey),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+// Retrieves a ReceiptData by ID.
+func (s *FileStore) Get(id string) (ReceiptData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ReceiptData{}, ErrNotFound
+	}
+	return data, nil
+}
+
+// Updates or inserts a ReceiptData by ID. The write is logged before it is applied.
+func (s *FileStore) Put(id string, data ReceiptData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: opPut, ID: id, Data: &data}); err != nil {
+		return err
+	}
+	s.receipts[id] = data
+	s.maybeCompact()
+	return nil
+}
+
+// Sets the RetailerID of a ReceiptData by ID. The updated receipt is logged before it is applied.
+func (s *FileStore) AssignRetailer(id, retailerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ErrNotFound
+	}
+	data.Receipt.RetailerID = retailerID
+	if err := s.append(walRecord{Op: opPut, ID: id, Data: &data}); err != nil {
+		return err
+	}
+	s.receipts[id] = data
+	s.maybeCompact()
+	return nil
+}
+
+// Sets the points, rule version and campaigns of a ReceiptData by ID. The updated receipt is logged before it is applied.
+func (s *FileStore) AssignScore(id string, points int64, ruleVersion string, campaigns []models.AppliedCampaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ErrNotFound
+	}
+	data.Point, data.RuleVersion, data.Campaigns = points, ruleVersion, campaigns
+	if err := s.append(walRecord{Op: opPut, ID: id, Data: &data}); err != nil {
+		return err
+	}
+	s.receipts[id] = data
+	s.maybeCompact()
+	return nil
+}
+
+// Deletes a ReceiptData by ID. The delete is logged before it is applied.
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.receipts[id]; !exists {
+		return ErrNotFound
+	}
+	if err := s.append(walRecord{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	delete(s.receipts, id)
+	s.maybeCompact()
+	return nil
+}
+
+// Lists the ReceiptData selected by the query ordered by ID.
+func (s *FileStore) List(q ListQuery) ([]ReceiptData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return selectPage(s.receipts, q), nil
+}
+
+// Counts the stored receipts.
+func (s *FileStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.receipts), nil
+}
+
+// Records the entries of a Transfer. Both entries are logged in one record before they are applied.
+func (s *FileStore) Transfer(t Transfer) ([]LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.ledger.prepare(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.append(walRecord{Op: opTransfer, ID: t.ID, Entries: entries}); err != nil {
+		return nil, err
+	}
+	s.ledger.add(entries...)
+	s.maybeCompact()
+	return entries, nil
+}
+
+// Lists the LedgerEntry values of an account in append order.
+func (s *FileStore) Entries(accountID string) ([]LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ledger.entries(accountID)
+}
+
+// Returns the balance of an account.
+func (s *FileStore) Balance(accountID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ledger.balance(accountID)
+}
+
+// Retrieves a Campaign by ID.
+func (s *FileStore) GetCampaign(id string) (models.Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, exists := s.campaigns[id]
+	if !exists {
+		return models.Campaign{}, ErrCampaignNotFound
+	}
+	return c, nil
+}
+
+// Updates or inserts a Campaign by ID. The write is logged before it is applied.
+func (s *FileStore) PutCampaign(c models.Campaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: opPutCampaign, ID: c.ID, Campaign: &c}); err != nil {
+		return err
+	}
+	s.campaigns[c.ID] = c
+	s.maybeCompact()
+	return nil
+}
+
+// Deletes a Campaign by ID. The delete is logged before it is applied.
+func (s *FileStore) DeleteCampaign(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.campaigns[id]; !exists {
+		return ErrCampaignNotFound
+	}
+	if err := s.append(walRecord{Op: opDeleteCampaign, ID: id}); err != nil {
+		return err
+	}
+	delete(s.campaigns, id)
+	s.maybeCompact()
+	return nil
+}
+
+// Lists every Campaign ordered by start date, then ID.
+func (s *FileStore) ListCampaigns() ([]models.Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedCampaigns(s.campaigns), nil
+}
+
+// Retrieves a Retailer by ID.
+func (s *FileStore) GetRetailer(id string) (models.Retailer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, exists := s.retailers[id]
+	if !exists {
+		return models.Retailer{}, ErrRetailerNotFound
+	}
+	return r, nil
+}
+
+// Updates or inserts a Retailer by ID. The write is logged before it is applied.
+func (s *FileStore) PutRetailer(r models.Retailer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: opPutRetailer, ID: r.ID, Retailer: &r}); err != nil {
+		return err
+	}
+	s.retailers[r.ID] = r
+	s.maybeCompact()
+	return nil
+}
+
+// Lists every Retailer ordered by ID.
+func (s *FileStore) ListRetailers() ([]models.Retailer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedRetailers(s.retailers), nil
+}
+
+// Retrieves a Webhook by ID.
+func (s *FileStore) GetWebhook(id string) (models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, exists := s.webhooks[id]
+	if !exists {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+// Updates or inserts a Webhook by ID. The write is logged before it is applied.
+func (s *FileStore) PutWebhook(w models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: opPutWebhook, ID: w.ID, Webhook: &w}); err != nil {
+		return err
+	}
+	s.webhooks[w.ID] = w
+	s.maybeCompact()
+	return nil
+}
+
+// Deletes a Webhook and its Delivery history by ID. The delete is logged before it is applied.
+func (s *FileStore) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	if err := s.append(walRecord{Op: opDeleteWebhook, ID: id}); err != nil {
+		return err
+	}
+	s.deleteWebhook(id)
+	s.maybeCompact()
+	return nil
+}
+
+// deleteWebhook removes a webhook and its deliveries from memory
+func (s *FileStore) deleteWebhook(id string) {
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+}
+
+// Lists every Webhook ordered by creation time, then ID.
+func (s *FileStore) ListWebhooks() ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedWebhooks(s.webhooks), nil
+}
+
+// Retrieves a Delivery by ID.
+func (s *FileStore) GetDelivery(id string) (models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, exists := s.deliveries[id]
+	if !exists {
+		return models.Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// Updates or inserts a Delivery by ID. The write is logged before it is applied.
+func (s *FileStore) PutDelivery(d models.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.webhooks[d.WebhookID]; !exists {
+		return ErrWebhookNotFound
+	}
+	if err := s.append(walRecord{Op: opPutDelivery, ID: d.ID, Delivery: &d}); err != nil {
+		return err
+	}
+	s.deliveries[d.ID] = d
+	s.maybeCompact()
+	return nil
+}
+
+// Lists the Delivery values matching the query, newest first.
+func (s *FileStore) ListDeliveries(q DeliveryQuery) ([]models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return selectDeliveries(s.deliveries, q), nil
+}
+
+// Deletes the Delivery values matching the query. Their IDs are logged in one record before they are deleted.
+func (s *FileStore) DeleteDeliveries(q DeliveryQuery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id, d := range s.deliveries {
+		if q.Matches(d) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := s.append(walRecord{Op: opDeleteDeliveries, IDs: ids}); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		delete(s.deliveries, id)
+	}
+	s.maybeCompact()
+	return len(ids), nil
+}
+
+// Retrieves an APIKey by ID.
+func (s *FileStore) GetAPIKey(id string) (models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, exists := s.apiKeys[id]
+	if !exists {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+// Updates or inserts an APIKey by ID. The write is logged before it is applied.
+func (s *FileStore) PutAPIKey(k models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: opPutAPIKey, ID: k.ID, APIKey: &k}); err != nil {
+		return err
+	}
+	s.apiKeys[k.ID] = k
+	s.maybeCompact()
+	return nil
+}
+
+// Lists every APIKey ordered by creation time, then ID.
+func (s *FileStore) ListAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedAPIKeys(s.apiKeys), nil
+}
+
+// Compact writes the current contents to a snapshot and truncates the log.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+// Close flushes the log and releases the underlying files.
+func (s *FileStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wal == nil {
+		return nil
+	}
+	err := s.wal.Sync()
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+	s.wal = nil
+	return err
+}
+
+// Ping fails if the store was closed or its data directory is gone
+func (s *FileStore) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.wal == nil {
+		return errors.New("file store is closed")
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	if _, err := os.Stat(s.dir); err != nil {
+		return fmt.Errorf("failed to reach data directory: %w", err)
+	}
+	return nil
+}
+
+// append encodes a record as "<crc32> <json>\n" and writes it to the log.
+// A record that fails to be written or synced is cut off the log again, so that
+// the next record does not follow a torn one that would stop replay early.
+func (s *FileStore) append(rec walRecord) error {
+	if s.wal == nil {
+		return errors.New("file store is closed")
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = strconv.AppendUint(line, uint64(crc32.ChecksumIEEE(payload)), 16)
+	line = append(line, ' ')
+	line = append(line, payload...)
+	line = append(line, '\n')
+
+	offset, err := s.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to find end of log: %w", err)
+	}
+	if _, err := s.wal.Write(line); err != nil {
+		return s.rollback(offset, fmt.Errorf("failed to write log record: %w", err))
+	}
+	if s.opts.Sync == SyncAlways {
+		if err := s.wal.Sync(); err != nil {
+			return s.rollback(offset, fmt.Errorf("failed to sync log: %w", err))
+		}
+	}
+	s.records++
+	return nil
+}
+
+// rollback cuts the log back to offset after a failed append and returns its error.
+// If the log cannot be cut back, the store fails and rejects every later write.
+func (s *FileStore) rollback(offset int64, err error) error {
+	if truncErr := s.wal.Truncate(offset); truncErr != nil {
+		return s.fail(errors.Join(err, fmt.Errorf("failed to truncate log: %w", truncErr)))
+	}
+	if _, seekErr := s.wal.Seek(offset, io.SeekStart); seekErr != nil {
+		return s.fail(errors.Join(err, fmt.Errorf("failed to seek log: %w", seekErr)))
+	}
+	return err
+}
+
+// fail marks the store failed because of err, which it returns
+func (s *FileStore) fail(err error) error {
+	s.failed = fmt.Errorf("file store failed, reopen it to recover: %w", err)
+	slog.Error("file store log is damaged, rejecting writes", "path", filepath.Join(s.dir, walFileName), "error", err)
+	return s.failed
+}
+
+// decodeRecord parses a log line, reporting false if it is torn or corrupted
+func decodeRecord(line []byte) (walRecord, bool) {
+	var rec walRecord
+	sum, payload, found := bytes.Cut(line, []byte{' '})
+	if !found {
+		return rec, false
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || uint32(want) != crc32.ChecksumIEEE(payload) {
+		return rec, false
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, false
+	}
+	return rec, true
+}
+
+// replayWAL applies the log on top of the snapshot and opens it for appending.
+// A torn or corrupted tail left by a crash is truncated away.
+func (s *FileStore) replayWAL() error {
+	path := filepath.Join(s.dir, walFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+
+	var valid int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		rec, ok := decodeRecord(bytes.TrimSuffix(line, []byte{'\n'}))
+		if !ok {
+			break
+		}
+		switch rec.Op {
+		case opPut:
+			if rec.Data != nil {
+				s.receipts[rec.ID] = *rec.Data
+			}
+		case opDelete:
+			delete(s.receipts, rec.ID)
+		case opEntry:
+			if rec.Entry != nil {
+				s.ledger.add(*rec.Entry)
+			}
+		case opTransfer:
+			s.ledger.add(rec.Entries...)
+		case opPutCampaign:
+			if rec.Campaign != nil {
+				s.campaigns[rec.ID] = *rec.Campaign
+			}
+		case opDeleteCampaign:
+			delete(s.campaigns, rec.ID)
+		case opPutRetailer:
+			if rec.Retailer != nil {
+				s.retailers[rec.ID] = *rec.Retailer
+			}
+		case opPutWebhook:
+			if rec.Webhook != nil {
+				s.webhooks[rec.ID] = *rec.Webhook
+			}
+		case opDeleteWebhook:
+			s.deleteWebhook(rec.ID)
+		case opPutDelivery:
+			if rec.Delivery != nil {
+				s.deliveries[rec.ID] = *rec.Delivery
+			}
+		case opDeleteDeliveries:
+			for _, id := range rec.IDs {
+				delete(s.deliveries, id)
+			}
+		case opPutAPIKey:
+			if rec.APIKey != nil {
+				s.apiKeys[rec.ID] = *rec.APIKey
+			}
+		}
+		valid += int64(len(line))
+		s.records++
+	}
+
+	if info, err := f.Stat(); err == nil && info.Size() > valid {
+		slog.Warn("truncating torn tail of file store log", "path", path, "bytes", info.Size()-valid)
+	}
+	slog.Info("replayed file store log", "path", path, "records", s.records)
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to seek log: %w", err)
+	}
+	s.wal = f
+	return nil
+}
+
+// loadSnapshot reads the last compacted state, if any
+func (s *FileStore) loadSnapshot() error {
+	raw, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.receipts); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	raw, err = os.ReadFile(filepath.Join(s.dir, ledgerSnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ledger snapshot: %w", err)
+	}
+	var accounts map[string][]LedgerEntry
+	if err := json.Unmarshal(raw, &accounts); err != nil {
+		return fmt.Errorf("failed to decode ledger snapshot: %w", err)
+	}
+	for _, entries := range accounts {
+		s.ledger.add(entries...)
+	}
+
+	// Snapshots written before campaigns, retailers, webhooks and API keys existed lack their snapshots
+	raw, err = os.ReadFile(filepath.Join(s.dir, campaignSnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read campaign snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.campaigns); err != nil {
+		return fmt.Errorf("failed to decode campaign snapshot: %w", err)
+	}
+
+	raw, err = os.ReadFile(filepath.Join(s.dir, retailerSnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read retailer snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.retailers); err != nil {
+		return fmt.Errorf("failed to decode retailer snapshot: %w", err)
+	}
+
+	raw, err = os.ReadFile(filepath.Join(s.dir, webhookSnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read webhook snapshot: %w", err)
+	}
+	snapshot := webhookSnapshot{Webhooks: s.webhooks, Deliveries: s.deliveries}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode webhook snapshot: %w", err)
+	}
+
+	raw, err = os.ReadFile(filepath.Join(s.dir, apiKeySnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read api key snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.apiKeys); err != nil {
+		return fmt.Errorf("failed to decode api key snapshot: %w", err)
+	}
+	return nil
+}
+
+// maybeCompact compacts the log once it holds CompactEvery records. It runs after a write is
+// logged and applied, so a failure is only logged: the write is committed all the same and
+// compaction is tried again by the next write.
+func (s *FileStore) maybeCompact() {
+	if s.opts.CompactEvery < 0 || s.records < s.opts.CompactEvery {
+		return
+	}
+	if err := s.compact(); err != nil {
+		slog.Error("failed to compact file store log", "path", s.dir, "error", err)
+	}
+}
+
+// compact atomically replaces the snapshots and then empties the log.
+// Replaying a log over a snapshot that already contains it is harmless, ledger
+// entries are applied once by ID, so a crash between the steps loses nothing.
+func (s *FileStore) compact() error {
+	raw, err := json.Marshal(s.receipts)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := s.installSnapshot(snapshotFileName, raw); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(s.ledger.accounts)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger snapshot: %w", err)
+	}
+	if err := s.installSnapshot(ledgerSnapshotFileName, raw); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(s.campaigns)
+	if err != nil {
+		return fmt.Errorf("failed to encode campaign snapshot: %w", err)
+	}
+	if err := s.installSnapshot(campaignSnapshotFileName, raw); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(s.retailers)
+	if err != nil {
+		return fmt.Errorf("failed to encode retailer snapshot: %w", err)
+	}
+	if err := s.installSnapshot(retailerSnapshotFileName, raw); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(webhookSnapshot{Webhooks: s.webhooks, Deliveries: s.deliveries})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook snapshot: %w", err)
+	}
+	if err := s.installSnapshot(webhookSnapshotFileName, raw); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(s.apiKeys)
+	if err != nil {
+		return fmt.Errorf("failed to encode api key snapshot: %w", err)
+	}
+	if err := s.installSnapshot(apiKeySnapshotFileName, raw); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("failed to sync data directory: %w", err)
+	}
+
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		// Later records would be written past the emptied start of the log
+		return s.fail(fmt.Errorf("failed to seek log: %w", err))
+	}
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	s.records = 0
+	return nil
+}
+
+// installSnapshot atomically replaces a snapshot file with raw
+func (s *FileStore) installSnapshot(name string, raw []byte) error {
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := writeFileSync(tmp, raw); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to install %s: %w", name, err)
+	}
+	return nil
+}
+
+// syncLoop flushes the log periodically under SyncInterval
+func (s *FileStore) syncLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.wal != nil {
+				if err := s.wal.Sync(); err != nil {
+					slog.Error("failed to sync file store log", "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,420 @@
+package repo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"receipt-processor/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Run the shared store suite against the file-backed store
+func TestFileStoreTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &StoreTestSuite{newStore: func() Store {
+		store, err := OpenFileStore(t.TempDir(), FileStoreOptions{CompactEvery: 10})
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	}})
+}
+
+func TestFileStoreRecoversAfterReopen(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("id-%d", i)
+		require.NoError(t, store.Put(id, mockReceiptData(id, int64(i))))
+	}
+	require.NoError(t, store.Delete("id-2"))
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	count, err := reopened.Count()
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+
+	data, err := reopened.Get("id-6")
+	require.NoError(t, err)
+	require.Equal(t, mockReceiptData("id-6", 6), data)
+
+	_, err = reopened.Get("id-2")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileStoreRecoversCampaigns(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("c-%d", i)
+		require.NoError(t, store.PutCampaign(models.Campaign{ID: id, Name: id, StartDate: "2022-01-01", EndDate: "2022-01-31", Bonus: int64(i + 1)}))
+	}
+	require.NoError(t, store.DeleteCampaign("c-1"))
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	list, err := reopened.ListCampaigns()
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	_, err = reopened.GetCampaign("c-1")
+	require.ErrorIs(t, err, ErrCampaignNotFound)
+}
+
+func TestFileStoreRecoversWebhooks(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("w-%d", i)
+		require.NoError(t, store.PutWebhook(models.Webhook{ID: id, URL: "https://example.com/" + id, Secret: id}))
+		require.NoError(t, store.PutDelivery(models.Delivery{ID: "d-" + id, WebhookID: id, Status: models.DeliveryDead}))
+	}
+	require.NoError(t, store.DeleteWebhook("w-1"))
+	require.NoError(t, store.PutDelivery(models.Delivery{ID: "d-w-0-done", WebhookID: "w-0", Status: models.DeliverySucceeded}))
+	deleted, err := store.DeleteDeliveries(DeliveryQuery{Status: models.DeliverySucceeded})
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	list, err := reopened.ListWebhooks()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	deliveries, err := reopened.ListDeliveries(DeliveryQuery{})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	_, err = reopened.GetDelivery("d-w-1")
+	require.ErrorIs(t, err, ErrDeliveryNotFound)
+	_, err = reopened.GetDelivery("d-w-0-done")
+	require.ErrorIs(t, err, ErrDeliveryNotFound)
+}
+
+func TestFileStoreRecoversAPIKeys(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("k-%d", i)
+		require.NoError(t, store.PutAPIKey(models.APIKey{ID: id, ClientID: "acme", Hash: id}))
+	}
+	revoked := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	require.NoError(t, store.PutAPIKey(models.APIKey{ID: "k-4", ClientID: "acme", Hash: "k-4", RevokedAt: &revoked}))
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 3})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	list, err := reopened.ListAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, list, 5)
+	key, err := reopened.GetAPIKey("k-4")
+	require.NoError(t, err)
+	require.True(t, key.Revoked())
+}
+
+func TestFileStoreRecoversLedger(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := store.Transfer(Transfer{ID: fmt.Sprintf("t%d", i), Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 10})
+		require.NoError(t, err)
+	}
+	wal, err := os.ReadFile(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+
+	// Crash after the snapshot is written but before the log is emptied
+	require.NoError(t, store.Compact())
+	require.NoError(t, store.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, walFileName), wal, 0o644))
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	entries, err := reopened.Entries("alice")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	balance, err := reopened.Balance("alice")
+	require.NoError(t, err)
+	require.Equal(t, int64(30), balance)
+	_, err = reopened.Transfer(Transfer{ID: "t0", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 10})
+	require.ErrorIs(t, err, ErrDuplicateTransaction)
+}
+
+func TestFileStoreUpgradesSingleEntries(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	// A log written before transfers existed holds single entries
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	for i, points := range []int64{28, 15} {
+		entry := LedgerEntry{ID: fmt.Sprintf("e%d", i), AccountID: "alice", Kind: EntryCredit, Points: points, Balance: 28 + int64(i)*15}
+		require.NoError(t, store.append(walRecord{Op: opEntry, ID: entry.ID, Entry: &entry}))
+	}
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	balance, err := reopened.Balance("alice")
+	require.NoError(t, err)
+	require.Equal(t, int64(43), balance)
+	issued, err := reopened.Entries(IssuedAccount)
+	require.NoError(t, err)
+	require.Len(t, issued, 2)
+	require.Equal(t, int64(-43), issued[1].Balance)
+}
+
+func TestFileStoreTruncatesTornTail(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	require.NoError(t, store.Put("a", mockReceiptData("a", 1)))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash in the middle of appending a record
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`1234abcd {"op":"put","id":"b","da`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+
+	count, err := reopened.Count()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// Writes after recovery must land on a clean log
+	require.NoError(t, reopened.Put("c", mockReceiptData("c", 3)))
+	require.NoError(t, reopened.Close())
+
+	again, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	defer again.Close()
+	_, err = again.Get("c")
+	require.NoError(t, err)
+}
+
+// faultyLog fails writes after writing half of them, syncs and truncates of the log on demand
+type faultyLog struct {
+	*os.File
+	failWrite, failSync, failTruncate bool
+}
+
+func (f *faultyLog) Write(p []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyLog) Sync() error {
+	if f.failSync {
+		return errors.New("sync failed")
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyLog) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return f.File.Truncate(size)
+}
+
+func TestFileStoreRollsBackFailedWrites(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	log := &faultyLog{File: store.wal.(*os.File)}
+	store.wal = log
+	require.NoError(t, store.Put("a", mockReceiptData("a", 1)))
+
+	// Failed writes and syncs leave nothing behind in memory or in the log
+	log.failWrite = true
+	require.Error(t, store.Put("b", mockReceiptData("b", 2)))
+	log.failWrite, log.failSync = false, true
+	require.Error(t, store.Put("c", mockReceiptData("c", 3)))
+	log.failSync = false
+	_, err = store.Get("b")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Writes acknowledged after the failures survive a reopen
+	require.NoError(t, store.Put("d", mockReceiptData("d", 4)))
+	require.NoError(t, store.Close())
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	defer reopened.Close()
+	for _, id := range []string{"a", "d"} {
+		_, err := reopened.Get(id)
+		require.NoError(t, err, "acknowledged receipt %s was lost", id)
+	}
+	for _, id := range []string{"b", "c"} {
+		_, err := reopened.Get(id)
+		require.ErrorIs(t, err, ErrNotFound, "failed receipt %s was stored", id)
+	}
+}
+
+func TestFileStoreFailsWhenRollbackFails(t *testing.T) {
+	t.Parallel()
+	store, err := OpenFileStore(t.TempDir(), FileStoreOptions{CompactEvery: -1})
+	require.NoError(t, err)
+	defer store.Close()
+	log := &faultyLog{File: store.wal.(*os.File), failWrite: true, failTruncate: true}
+	store.wal = log
+
+	require.Error(t, store.Put("a", mockReceiptData("a", 1)))
+
+	// The torn record cannot be cut off, so later writes are rejected even once the disk recovers
+	log.failWrite, log.failTruncate = false, false
+	require.Error(t, store.Put("b", mockReceiptData("b", 2)))
+	require.Error(t, store.Ping(context.Background()))
+	_, err = store.Get("b")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileStoreCompactionFailureKeepsWrites(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 2})
+	require.NoError(t, err)
+	require.NoError(t, store.Put("a", mockReceiptData("a", 1)))
+
+	// A directory in place of the snapshot makes compaction fail after the write was logged
+	require.NoError(t, os.Mkdir(filepath.Join(dir, snapshotFileName), 0o755))
+	require.NoError(t, store.Put("b", mockReceiptData("b", 2)))
+	require.NoError(t, store.Delete("a"))
+	_, err = store.Transfer(Transfer{ID: "t1", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 5})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, snapshotFileName)))
+	reopened, err := OpenFileStore(dir, FileStoreOptions{CompactEvery: 2})
+	require.NoError(t, err)
+	defer reopened.Close()
+	_, err = reopened.Get("b")
+	require.NoError(t, err)
+	_, err = reopened.Get("a")
+	require.ErrorIs(t, err, ErrNotFound)
+	balance, err := reopened.Balance("alice")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), balance)
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, s := range []string{"always", "interval", "never"} {
+		policy, err := ParseSyncPolicy(s)
+		require.NoError(t, err)
+		require.Equal(t, SyncPolicy(s), policy)
+	}
+	_, err := ParseSyncPolicy("sometimes")
+	require.Error(t, err)
+}
+
+const crashDirEnv = "FILE_STORE_CRASH_DIR"
+
+// TestFileStoreCrashWriter is not a real test: TestFileStoreCrashRecovery runs it in a
+// child process which writes receipts forever, printing each ID once Put acknowledged it.
+func TestFileStoreCrashWriter(t *testing.T) {
+	dir := os.Getenv(crashDirEnv)
+	if dir == "" {
+		t.Skip("only runs as the child of TestFileStoreCrashRecovery")
+	}
+	store, err := OpenFileStore(dir, FileStoreOptions{Sync: SyncAlways, CompactEvery: 25})
+	if err != nil {
+		fmt.Println("open:", err)
+		os.Exit(1)
+	}
+	for i := 0; ; i++ {
+		id := fmt.Sprintf("id-%06d", i)
+		if err := store.Put(id, mockReceiptData(id, int64(i))); err != nil {
+			fmt.Println("put:", err)
+			os.Exit(1)
+		}
+		fmt.Println(id)
+	}
+}
+
+func TestFileStoreCrashRecovery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns a child process")
+	}
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFileStoreCrashWriter$")
+	cmd.Env = append(os.Environ(), crashDirEnv+"="+dir)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	// Kill the writer without warning after enough acknowledged writes
+	var acked []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(acked) < 200 {
+		acked = append(acked, scanner.Text())
+	}
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+	require.Len(t, acked, 200, "child exited early: %v", acked)
+
+	store, err := OpenFileStore(dir, FileStoreOptions{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i, id := range acked {
+		data, err := store.Get(id)
+		require.NoError(t, err, "acknowledged receipt %s was lost", id)
+		require.Equal(t, int64(i), data.Point)
+	}
+}
+
+func TestFileStorePing(t *testing.T) {
+	t.Parallel()
+	store, err := OpenFileStore(t.TempDir(), FileStoreOptions{})
+	require.NoError(t, err)
+	require.NoError(t, store.Ping(context.Background()))
+
+	require.NoError(t, store.Close())
+	require.ErrorContains(t, store.Ping(context.Background()), "file store is closed")
+}
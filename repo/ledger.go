@@ -0,0 +1,228 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of LedgerEntry
+const (
+	// EntryCredit awards the points of a receipt to an account
+	EntryCredit = "credit"
+	// EntryReversal takes back the points of a deleted receipt
+	EntryReversal = "reversal"
+	// EntryRedemption spends points of an account
+	EntryRedemption = "redemption"
+	// EntryRedemptionReversal refunds the points of a redemption
+	EntryRedemptionReversal = "redemption_reversal"
+	// EntryRescore adjusts the points of a receipt rescored with another rule set
+	EntryRescore = "rescore"
+)
+
+// System accounts are the other side of every transfer to or from a customer account.
+// Their IDs cannot be used by customer accounts and their balances may be negative.
+const (
+	systemAccountPrefix = "system:"
+	// IssuedAccount is debited with every point awarded for a receipt
+	IssuedAccount = systemAccountPrefix + "issued"
+	// RedeemedAccount is credited with every point spent on a redemption
+	RedeemedAccount = systemAccountPrefix + "redeemed"
+)
+
+// IsSystemAccount reports whether an account ID belongs to a system account
+func IsSystemAccount(id string) bool {
+	return strings.HasPrefix(id, systemAccountPrefix)
+}
+
+// LedgerEntry is a single change to the points balance of an account.
+// The balance of an account is the sum of the Points of its entries, and
+// the entries of a transaction sum to zero.
+type LedgerEntry struct {
+	ID            string    `json:"id"`
+	TransactionID string    `json:"transactionId"`
+	AccountID     string    `json:"accountId"`
+	Kind          string    `json:"kind"`
+	ReceiptID     string    `json:"receiptId,omitempty"`
+	RedemptionID  string    `json:"redemptionId,omitempty"`
+	Points        int64     `json:"points"`
+	Balance       int64     `json:"balance"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Transfer moves points from one account to another. It is recorded as a
+// transaction of two entries, a debit of From and a credit of To.
+type Transfer struct {
+	// ID identifies the transaction, a transfer with an ID already used is rejected
+	ID           string
+	Kind         string
+	From         string
+	To           string
+	Points       int64
+	ReceiptID    string
+	RedemptionID string
+	CreatedAt    time.Time
+}
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrInsufficientFunds is returned when a transfer would make a customer balance negative
+	ErrInsufficientFunds = errors.New("insufficient points")
+	// ErrDuplicateTransaction is returned when a transfer reuses a transaction ID
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+)
+
+// LedgerStore is an append-only double-entry log of points moved between accounts.
+// An account exists once it has an entry. Implementations must be safe for concurrent use.
+type LedgerStore interface {
+	// Transfer atomically records the debit and credit entries of a transfer and returns them
+	// with Balance set. It fails with ErrInsufficientFunds if From is a customer account whose
+	// balance is lower than Points, and with ErrDuplicateTransaction if the ID was already used.
+	Transfer(t Transfer) ([]LedgerEntry, error)
+	// Entries returns the entries of an account in the order they were appended,
+	// returning ErrAccountNotFound if the account has none.
+	Entries(accountID string) ([]LedgerEntry, error)
+	// Balance returns the current balance of an account, returning ErrAccountNotFound if it has no entries.
+	Balance(accountID string) (int64, error)
+}
+
+// Store keeps receipts, the points ledger, campaigns, the retailer registry, webhooks and API keys.
+type Store interface {
+	ReceiptStore
+	LedgerStore
+	CampaignStore
+	RetailerStore
+	WebhookStore
+	APIKeyStore
+}
+
+// Pinger is implemented by stores kept outside the process, which can become unreachable
+type Pinger interface {
+	// Ping fails unless the store can currently serve reads and writes
+	Ping(ctx context.Context) error
+}
+
+// SumPoints recomputes a balance from ledger entries
+func SumPoints(entries []LedgerEntry) int64 {
+	var sum int64
+	for _, entry := range entries {
+		sum += entry.Points
+	}
+	return sum
+}
+
+// validate rejects transfers that cannot be recorded
+func (t Transfer) validate() error {
+	switch {
+	case t.ID == "":
+		return errors.New("transfer has no ID")
+	case t.Points <= 0:
+		return fmt.Errorf("transfer of %d points is not positive", t.Points)
+	case t.From == t.To:
+		return fmt.Errorf("transfer from account %s to itself", t.From)
+	}
+	return nil
+}
+
+// entries builds the debit and credit of the transfer from the current balances
+func (t Transfer) entries(fromBalance, toBalance int64) []LedgerEntry {
+	entry := func(side, accountID string, points, balance int64) LedgerEntry {
+		return LedgerEntry{
+			ID:            t.ID + ":" + side,
+			TransactionID: t.ID,
+			AccountID:     accountID,
+			Kind:          t.Kind,
+			ReceiptID:     t.ReceiptID,
+			RedemptionID:  t.RedemptionID,
+			Points:        points,
+			Balance:       balance + points,
+			CreatedAt:     t.CreatedAt,
+		}
+	}
+	return []LedgerEntry{
+		entry("debit", t.From, -t.Points, fromBalance),
+		entry("credit", t.To, t.Points, toBalance),
+	}
+}
+
+// ledger is the in-memory ledger shared by MemoryStore and FileStore
+type ledger struct {
+	// account ID -> entries in append order
+	accounts map[string][]LedgerEntry
+	// IDs of every entry, to apply each entry once when replaying
+	entryIDs map[string]struct{}
+	// IDs of every transaction, to reject reused IDs
+	transactions map[string]struct{}
+}
+
+func newLedger() ledger {
+	return ledger{
+		accounts:     make(map[string][]LedgerEntry),
+		entryIDs:     make(map[string]struct{}),
+		transactions: make(map[string]struct{}),
+	}
+}
+
+// prepare checks a transfer against the ledger and returns its entries without applying them
+func (l *ledger) prepare(t Transfer) ([]LedgerEntry, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if _, exists := l.transactions[t.ID]; exists {
+		return nil, ErrDuplicateTransaction
+	}
+	fromBalance := l.lastBalance(t.From)
+	if !IsSystemAccount(t.From) && fromBalance < t.Points {
+		return nil, ErrInsufficientFunds
+	}
+	return t.entries(fromBalance, l.lastBalance(t.To)), nil
+}
+
+// add appends entries unless an entry with the same ID was already added.
+// Single entries written before transfers existed get their issuing counterpart.
+func (l *ledger) add(entries ...LedgerEntry) {
+	for _, entry := range entries {
+		if _, exists := l.entryIDs[entry.ID]; exists {
+			continue
+		}
+		if entry.TransactionID == "" {
+			entry.TransactionID = entry.ID
+			counterpart := entry
+			counterpart.ID = entry.ID + ":issuer"
+			counterpart.AccountID = IssuedAccount
+			counterpart.Points = -entry.Points
+			counterpart.Balance = l.lastBalance(IssuedAccount) - entry.Points
+			l.add(entry, counterpart)
+			continue
+		}
+		l.entryIDs[entry.ID] = struct{}{}
+		l.transactions[entry.TransactionID] = struct{}{}
+		l.accounts[entry.AccountID] = append(l.accounts[entry.AccountID], entry)
+	}
+}
+
+// lastBalance returns the balance of an account, zero if it has no entries
+func (l *ledger) lastBalance(accountID string) int64 {
+	entries := l.accounts[accountID]
+	if len(entries) == 0 {
+		return 0
+	}
+	return entries[len(entries)-1].Balance
+}
+
+func (l *ledger) entries(accountID string) ([]LedgerEntry, error) {
+	entries := l.accounts[accountID]
+	if len(entries) == 0 {
+		return nil, ErrAccountNotFound
+	}
+	return append([]LedgerEntry(nil), entries...), nil
+}
+
+func (l *ledger) balance(accountID string) (int64, error) {
+	if len(l.accounts[accountID]) == 0 {
+		return 0, ErrAccountNotFound
+	}
+	return l.lastBalance(accountID), nil
+}
@@ -0,0 +1,331 @@
+package repo
+
+import (
+	"receipt-processor/models"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps receipts, the ledger, campaigns, retailers, webhooks and API keys in maps guarded by a mutex.
+// Data is lost when the process exits.
+type MemoryStore struct {
+	mu sync.RWMutex
+	// id -> ReceiptData
+	receipts map[string]ReceiptData
+	ledger   ledger
+	// id -> Campaign
+	campaigns map[string]models.Campaign
+	// id -> Retailer
+	retailers map[string]models.Retailer
+	// id -> Webhook
+	webhooks map[string]models.Webhook
+	// id -> Delivery
+	deliveries map[string]models.Delivery
+	// id -> APIKey
+	apiKeys map[string]models.APIKey
+}
+
+// NewMemoryStore returns an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		receipts:   make(map[string]ReceiptData),
+		ledger:     newLedger(),
+		campaigns:  make(map[string]models.Campaign),
+		retailers:  make(map[string]models.Retailer),
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.Delivery),
+		apiKeys:    make(map[string]models.APIKey),
+	}
+}
+
+// Retrieves a ReceiptData by ID.
+func (s *MemoryStore) Get(id string) (ReceiptData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ReceiptData{}, ErrNotFound
+	}
+	return data, nil
+}
+
+// Updates or inserts a ReceiptData by ID.
+func (s *MemoryStore) Put(id string, data ReceiptData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts[id] = data
+	return nil
+}
+
+// Sets the RetailerID of a ReceiptData by ID.
+func (s *MemoryStore) AssignRetailer(id, retailerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ErrNotFound
+	}
+	data.Receipt.RetailerID = retailerID
+	s.receipts[id] = data
+	return nil
+}
+
+// Sets the points, rule version and campaigns of a ReceiptData by ID.
+func (s *MemoryStore) AssignScore(id string, points int64, ruleVersion string, campaigns []models.AppliedCampaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.receipts[id]
+	if !exists {
+		return ErrNotFound
+	}
+	data.Point, data.RuleVersion, data.Campaigns = points, ruleVersion, campaigns
+	s.receipts[id] = data
+	return nil
+}
+
+// Deletes a ReceiptData by ID.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.receipts[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.receipts, id)
+	return nil
+}
+
+// Lists the ReceiptData selected by the query ordered by ID.
+func (s *MemoryStore) List(q ListQuery) ([]ReceiptData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return selectPage(s.receipts, q), nil
+}
+
+// Counts the stored receipts.
+func (s *MemoryStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.receipts), nil
+}
+
+// Records the entries of a Transfer.
+func (s *MemoryStore) Transfer(t Transfer) ([]LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.ledger.prepare(t)
+	if err != nil {
+		return nil, err
+	}
+	s.ledger.add(entries...)
+	return entries, nil
+}
+
+// Lists the LedgerEntry values of an account in append order.
+func (s *MemoryStore) Entries(accountID string) ([]LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ledger.entries(accountID)
+}
+
+// Returns the balance of an account.
+func (s *MemoryStore) Balance(accountID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ledger.balance(accountID)
+}
+
+// Retrieves a Campaign by ID.
+func (s *MemoryStore) GetCampaign(id string) (models.Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, exists := s.campaigns[id]
+	if !exists {
+		return models.Campaign{}, ErrCampaignNotFound
+	}
+	return c, nil
+}
+
+// Updates or inserts a Campaign by ID.
+func (s *MemoryStore) PutCampaign(c models.Campaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.campaigns[c.ID] = c
+	return nil
+}
+
+// Deletes a Campaign by ID.
+func (s *MemoryStore) DeleteCampaign(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.campaigns[id]; !exists {
+		return ErrCampaignNotFound
+	}
+	delete(s.campaigns, id)
+	return nil
+}
+
+// Lists every Campaign ordered by start date, then ID.
+func (s *MemoryStore) ListCampaigns() ([]models.Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedCampaigns(s.campaigns), nil
+}
+
+// Retrieves a Retailer by ID.
+func (s *MemoryStore) GetRetailer(id string) (models.Retailer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, exists := s.retailers[id]
+	if !exists {
+		return models.Retailer{}, ErrRetailerNotFound
+	}
+	return r, nil
+}
+
+// Updates or inserts a Retailer by ID.
+func (s *MemoryStore) PutRetailer(r models.Retailer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retailers[r.ID] = r
+	return nil
+}
+
+// Lists every Retailer ordered by ID.
+func (s *MemoryStore) ListRetailers() ([]models.Retailer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedRetailers(s.retailers), nil
+}
+
+// Retrieves a Webhook by ID.
+func (s *MemoryStore) GetWebhook(id string) (models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, exists := s.webhooks[id]
+	if !exists {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+// Updates or inserts a Webhook by ID.
+func (s *MemoryStore) PutWebhook(w models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks[w.ID] = w
+	return nil
+}
+
+// Deletes a Webhook and its Delivery history by ID.
+func (s *MemoryStore) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// Lists every Webhook ordered by creation time, then ID.
+func (s *MemoryStore) ListWebhooks() ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedWebhooks(s.webhooks), nil
+}
+
+// Retrieves a Delivery by ID.
+func (s *MemoryStore) GetDelivery(id string) (models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, exists := s.deliveries[id]
+	if !exists {
+		return models.Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// Updates or inserts a Delivery by ID.
+func (s *MemoryStore) PutDelivery(d models.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.webhooks[d.WebhookID]; !exists {
+		return ErrWebhookNotFound
+	}
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// Lists the Delivery values matching the query, newest first.
+func (s *MemoryStore) ListDeliveries(q DeliveryQuery) ([]models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return selectDeliveries(s.deliveries, q), nil
+}
+
+// Deletes the Delivery values matching the query.
+func (s *MemoryStore) DeleteDeliveries(q DeliveryQuery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for id, d := range s.deliveries {
+		if q.Matches(d) {
+			delete(s.deliveries, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Retrieves an APIKey by ID.
+func (s *MemoryStore) GetAPIKey(id string) (models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, exists := s.apiKeys[id]
+	if !exists {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+// Updates or inserts an APIKey by ID.
+func (s *MemoryStore) PutAPIKey(k models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKeys[k.ID] = k
+	return nil
+}
+
+// Lists every APIKey ordered by creation time, then ID.
+func (s *MemoryStore) ListAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedAPIKeys(s.apiKeys), nil
+}
+
+// selectPage copies the map values selected by the query into a slice ordered by receipt ID.
+func selectPage(receipts map[string]ReceiptData, q ListQuery) []ReceiptData {
+	ids := make([]string, 0, len(receipts))
+	for id := range receipts {
+		if id > q.After {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	list := make([]ReceiptData, 0)
+	for _, id := range ids {
+		if q.Limit > 0 && len(list) == q.Limit {
+			break
+		}
+		if data := receipts[id]; q.Matches(data) {
+			list = append(list, data)
+		}
+	}
+	return list
+}
@@ -0,0 +1,7 @@
+ALTER TABLE items ADD COLUMN price TEXT NOT NULL DEFAULT '0.00';
+UPDATE items SET price = printf('%d.%02d', price_cents / 100, price_cents % 100);
+ALTER TABLE items DROP COLUMN price_cents;
+
+ALTER TABLE receipts ADD COLUMN total TEXT NOT NULL DEFAULT '0.00';
+UPDATE receipts SET total = printf('%d.%02d', total_cents / 100, total_cents % 100);
+ALTER TABLE receipts DROP COLUMN total_cents;
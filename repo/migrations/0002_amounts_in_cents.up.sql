@@ -0,0 +1,8 @@
+-- Store money amounts as exact integer cents instead of decimal strings
+ALTER TABLE receipts ADD COLUMN total_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE receipts SET total_cents = CAST(ROUND(CAST(total AS REAL) * 100) AS INTEGER);
+ALTER TABLE receipts DROP COLUMN total;
+
+ALTER TABLE items ADD COLUMN price_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE items SET price_cents = CAST(ROUND(CAST(price AS REAL) * 100) AS INTEGER);
+ALTER TABLE items DROP COLUMN price;
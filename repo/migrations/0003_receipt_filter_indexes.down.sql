@@ -0,0 +1,2 @@
+DROP INDEX receipts_purchase_date;
+DROP INDEX receipts_retailer;
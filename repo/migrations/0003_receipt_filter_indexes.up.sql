@@ -0,0 +1,3 @@
+-- Support the filters of the receipt listing
+CREATE INDEX receipts_retailer ON receipts (retailer COLLATE NOCASE);
+CREATE INDEX receipts_purchase_date ON receipts (purchase_date);
@@ -0,0 +1,4 @@
+DROP INDEX ledger_entries_account;
+DROP TABLE ledger_entries;
+
+ALTER TABLE receipts DROP COLUMN account_id;
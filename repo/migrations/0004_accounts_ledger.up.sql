@@ -0,0 +1,15 @@
+-- Receipts may be submitted on behalf of an account, which is credited their points
+ALTER TABLE receipts ADD COLUMN account_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE ledger_entries (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    id         TEXT    NOT NULL UNIQUE,
+    account_id TEXT    NOT NULL,
+    kind       TEXT    NOT NULL,
+    receipt_id TEXT    NOT NULL DEFAULT '',
+    points     INTEGER NOT NULL,
+    balance    INTEGER NOT NULL,
+    created_at TEXT    NOT NULL
+);
+
+CREATE INDEX ledger_entries_account ON ledger_entries (account_id, seq);
@@ -0,0 +1,5 @@
+DROP INDEX ledger_entries_transaction;
+DELETE FROM ledger_entries WHERE account_id LIKE 'system:%';
+
+ALTER TABLE ledger_entries DROP COLUMN redemption_id;
+ALTER TABLE ledger_entries DROP COLUMN transaction_id;
@@ -0,0 +1,13 @@
+-- Record every ledger change as a transaction of balancing entries
+ALTER TABLE ledger_entries ADD COLUMN transaction_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE ledger_entries ADD COLUMN redemption_id TEXT NOT NULL DEFAULT '';
+UPDATE ledger_entries SET transaction_id = id;
+
+-- Existing single entries are balanced by the account issuing the points
+INSERT INTO ledger_entries (id, transaction_id, account_id, kind, receipt_id, points, balance, created_at)
+SELECT id || ':issuer', id, 'system:issued', kind, receipt_id, -points,
+       -SUM(points) OVER (ORDER BY seq ROWS UNBOUNDED PRECEDING), created_at
+FROM ledger_entries
+ORDER BY seq;
+
+CREATE UNIQUE INDEX ledger_entries_transaction ON ledger_entries (transaction_id, account_id);
@@ -0,0 +1,2 @@
+-- Remember which rule set version awarded the points, empty for receipts scored before versions were recorded
+ALTER TABLE receipts ADD COLUMN rule_version TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE receipts DROP COLUMN campaigns;
+
+DROP TABLE campaigns;
@@ -0,0 +1,15 @@
+CREATE TABLE campaigns (
+    id            TEXT    PRIMARY KEY,
+    name          TEXT    NOT NULL,
+    start_date    TEXT    NOT NULL,
+    end_date      TEXT    NOT NULL,
+    retailer      TEXT    NOT NULL DEFAULT '',
+    item_contains TEXT    NOT NULL DEFAULT '',
+    multiplier    REAL    NOT NULL DEFAULT 0,
+    bonus         INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX campaigns_start_date ON campaigns (start_date, id);
+
+-- The campaigns applied to a receipt, a JSON array recording the terms each was applied under
+ALTER TABLE receipts ADD COLUMN campaigns TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,5 @@
+DROP INDEX receipts_retailer_id;
+
+ALTER TABLE receipts DROP COLUMN retailer_id;
+
+DROP TABLE retailers;
@@ -0,0 +1,11 @@
+CREATE TABLE retailers (
+    id      TEXT PRIMARY KEY,
+    name    TEXT NOT NULL,
+    -- JSON array of the alternative names matched to the retailer
+    aliases TEXT NOT NULL DEFAULT '[]'
+);
+
+-- The canonical retailer a receipt was matched to, empty if it matched none
+ALTER TABLE receipts ADD COLUMN retailer_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX receipts_retailer_id ON receipts (retailer_id, id);
@@ -0,0 +1,23 @@
+CREATE TABLE webhooks (
+    id         TEXT    PRIMARY KEY,
+    url        TEXT    NOT NULL,
+    -- JSON array of the event types delivered, every type if empty
+    events     TEXT    NOT NULL DEFAULT '[]',
+    secret     TEXT    NOT NULL,
+    -- Unix nanoseconds
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id         TEXT    PRIMARY KEY,
+    webhook_id TEXT    NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    status     TEXT    NOT NULL,
+    -- The delivered event and every attempt to deliver it, as JSON
+    event      TEXT    NOT NULL,
+    attempts   TEXT    NOT NULL DEFAULT '[]',
+    -- Unix nanoseconds
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX receipts_owner;
+
+ALTER TABLE receipts DROP COLUMN owner;
+
+DROP TABLE api_keys;
@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+    id         TEXT    PRIMARY KEY,
+    client_id  TEXT    NOT NULL,
+    name       TEXT    NOT NULL DEFAULT '',
+    admin      INTEGER NOT NULL DEFAULT 0,
+    -- Hex SHA-256 of the secret part of the key, the key itself is never stored
+    hash       TEXT    NOT NULL,
+    -- Unix nanoseconds, revoked_at is NULL while the key is active
+    created_at INTEGER NOT NULL,
+    revoked_at INTEGER
+);
+
+-- The client that submitted a receipt, empty for receipts submitted unauthenticated
+ALTER TABLE receipts ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX receipts_owner ON receipts (owner, id);
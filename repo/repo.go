@@ -4,36 +4,85 @@ package repo
 import (
 	"errors"
 	"receipt-processor/models"
-	"sync"
+	"strings"
 )
 
 type ReceiptData struct {
 	Receipt models.Receipt
 	Point   int64
+	// RuleVersion is the version of the rule set that awarded Point, empty if unknown
+	RuleVersion string
+	// Campaigns are the promotions whose points are included in Point
+	Campaigns []models.AppliedCampaign
+	// Owner is the ID of the client that submitted the receipt, empty if it was submitted unauthenticated
+	Owner string
 }
 
-var (
-	mu sync.Mutex
-	// Receipts is the main data storage
-	// id -> ReceiptData
-	Receipts    = make(map[string]ReceiptData)
-	ErrNotFound = errors.New("receipt not found")
-)
+var ErrNotFound = errors.New("receipt not found")
+
+// ListQuery selects a page of receipts. The zero value selects every receipt.
+type ListQuery struct {
+	// After skips receipts whose ID sorts before or equal to it, to continue a previous page
+	After string
+	// Limit is the maximum number of receipts returned, zero means no limit
+	Limit int
+	// Retailer keeps receipts from this retailer, compared case-insensitively
+	Retailer string
+	// PurchasedFrom and PurchasedTo keep receipts purchased within the inclusive yyyy-mm-dd range
+	PurchasedFrom string
+	PurchasedTo   string
+	// MinPoints and MaxPoints keep receipts awarded points within the inclusive range
+	MinPoints *int64
+	MaxPoints *int64
+	// RuleVersion keeps receipts scored by this rule set version
+	RuleVersion *string
+	// RetailerID keeps receipts matched to this canonical retailer, or matched to none if empty
+	RetailerID *string
+	// Owner keeps receipts submitted by this client
+	Owner string
+}
 
-// Retrieves a ReceiptData by ID.
-func GetReceiptData(id string) (ReceiptData, error) {
-	mu.Lock()
-	defer mu.Unlock()
-	data, exists := Receipts[id]
-	if !exists {
-		return ReceiptData{}, ErrNotFound
+// Matches reports whether data passes the filters of the query, ignoring After and Limit
+func (q ListQuery) Matches(data ReceiptData) bool {
+	r := data.Receipt
+	switch {
+	case q.Retailer != "" && !strings.EqualFold(r.Retailer, q.Retailer):
+		return false
+	case q.PurchasedFrom != "" && r.PurchaseDate < q.PurchasedFrom:
+		return false
+	case q.PurchasedTo != "" && r.PurchaseDate > q.PurchasedTo:
+		return false
+	case q.MinPoints != nil && data.Point < *q.MinPoints:
+		return false
+	case q.MaxPoints != nil && data.Point > *q.MaxPoints:
+		return false
+	case q.RuleVersion != nil && data.RuleVersion != *q.RuleVersion:
+		return false
+	case q.RetailerID != nil && r.RetailerID != *q.RetailerID:
+		return false
+	case q.Owner != "" && data.Owner != q.Owner:
+		return false
 	}
-	return data, nil
+	return true
 }
 
-// Updates or inserts a ReceiptData by ID.
-func UpdateReceiptData(id string, data ReceiptData) {
-	mu.Lock()
-	defer mu.Unlock()
-	Receipts[id] = data
+// ReceiptStore is the storage backend for processed receipts.
+// Implementations must be safe for concurrent use.
+type ReceiptStore interface {
+	// Get retrieves a ReceiptData by ID, returning ErrNotFound if it does not exist.
+	Get(id string) (ReceiptData, error)
+	// Put updates or inserts a ReceiptData by ID.
+	Put(id string, data ReceiptData) error
+	// AssignRetailer sets the RetailerID of a stored receipt leaving the rest of it unchanged,
+	// returning ErrNotFound if it does not exist.
+	AssignRetailer(id, retailerID string) error
+	// AssignScore sets the points, rule version and campaigns of a stored receipt leaving the rest of it
+	// unchanged, returning ErrNotFound if it does not exist.
+	AssignScore(id string, points int64, ruleVersion string, campaigns []models.AppliedCampaign) error
+	// Delete removes a ReceiptData by ID, returning ErrNotFound if it does not exist.
+	Delete(id string) error
+	// List returns the stored ReceiptData selected by the query ordered by ID.
+	List(q ListQuery) ([]ReceiptData, error)
+	// Count returns the number of stored receipts.
+	Count() (int, error)
 }
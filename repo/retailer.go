@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"errors"
+	"receipt-processor/models"
+	"sort"
+)
+
+var ErrRetailerNotFound = errors.New("retailer not found")
+
+// RetailerStore keeps the registry of canonical retailers.
+// Implementations must be safe for concurrent use.
+type RetailerStore interface {
+	// GetRetailer retrieves a retailer by ID, returning ErrRetailerNotFound if it does not exist.
+	GetRetailer(id string) (models.Retailer, error)
+	// PutRetailer updates or inserts a retailer by its ID.
+	PutRetailer(r models.Retailer) error
+	// ListRetailers returns every retailer ordered by ID.
+	ListRetailers() ([]models.Retailer, error)
+}
+
+// sortedRetailers copies the map values into a slice ordered by ID
+func sortedRetailers(retailers map[string]models.Retailer) []models.Retailer {
+	list := make([]models.Retailer, 0, len(retailers))
+	for _, r := range retailers {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
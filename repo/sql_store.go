@@ -0,0 +1,898 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"receipt-processor/models"
+	"strings"
+	"time"
+
+	// Registers the pure-Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLStore keeps receipts in a relational database, with a receipts table
+// and an items table keyed by receipt ID, next to ledger_entries, campaigns, retailers, webhooks,
+// webhook_deliveries and api_keys tables.
+// Queries are written for SQLite.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore opens the database and migrates its schema to the latest version.
+func OpenSQLStore(driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if driver == "sqlite" {
+		// SQLite allows a single writer, serialize access instead of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+
+	store, err := NewSQLStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewSQLStore wraps an open database and migrates its schema to the latest version.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to reach database: %w", err)
+	}
+	if err := MigrateUp(db); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Retrieves a ReceiptData by ID.
+func (s *SQLStore) Get(id string) (ReceiptData, error) {
+	row := s.db.QueryRow(
+		`SELECT id, account_id, retailer, retailer_id, purchase_date, purchase_time, total_cents, points, rule_version, campaigns, owner FROM receipts WHERE id = ?`, id)
+	data, err := scanReceipt(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReceiptData{}, ErrNotFound
+	}
+	if err != nil {
+		return ReceiptData{}, fmt.Errorf("failed to query receipt: %w", err)
+	}
+
+	items, err := s.queryItems(`WHERE receipt_id = ?`, id)
+	if err != nil {
+		return ReceiptData{}, err
+	}
+	data.Receipt.Items = items[id]
+	return data, nil
+}
+
+// Updates or inserts a ReceiptData by ID, replacing its items.
+func (s *SQLStore) Put(id string, data ReceiptData) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	campaigns, err := encodeCampaigns(data.Campaigns)
+	if err != nil {
+		return err
+	}
+	receipt := data.Receipt
+	_, err = tx.Exec(`
+		INSERT INTO receipts (id, account_id, retailer, retailer_id, purchase_date, purchase_time, total_cents, points, rule_version, campaigns, owner)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			account_id = excluded.account_id,
+			retailer = excluded.retailer,
+			retailer_id = excluded.retailer_id,
+			purchase_date = excluded.purchase_date,
+			purchase_time = excluded.purchase_time,
+			total_cents = excluded.total_cents,
+			points = excluded.points,
+			rule_version = excluded.rule_version,
+			campaigns = excluded.campaigns,
+			owner = excluded.owner`,
+		id, receipt.AccountID, receipt.Retailer, receipt.RetailerID, receipt.PurchaseDate, receipt.PurchaseTime, receipt.Total.Cents(), data.Point,
+		data.RuleVersion, campaigns, data.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to upsert receipt: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM items WHERE receipt_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to replace items: %w", err)
+	}
+	for i, item := range receipt.Items {
+		_, err := tx.Exec(`INSERT INTO items (receipt_id, position, short_description, price_cents) VALUES (?, ?, ?, ?)`,
+			id, i, item.ShortDescription, item.Price.Cents())
+		if err != nil {
+			return fmt.Errorf("failed to insert item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit receipt: %w", err)
+	}
+	return nil
+}
+
+// Sets the RetailerID of a ReceiptData by ID.
+func (s *SQLStore) AssignRetailer(id, retailerID string) error {
+	res, err := s.db.Exec(`UPDATE receipts SET retailer_id = ? WHERE id = ?`, retailerID, id)
+	if err != nil {
+		return fmt.Errorf("failed to assign retailer: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to assign retailer: %w", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Sets the points, rule version and campaigns of a ReceiptData by ID.
+func (s *SQLStore) AssignScore(id string, points int64, ruleVersion string, campaigns []models.AppliedCampaign) error {
+	encoded, err := encodeCampaigns(campaigns)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE receipts SET points = ?, rule_version = ?, campaigns = ? WHERE id = ?`, points, ruleVersion, encoded, id)
+	if err != nil {
+		return fmt.Errorf("failed to assign score: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to assign score: %w", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Deletes a ReceiptData and its items by ID.
+func (s *SQLStore) Delete(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM items WHERE receipt_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete items: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM receipts WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete receipt: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete receipt: %w", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete: %w", err)
+	}
+	return nil
+}
+
+// Lists the ReceiptData selected by the query ordered by ID.
+func (s *SQLStore) List(q ListQuery) ([]ReceiptData, error) {
+	where, args := listConditions(q)
+	query := `SELECT id, account_id, retailer, retailer_id, purchase_date, purchase_time, total_cents, points, rule_version, campaigns, owner FROM receipts` + where + ` ORDER BY id`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReceiptData
+	for rows.Next() {
+		data, err := scanReceipt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		list = append(list, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+
+	if len(list) == 0 {
+		return list, nil
+	}
+	itemsWhere := ""
+	var itemArgs []any
+	if q != (ListQuery{}) {
+		// Only load the items of the selected receipts
+		itemsWhere = `WHERE receipt_id IN (?` + strings.Repeat(`, ?`, len(list)-1) + `)`
+		for _, data := range list {
+			itemArgs = append(itemArgs, data.Receipt.ID)
+		}
+	}
+	items, err := s.queryItems(itemsWhere, itemArgs...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Receipt.Items = items[list[i].Receipt.ID]
+	}
+	return list, nil
+}
+
+// listConditions translates the filters of a query into a WHERE clause and its arguments
+func listConditions(q ListQuery) (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	if q.After != "" {
+		add(`id > ?`, q.After)
+	}
+	if q.Retailer != "" {
+		add(`retailer = ? COLLATE NOCASE`, q.Retailer)
+	}
+	if q.PurchasedFrom != "" {
+		add(`purchase_date >= ?`, q.PurchasedFrom)
+	}
+	if q.PurchasedTo != "" {
+		add(`purchase_date <= ?`, q.PurchasedTo)
+	}
+	if q.MinPoints != nil {
+		add(`points >= ?`, *q.MinPoints)
+	}
+	if q.MaxPoints != nil {
+		add(`points <= ?`, *q.MaxPoints)
+	}
+	if q.RuleVersion != nil {
+		add(`rule_version = ?`, *q.RuleVersion)
+	}
+	if q.RetailerID != nil {
+		add(`retailer_id = ?`, *q.RetailerID)
+	}
+	if q.Owner != "" {
+		add(`owner = ?`, q.Owner)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
+
+// Records the entries of a Transfer in one database transaction.
+func (s *SQLStore) Transfer(t Transfer) ([]LedgerEntry, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ledger_entries WHERE transaction_id = ?)`, t.ID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to query transaction: %w", err)
+	}
+	if exists {
+		return nil, ErrDuplicateTransaction
+	}
+	fromBalance, err := lastBalance(tx, t.From)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSystemAccount(t.From) && fromBalance < t.Points {
+		return nil, ErrInsufficientFunds
+	}
+	toBalance, err := lastBalance(tx, t.To)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := t.entries(fromBalance, toBalance)
+	for _, entry := range entries {
+		_, err = tx.Exec(`
+			INSERT INTO ledger_entries (id, transaction_id, account_id, kind, receipt_id, redemption_id, points, balance, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			entry.ID, entry.TransactionID, entry.AccountID, entry.Kind, entry.ReceiptID, entry.RedemptionID,
+			entry.Points, entry.Balance, entry.CreatedAt.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert ledger entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transfer: %w", err)
+	}
+	return entries, nil
+}
+
+// lastBalance reads the balance of an account within a transaction, zero if it has no entries
+func lastBalance(tx *sql.Tx, accountID string) (int64, error) {
+	var balance int64
+	err := tx.QueryRow(`SELECT balance FROM ledger_entries WHERE account_id = ? ORDER BY seq DESC LIMIT 1`,
+		accountID).Scan(&balance)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to query balance: %w", err)
+	}
+	return balance, nil
+}
+
+// Lists the LedgerEntry values of an account in append order.
+func (s *SQLStore) Entries(accountID string) ([]LedgerEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, transaction_id, account_id, kind, receipt_id, redemption_id, points, balance, created_at
+		FROM ledger_entries WHERE account_id = ? ORDER BY seq`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var entry LedgerEntry
+		var createdAt string
+		err := rows.Scan(&entry.ID, &entry.TransactionID, &entry.AccountID, &entry.Kind, &entry.ReceiptID, &entry.RedemptionID,
+			&entry.Points, &entry.Balance, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		if entry.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, fmt.Errorf("failed to parse ledger entry time: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ledger entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrAccountNotFound
+	}
+	return entries, nil
+}
+
+// Returns the balance of an account.
+func (s *SQLStore) Balance(accountID string) (int64, error) {
+	var balance int64
+	err := s.db.QueryRow(`SELECT balance FROM ledger_entries WHERE account_id = ? ORDER BY seq DESC LIMIT 1`,
+		accountID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance: %w", err)
+	}
+	return balance, nil
+}
+
+// Retrieves a Campaign by ID.
+func (s *SQLStore) GetCampaign(id string) (models.Campaign, error) {
+	row := s.db.QueryRow(`
+		SELECT id, name, start_date, end_date, retailer, item_contains, multiplier, bonus
+		FROM campaigns WHERE id = ?`, id)
+	c, err := scanCampaign(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Campaign{}, ErrCampaignNotFound
+	}
+	if err != nil {
+		return models.Campaign{}, fmt.Errorf("failed to query campaign: %w", err)
+	}
+	return c, nil
+}
+
+// Updates or inserts a Campaign by ID.
+func (s *SQLStore) PutCampaign(c models.Campaign) error {
+	_, err := s.db.Exec(`
+		INSERT INTO campaigns (id, name, start_date, end_date, retailer, item_contains, multiplier, bonus)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			start_date = excluded.start_date,
+			end_date = excluded.end_date,
+			retailer = excluded.retailer,
+			item_contains = excluded.item_contains,
+			multiplier = excluded.multiplier,
+			bonus = excluded.bonus`,
+		c.ID, c.Name, c.StartDate, c.EndDate, c.Retailer, c.ItemContains, c.Multiplier, c.Bonus)
+	if err != nil {
+		return fmt.Errorf("failed to upsert campaign: %w", err)
+	}
+	return nil
+}
+
+// Deletes a Campaign by ID.
+func (s *SQLStore) DeleteCampaign(id string) error {
+	res, err := s.db.Exec(`DELETE FROM campaigns WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	} else if n == 0 {
+		return ErrCampaignNotFound
+	}
+	return nil
+}
+
+// Lists every Campaign ordered by start date, then ID.
+func (s *SQLStore) ListCampaigns() ([]models.Campaign, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, start_date, end_date, retailer, item_contains, multiplier, bonus
+		FROM campaigns ORDER BY start_date, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Campaign, 0)
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	return list, nil
+}
+
+func scanCampaign(row rowScanner) (models.Campaign, error) {
+	var c models.Campaign
+	err := row.Scan(&c.ID, &c.Name, &c.StartDate, &c.EndDate, &c.Retailer, &c.ItemContains, &c.Multiplier, &c.Bonus)
+	return c, err
+}
+
+// Retrieves a Retailer by ID.
+func (s *SQLStore) GetRetailer(id string) (models.Retailer, error) {
+	r, err := scanRetailer(s.db.QueryRow(`SELECT id, name, aliases FROM retailers WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Retailer{}, ErrRetailerNotFound
+	}
+	if err != nil {
+		return models.Retailer{}, fmt.Errorf("failed to query retailer: %w", err)
+	}
+	return r, nil
+}
+
+// Updates or inserts a Retailer by ID.
+func (s *SQLStore) PutRetailer(r models.Retailer) error {
+	aliases, err := json.Marshal(r.Aliases)
+	if err != nil {
+		return fmt.Errorf("failed to encode retailer aliases: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO retailers (id, name, aliases) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, aliases = excluded.aliases`,
+		r.ID, r.Name, string(aliases))
+	if err != nil {
+		return fmt.Errorf("failed to upsert retailer: %w", err)
+	}
+	return nil
+}
+
+// Lists every Retailer ordered by ID.
+func (s *SQLStore) ListRetailers() ([]models.Retailer, error) {
+	rows, err := s.db.Query(`SELECT id, name, aliases FROM retailers ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retailers: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Retailer, 0)
+	for rows.Next() {
+		r, err := scanRetailer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan retailer: %w", err)
+		}
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query retailers: %w", err)
+	}
+	return list, nil
+}
+
+func scanRetailer(row rowScanner) (models.Retailer, error) {
+	var r models.Retailer
+	var aliases string
+	if err := row.Scan(&r.ID, &r.Name, &aliases); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal([]byte(aliases), &r.Aliases); err != nil {
+		return r, fmt.Errorf("failed to decode retailer aliases: %w", err)
+	}
+	return r, nil
+}
+
+// Retrieves a Webhook by ID.
+func (s *SQLStore) GetWebhook(id string) (models.Webhook, error) {
+	w, err := scanWebhook(s.db.QueryRow(`SELECT id, url, events, secret, created_at FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("failed to query webhook: %w", err)
+	}
+	return w, nil
+}
+
+// Updates or inserts a Webhook by ID.
+func (s *SQLStore) PutWebhook(w models.Webhook) error {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook events: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO webhooks (id, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			url = excluded.url,
+			events = excluded.events,
+			secret = excluded.secret,
+			created_at = excluded.created_at`,
+		w.ID, w.URL, string(events), w.Secret, w.CreatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to upsert webhook: %w", err)
+	}
+	return nil
+}
+
+// Deletes a Webhook and its Delivery history by ID.
+func (s *SQLStore) DeleteWebhook(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete: %w", err)
+	}
+	return nil
+}
+
+// Lists every Webhook ordered by creation time, then ID.
+func (s *SQLStore) ListWebhooks() ([]models.Webhook, error) {
+	rows, err := s.db.Query(`SELECT id, url, events, secret, created_at FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		list = append(list, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	return list, nil
+}
+
+func scanWebhook(row rowScanner) (models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	var createdAt int64
+	if err := row.Scan(&w.ID, &w.URL, &events, &w.Secret, &createdAt); err != nil {
+		return w, err
+	}
+	if err := json.Unmarshal([]byte(events), &w.Events); err != nil {
+		return w, fmt.Errorf("failed to decode webhook events: %w", err)
+	}
+	w.CreatedAt = time.Unix(0, createdAt).UTC()
+	return w, nil
+}
+
+// Retrieves a Delivery by ID.
+func (s *SQLStore) GetDelivery(id string) (models.Delivery, error) {
+	d, err := scanDelivery(s.db.QueryRow(`
+		SELECT id, webhook_id, status, event, attempts, created_at
+		FROM webhook_deliveries WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Delivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("failed to query delivery: %w", err)
+	}
+	return d, nil
+}
+
+// Updates or inserts a Delivery by ID.
+func (s *SQLStore) PutDelivery(d models.Delivery) error {
+	event, err := json.Marshal(d.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery event: %w", err)
+	}
+	attempts := d.Attempts
+	if attempts == nil {
+		attempts = []models.DeliveryAttempt{}
+	}
+	encodedAttempts, err := json.Marshal(attempts)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery attempts: %w", err)
+	}
+	// The delivery is only stored while its webhook exists
+	res, err := s.db.Exec(`
+		INSERT INTO webhook_deliveries (id, webhook_id, status, event, attempts, created_at)
+		SELECT ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM webhooks WHERE id = ?)
+		ON CONFLICT (id) DO UPDATE SET
+			webhook_id = excluded.webhook_id,
+			status = excluded.status,
+			event = excluded.event,
+			attempts = excluded.attempts,
+			created_at = excluded.created_at`,
+		d.ID, d.WebhookID, d.Status, string(event), string(encodedAttempts), d.CreatedAt.UnixNano(), d.WebhookID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert delivery: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to upsert delivery: %w", err)
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Lists the Delivery values matching the query, newest first.
+func (s *SQLStore) ListDeliveries(q DeliveryQuery) ([]models.Delivery, error) {
+	where, args := deliveryConditions(q)
+	query := `SELECT id, webhook_id, status, event, attempts, created_at FROM webhook_deliveries` + where
+	query += " ORDER BY created_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		list = append(list, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	return list, nil
+}
+
+// Deletes the Delivery values matching the query.
+func (s *SQLStore) DeleteDeliveries(q DeliveryQuery) (int, error) {
+	where, args := deliveryConditions(q)
+	res, err := s.db.Exec(`DELETE FROM webhook_deliveries`+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deliveries: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deliveries: %w", err)
+	}
+	return int(n), nil
+}
+
+// deliveryConditions builds the WHERE clause selecting the deliveries of a query, empty if it selects every delivery
+func deliveryConditions(q DeliveryQuery) (string, []any) {
+	var conditions []string
+	var args []any
+	if q.WebhookID != "" {
+		conditions = append(conditions, "webhook_id = ?")
+		args = append(args, q.WebhookID)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.Before.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.Before.UnixNano())
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scanDelivery(row rowScanner) (models.Delivery, error) {
+	var d models.Delivery
+	var event, attempts string
+	var createdAt int64
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Status, &event, &attempts, &createdAt); err != nil {
+		return d, err
+	}
+	if err := json.Unmarshal([]byte(event), &d.Event); err != nil {
+		return d, fmt.Errorf("failed to decode delivery event: %w", err)
+	}
+	if err := json.Unmarshal([]byte(attempts), &d.Attempts); err != nil {
+		return d, fmt.Errorf("failed to decode delivery attempts: %w", err)
+	}
+	d.CreatedAt = time.Unix(0, createdAt).UTC()
+	return d, nil
+}
+
+// Retrieves an APIKey by ID.
+func (s *SQLStore) GetAPIKey(id string) (models.APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow(`SELECT id, client_id, name, admin, hash, created_at, revoked_at FROM api_keys WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to query api key: %w", err)
+	}
+	return k, nil
+}
+
+// Updates or inserts an APIKey by ID.
+func (s *SQLStore) PutAPIKey(k models.APIKey) error {
+	var revokedAt *int64
+	if k.RevokedAt != nil {
+		nanos := k.RevokedAt.UnixNano()
+		revokedAt = &nanos
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO api_keys (id, client_id, name, admin, hash, created_at, revoked_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			client_id = excluded.client_id,
+			name = excluded.name,
+			admin = excluded.admin,
+			hash = excluded.hash,
+			created_at = excluded.created_at,
+			revoked_at = excluded.revoked_at`,
+		k.ID, k.ClientID, k.Name, k.Admin, k.Hash, k.CreatedAt.UnixNano(), revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert api key: %w", err)
+	}
+	return nil
+}
+
+// Lists every APIKey ordered by creation time, then ID.
+func (s *SQLStore) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := s.db.Query(`SELECT id, client_id, name, admin, hash, created_at, revoked_at FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		list = append(list, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	return list, nil
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	var createdAt int64
+	var revokedAt sql.NullInt64
+	if err := row.Scan(&k.ID, &k.ClientID, &k.Name, &k.Admin, &k.Hash, &createdAt, &revokedAt); err != nil {
+		return k, err
+	}
+	k.CreatedAt = time.Unix(0, createdAt).UTC()
+	if revokedAt.Valid {
+		revoked := time.Unix(0, revokedAt.Int64).UTC()
+		k.RevokedAt = &revoked
+	}
+	return k, nil
+}
+
+// Counts the stored receipts.
+func (s *SQLStore) Count() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM receipts`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count receipts: %w", err)
+	}
+	return count, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping fails if the database cannot be reached
+func (s *SQLStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach database: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReceipt(row rowScanner) (ReceiptData, error) {
+	var data ReceiptData
+	var campaigns string
+	r := &data.Receipt
+	err := row.Scan(&r.ID, &r.AccountID, &r.Retailer, &r.RetailerID, &r.PurchaseDate, &r.PurchaseTime, &r.Total, &data.Point, &data.RuleVersion, &campaigns, &data.Owner)
+	if err != nil {
+		return data, err
+	}
+	if err := json.Unmarshal([]byte(campaigns), &data.Campaigns); err != nil {
+		return data, fmt.Errorf("failed to decode applied campaigns: %w", err)
+	}
+	if len(data.Campaigns) == 0 {
+		// Keep receipts without campaigns equal to how they were stored
+		data.Campaigns = nil
+	}
+	return data, nil
+}
+
+// encodeCampaigns converts the campaigns applied to a receipt into the JSON of the campaigns column
+func encodeCampaigns(campaigns []models.AppliedCampaign) (string, error) {
+	if campaigns == nil {
+		return "[]", nil
+	}
+	raw, err := json.Marshal(campaigns)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode applied campaigns: %w", err)
+	}
+	return string(raw), nil
+}
+
+// queryItems loads items matching the where clause grouped by receipt ID in purchase order
+func (s *SQLStore) queryItems(where string, args ...any) (map[string][]models.Item, error) {
+	rows, err := s.db.Query(
+		`SELECT receipt_id, short_description, price_cents FROM items `+where+` ORDER BY receipt_id, position`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string][]models.Item)
+	for rows.Next() {
+		var receiptID string
+		var item models.Item
+		if err := rows.Scan(&receiptID, &item.ShortDescription, &item.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items[receiptID] = append(items[receiptID], item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"receipt-processor/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func openTestSQLStore(t *testing.T) *SQLStore {
+	store, err := OpenSQLStore("sqlite", filepath.Join(t.TempDir(), "receipts.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// Run the shared store suite against the SQLite store
+func TestSQLStoreTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &StoreTestSuite{newStore: func() Store { return openTestSQLStore(t) }})
+}
+
+func TestSQLStoreKeepsItemOrder(t *testing.T) {
+	t.Parallel()
+	store := openTestSQLStore(t)
+
+	data := mockReceiptData("a", 1)
+	data.Receipt.Items = append(data.Receipt.Items,
+		models.Item{ShortDescription: "Emils Cheese Pizza", Price: 1225},
+		models.Item{ShortDescription: "Knorr Creamy Chicken", Price: 126},
+	)
+	require.NoError(t, store.Put("a", data))
+
+	got, err := store.Get("a")
+	require.NoError(t, err)
+	require.Equal(t, data.Receipt.Items, got.Receipt.Items)
+
+	// Replacing a receipt replaces its items
+	data.Receipt.Items = data.Receipt.Items[:1]
+	require.NoError(t, store.Put("a", data))
+	got, err = store.Get("a")
+	require.NoError(t, err)
+	require.Len(t, got.Receipt.Items, 1)
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrations.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	latest := migrations[len(migrations)-1].Version
+
+	require.NoError(t, MigrateUp(db))
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+
+	// Running again is a no-op
+	require.NoError(t, MigrateUp(db))
+
+	// Migrating all the way down drops the tables
+	require.NoError(t, MigrateTo(db, 0))
+	version, err = SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+	_, err = db.Exec(`SELECT COUNT(*) FROM receipts`)
+	require.Error(t, err)
+
+	// Decimal amounts written by the first schema are converted to cents
+	require.NoError(t, MigrateTo(db, 1))
+	_, err = db.Exec(`INSERT INTO receipts (id, retailer, purchase_date, purchase_time, total, points)
+		VALUES ('a', 'Target', '2022-01-01', '13:01', '35.35', 28)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO items (receipt_id, position, short_description, price) VALUES ('a', 0, 'Pepsi', '1.10')`)
+	require.NoError(t, err)
+
+	require.NoError(t, MigrateUp(db))
+	store := &SQLStore{db: db}
+	data, err := store.Get("a")
+	require.NoError(t, err)
+	require.Equal(t, models.Money(3535), data.Receipt.Total)
+	require.Equal(t, models.Money(110), data.Receipt.Items[0].Price)
+}
+
+func TestMigrationBalancesLedger(t *testing.T) {
+	t.Parallel()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Single entries written before the double-entry ledger get an issuing counterpart
+	require.NoError(t, MigrateTo(db, 4))
+	for i, points := range []int64{28, 15} {
+		_, err = db.Exec(`INSERT INTO ledger_entries (id, account_id, kind, receipt_id, points, balance, created_at)
+			VALUES (?, 'alice', 'credit', ?, ?, ?, '2022-01-01T13:01:00Z')`, fmt.Sprintf("e%d", i), fmt.Sprintf("r%d", i), points, 28+int64(i)*15)
+		require.NoError(t, err)
+	}
+	require.NoError(t, MigrateUp(db))
+
+	store := &SQLStore{db: db}
+	balance, err := store.Balance(IssuedAccount)
+	require.NoError(t, err)
+	require.Equal(t, int64(-43), balance)
+	entries, err := store.Entries("alice")
+	require.NoError(t, err)
+	require.Equal(t, "e0", entries[0].TransactionID)
+
+	// New transfers continue the balances
+	_, err = store.Transfer(Transfer{ID: "t1", Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 43})
+	require.NoError(t, err)
+	balance, err = store.Balance("alice")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), balance)
+
+	require.NoError(t, MigrateTo(db, 4))
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM ledger_entries WHERE account_id LIKE 'system:%'`).Scan(&count))
+	require.Zero(t, count)
+}
+
+func TestSQLStorePing(t *testing.T) {
+	t.Parallel()
+	store := openTestSQLStore(t)
+	require.NoError(t, store.Ping(context.Background()))
+
+	require.NoError(t, store.Close())
+	require.ErrorContains(t, store.Ping(context.Background()), "failed to reach database")
+}
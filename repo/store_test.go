@@ -0,0 +1,456 @@
+package repo
+
+import (
+	"fmt"
+	"receipt-processor/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// StoreTestSuite runs the same behaviour checks against every Store implementation
+type StoreTestSuite struct {
+	suite.Suite
+	newStore func() Store
+	store    Store
+}
+
+// SetupTest creates a fresh store before each test
+func (suite *StoreTestSuite) SetupTest() {
+	suite.store = suite.newStore()
+}
+
+func mockReceiptData(id string, point int64) ReceiptData {
+	return ReceiptData{
+		Receipt: models.Receipt{
+			ID:           id,
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Items: []models.Item{
+				{ShortDescription: "Mountain Dew 12PK", Price: 649},
+			},
+			Total: 649,
+		},
+		Point: point,
+	}
+}
+
+func (suite *StoreTestSuite) TestPutAndGet() {
+	data := mockReceiptData("a", 28)
+	data.RuleVersion = "1"
+	data.Campaigns = []models.AppliedCampaign{{ID: "c1", Name: "Double points", Multiplier: 2, Points: 28}}
+	data.Receipt.RetailerID = "target"
+	data.Owner = "acme"
+	suite.Require().NoError(suite.store.Put("a", data))
+
+	got, err := suite.store.Get("a")
+	suite.NoError(err)
+	suite.Equal(data, got)
+
+	// Put with an existing ID overwrites the data
+	data.Point = 30
+	suite.Require().NoError(suite.store.Put("a", data))
+	got, err = suite.store.Get("a")
+	suite.NoError(err)
+	suite.Equal(int64(30), got.Point)
+}
+
+func (suite *StoreTestSuite) TestGetNotFound() {
+	_, err := suite.store.Get("missing")
+	suite.ErrorIs(err, ErrNotFound)
+}
+
+func (suite *StoreTestSuite) TestDelete() {
+	suite.Require().NoError(suite.store.Put("a", mockReceiptData("a", 1)))
+
+	suite.NoError(suite.store.Delete("a"))
+	_, err := suite.store.Get("a")
+	suite.ErrorIs(err, ErrNotFound)
+
+	suite.ErrorIs(suite.store.Delete("a"), ErrNotFound)
+}
+
+func (suite *StoreTestSuite) TestListAndCount() {
+	for _, id := range []string{"c", "a", "b"} {
+		suite.Require().NoError(suite.store.Put(id, mockReceiptData(id, 1)))
+	}
+
+	list, err := suite.store.List(ListQuery{})
+	suite.NoError(err)
+	suite.Require().Len(list, 3)
+	suite.Equal("a", list[0].Receipt.ID)
+	suite.Equal("b", list[1].Receipt.ID)
+	suite.Equal("c", list[2].Receipt.ID)
+
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(3, count)
+}
+
+func (suite *StoreTestSuite) TestListQuery() {
+	for i, date := range []string{"2022-01-01", "2022-01-02", "2022-01-03", "2022-01-04", "2022-01-05"} {
+		id := fmt.Sprintf("id-%d", i)
+		data := mockReceiptData(id, int64(i*10))
+		data.Receipt.PurchaseDate = date
+		if i%2 == 1 {
+			data.Receipt.Retailer = "M&M Corner Market"
+			data.Receipt.RetailerID = "m-and-m-corner-market"
+		}
+		if i < 2 {
+			data.Owner = "acme"
+		}
+		suite.Require().NoError(suite.store.Put(id, data))
+	}
+	ids := func(list []ReceiptData) []string {
+		ids := []string{}
+		for _, data := range list {
+			ids = append(ids, data.Receipt.ID)
+		}
+		return ids
+	}
+	minPoints, maxPoints := int64(10), int64(30)
+	version := "2"
+	retailerID, unmatched := "m-and-m-corner-market", ""
+	data, err := suite.store.Get("id-4")
+	suite.Require().NoError(err)
+	data.RuleVersion = version
+	suite.Require().NoError(suite.store.Put("id-4", data))
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  []string
+	}{
+		{"first page", ListQuery{Limit: 2}, []string{"id-0", "id-1"}},
+		{"next page", ListQuery{After: "id-1", Limit: 2}, []string{"id-2", "id-3"}},
+		{"last page", ListQuery{After: "id-3", Limit: 2}, []string{"id-4"}},
+		{"past the end", ListQuery{After: "id-4"}, []string{}},
+		{"retailer ignores case", ListQuery{Retailer: "m&m corner market"}, []string{"id-1", "id-3"}},
+		{"date range", ListQuery{PurchasedFrom: "2022-01-02", PurchasedTo: "2022-01-03"}, []string{"id-1", "id-2"}},
+		{"points range", ListQuery{MinPoints: &minPoints, MaxPoints: &maxPoints}, []string{"id-1", "id-2", "id-3"}},
+		{"filters with limit", ListQuery{Retailer: "Target", Limit: 2}, []string{"id-0", "id-2"}},
+		{"rule version", ListQuery{RuleVersion: &version}, []string{"id-4"}},
+		{"retailer ID", ListQuery{RetailerID: &retailerID}, []string{"id-1", "id-3"}},
+		{"no retailer ID", ListQuery{RetailerID: &unmatched, Limit: 2}, []string{"id-0", "id-2"}},
+		{"owner", ListQuery{Owner: "acme"}, []string{"id-0", "id-1"}},
+		{"owner with limit", ListQuery{Owner: "acme", After: "id-0", Limit: 2}, []string{"id-1"}},
+	}
+	for _, tt := range tests {
+		list, err := suite.store.List(tt.query)
+		suite.NoError(err, tt.name)
+		suite.Equal(tt.want, ids(list), tt.name)
+	}
+
+	// Items are loaded for every receipt of a page
+	list, err := suite.store.List(ListQuery{After: "id-2", Limit: 1})
+	suite.NoError(err)
+	suite.Require().Len(list, 1)
+	suite.Equal(mockReceiptData("id-3", 0).Receipt.Items, list[0].Receipt.Items)
+}
+
+func (suite *StoreTestSuite) TestLedger() {
+	_, err := suite.store.Balance("alice")
+	suite.ErrorIs(err, ErrAccountNotFound)
+	_, err = suite.store.Entries("alice")
+	suite.ErrorIs(err, ErrAccountNotFound)
+
+	createdAt := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	for _, transfer := range []Transfer{
+		{ID: "t1", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 28, ReceiptID: "a"},
+		{ID: "t2", Kind: EntryCredit, From: IssuedAccount, To: "bob", Points: 100, ReceiptID: "b"},
+		{ID: "t3", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 15, ReceiptID: "c"},
+		{ID: "t4", Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 40, RedemptionID: "t4"},
+	} {
+		transfer.CreatedAt = createdAt
+		entries, err := suite.store.Transfer(transfer)
+		suite.Require().NoError(err, transfer.ID)
+		suite.Require().Len(entries, 2)
+		suite.Zero(SumPoints(entries), "entries of a transaction balance")
+	}
+
+	balance, err := suite.store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(3), balance)
+
+	entries, err := suite.store.Entries("alice")
+	suite.NoError(err)
+	suite.Require().Len(entries, 3)
+	suite.Equal(LedgerEntry{
+		ID: "t4:debit", TransactionID: "t4", AccountID: "alice", Kind: EntryRedemption, RedemptionID: "t4",
+		Points: -40, Balance: 3, CreatedAt: createdAt,
+	}, entries[2])
+	suite.Equal([]int64{28, 43, 3}, []int64{entries[0].Balance, entries[1].Balance, entries[2].Balance})
+	suite.Equal(balance, SumPoints(entries))
+
+	// System accounts balance the customer accounts
+	issued, err := suite.store.Balance(IssuedAccount)
+	suite.NoError(err)
+	suite.Equal(int64(-143), issued)
+	redeemed, err := suite.store.Balance(RedeemedAccount)
+	suite.NoError(err)
+	suite.Equal(int64(40), redeemed)
+}
+
+func (suite *StoreTestSuite) TestTransferRejected() {
+	_, err := suite.store.Transfer(Transfer{ID: "t1", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 10})
+	suite.Require().NoError(err)
+
+	_, err = suite.store.Transfer(Transfer{ID: "t2", Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 11})
+	suite.ErrorIs(err, ErrInsufficientFunds)
+	_, err = suite.store.Transfer(Transfer{ID: "t1", Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 5})
+	suite.ErrorIs(err, ErrDuplicateTransaction)
+	_, err = suite.store.Transfer(Transfer{ID: "t3", Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 0})
+	suite.Error(err)
+
+	balance, err := suite.store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(10), balance)
+}
+
+func (suite *StoreTestSuite) TestConcurrentTransfers() {
+	_, err := suite.store.Transfer(Transfer{ID: "credit", Kind: EntryCredit, From: IssuedAccount, To: "alice", Points: 100})
+	suite.Require().NoError(err)
+
+	// 50 redemptions of 10 points compete for a balance of 100
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := suite.store.Transfer(Transfer{
+				ID: fmt.Sprintf("redeem-%02d", i), Kind: EntryRedemption, From: "alice", To: RedeemedAccount, Points: 10,
+			})
+			if err != nil {
+				suite.ErrorIs(err, ErrInsufficientFunds)
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	suite.Equal(10, succeeded)
+	entries, err := suite.store.Entries("alice")
+	suite.NoError(err)
+	for _, entry := range entries {
+		suite.GreaterOrEqual(entry.Balance, int64(0))
+	}
+	suite.Equal(int64(0), entries[len(entries)-1].Balance)
+	suite.Equal(int64(0), SumPoints(entries))
+}
+
+func (suite *StoreTestSuite) TestCampaigns() {
+	list, err := suite.store.ListCampaigns()
+	suite.NoError(err)
+	suite.Empty(list)
+
+	later := models.Campaign{ID: "a", Name: "Gatorade bonus", StartDate: "2022-03-01", EndDate: "2022-03-31", ItemContains: "Gatorade", Bonus: 100}
+	earlier := models.Campaign{ID: "b", Name: "Target weekend", StartDate: "2022-01-01", EndDate: "2022-01-02", Retailer: "Target", Multiplier: 1.5}
+	suite.Require().NoError(suite.store.PutCampaign(later))
+	suite.Require().NoError(suite.store.PutCampaign(earlier))
+
+	got, err := suite.store.GetCampaign("b")
+	suite.NoError(err)
+	suite.Equal(earlier, got)
+
+	// Campaigns are listed by start date
+	list, err = suite.store.ListCampaigns()
+	suite.NoError(err)
+	suite.Equal([]models.Campaign{earlier, later}, list)
+
+	// Put with an existing ID overwrites the campaign
+	later.Bonus = 50
+	suite.Require().NoError(suite.store.PutCampaign(later))
+	got, err = suite.store.GetCampaign("a")
+	suite.NoError(err)
+	suite.Equal(int64(50), got.Bonus)
+
+	suite.NoError(suite.store.DeleteCampaign("a"))
+	_, err = suite.store.GetCampaign("a")
+	suite.ErrorIs(err, ErrCampaignNotFound)
+	suite.ErrorIs(suite.store.DeleteCampaign("a"), ErrCampaignNotFound)
+}
+
+func (suite *StoreTestSuite) TestAssignRetailer() {
+	data := mockReceiptData("a", 28)
+	suite.Require().NoError(suite.store.Put("a", data))
+
+	suite.NoError(suite.store.AssignRetailer("a", "target"))
+	got, err := suite.store.Get("a")
+	suite.NoError(err)
+	data.Receipt.RetailerID = "target"
+	suite.Equal(data, got)
+
+	suite.ErrorIs(suite.store.AssignRetailer("missing", "target"), ErrNotFound)
+}
+
+func (suite *StoreTestSuite) TestAssignScore() {
+	data := mockReceiptData("a", 28)
+	suite.Require().NoError(suite.store.Put("a", data))
+	suite.Require().NoError(suite.store.AssignRetailer("a", "target"))
+
+	// The retailer assigned meanwhile is kept
+	campaigns := []models.AppliedCampaign{{ID: "double", Name: "Double points", Multiplier: 2, Points: 20}}
+	suite.NoError(suite.store.AssignScore("a", 40, "2", campaigns))
+	got, err := suite.store.Get("a")
+	suite.NoError(err)
+	data.Receipt.RetailerID = "target"
+	data.Point, data.RuleVersion, data.Campaigns = 40, "2", campaigns
+	suite.Equal(data, got)
+
+	suite.ErrorIs(suite.store.AssignScore("missing", 40, "2", nil), ErrNotFound)
+}
+
+func (suite *StoreTestSuite) TestRetailers() {
+	_, err := suite.store.GetRetailer("target")
+	suite.ErrorIs(err, ErrRetailerNotFound)
+
+	target := models.Retailer{ID: "target", Name: "Target", Aliases: []string{}}
+	market := models.Retailer{ID: "m-and-m-corner-market", Name: "M&M Corner Market", Aliases: []string{"M and M Market"}}
+	suite.Require().NoError(suite.store.PutRetailer(target))
+	suite.Require().NoError(suite.store.PutRetailer(market))
+
+	got, err := suite.store.GetRetailer("m-and-m-corner-market")
+	suite.NoError(err)
+	suite.Equal(market, got)
+
+	// Put with an existing ID overwrites the retailer
+	target.Aliases = []string{"Target Store"}
+	suite.Require().NoError(suite.store.PutRetailer(target))
+	list, err := suite.store.ListRetailers()
+	suite.NoError(err)
+	suite.Equal([]models.Retailer{market, target}, list)
+}
+
+func (suite *StoreTestSuite) TestWebhooks() {
+	created := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	first := models.Webhook{ID: "w1", URL: "https://example.com/a", Events: []string{}, Secret: "s1", CreatedAt: created}
+	second := models.Webhook{ID: "w2", URL: "https://example.com/b", Events: []string{models.EventReceiptDeleted}, Secret: "s2", CreatedAt: created.Add(time.Second)}
+	suite.Require().NoError(suite.store.PutWebhook(second))
+	suite.Require().NoError(suite.store.PutWebhook(first))
+
+	got, err := suite.store.GetWebhook("w2")
+	suite.NoError(err)
+	suite.Equal(second, got)
+	list, err := suite.store.ListWebhooks()
+	suite.NoError(err)
+	suite.Equal([]models.Webhook{first, second}, list)
+
+	event := models.ReceiptEvent{ID: "e1", Type: models.EventReceiptProcessed, CreatedAt: created, ReceiptID: "a", Points: 28}
+	delivered := models.Delivery{ID: "d1", WebhookID: "w1", Event: event, Status: models.DeliverySucceeded,
+		Attempts: []models.DeliveryAttempt{{At: created, StatusCode: 200}}, CreatedAt: created}
+	dead := models.Delivery{ID: "d2", WebhookID: "w1", Event: event, Status: models.DeliveryDead,
+		Attempts: []models.DeliveryAttempt{{At: created, Error: "connection refused"}}, CreatedAt: created.Add(time.Second)}
+	other := models.Delivery{ID: "d3", WebhookID: "w2", Event: event, Status: models.DeliveryDead,
+		Attempts: []models.DeliveryAttempt{}, CreatedAt: created.Add(2 * time.Second)}
+	for _, d := range []models.Delivery{delivered, dead, other} {
+		suite.Require().NoError(suite.store.PutDelivery(d))
+	}
+
+	gotDelivery, err := suite.store.GetDelivery("d2")
+	suite.NoError(err)
+	suite.Equal(dead, gotDelivery)
+	_, err = suite.store.GetDelivery("missing")
+	suite.ErrorIs(err, ErrDeliveryNotFound)
+
+	// Deliveries are listed newest first
+	deliveries, err := suite.store.ListDeliveries(DeliveryQuery{WebhookID: "w1"})
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{dead, delivered}, deliveries)
+	deliveries, err = suite.store.ListDeliveries(DeliveryQuery{Status: models.DeliveryDead, Limit: 1})
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{other}, deliveries)
+
+	// Deliveries of deleted webhooks are not stored again
+	suite.ErrorIs(suite.store.PutDelivery(models.Delivery{ID: "d4", WebhookID: "missing", Event: event, Status: models.DeliveryPending,
+		Attempts: []models.DeliveryAttempt{}, CreatedAt: created}), ErrWebhookNotFound)
+	_, err = suite.store.GetDelivery("d4")
+	suite.ErrorIs(err, ErrDeliveryNotFound)
+
+	// Deleting a webhook deletes its deliveries
+	suite.NoError(suite.store.DeleteWebhook("w1"))
+	_, err = suite.store.GetWebhook("w1")
+	suite.ErrorIs(err, ErrWebhookNotFound)
+	suite.ErrorIs(suite.store.DeleteWebhook("w1"), ErrWebhookNotFound)
+	deliveries, err = suite.store.ListDeliveries(DeliveryQuery{})
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{other}, deliveries)
+}
+
+func (suite *StoreTestSuite) TestDeleteDeliveries() {
+	created := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	suite.Require().NoError(suite.store.PutWebhook(models.Webhook{ID: "w1", URL: "https://example.com/a", Events: []string{}, Secret: "s1", CreatedAt: created}))
+	event := models.ReceiptEvent{ID: "e1", Type: models.EventReceiptProcessed, CreatedAt: created, ReceiptID: "a", Points: 28}
+	old := models.Delivery{ID: "d1", WebhookID: "w1", Event: event, Status: models.DeliverySucceeded,
+		Attempts: []models.DeliveryAttempt{}, CreatedAt: created}
+	oldDead := models.Delivery{ID: "d2", WebhookID: "w1", Event: event, Status: models.DeliveryDead,
+		Attempts: []models.DeliveryAttempt{}, CreatedAt: created}
+	recent := models.Delivery{ID: "d3", WebhookID: "w1", Event: event, Status: models.DeliverySucceeded,
+		Attempts: []models.DeliveryAttempt{}, CreatedAt: created.Add(time.Hour)}
+	for _, d := range []models.Delivery{old, oldDead, recent} {
+		suite.Require().NoError(suite.store.PutDelivery(d))
+	}
+
+	deleted, err := suite.store.DeleteDeliveries(DeliveryQuery{Status: models.DeliverySucceeded, Before: created.Add(time.Minute)})
+	suite.NoError(err)
+	suite.Equal(1, deleted)
+	deliveries, err := suite.store.ListDeliveries(DeliveryQuery{})
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{recent, oldDead}, deliveries)
+
+	deleted, err = suite.store.DeleteDeliveries(DeliveryQuery{Status: models.DeliverySucceeded, Before: created})
+	suite.NoError(err)
+	suite.Zero(deleted)
+}
+
+func (suite *StoreTestSuite) TestAPIKeys() {
+	created := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+	revoked := created.Add(time.Hour)
+	first := models.APIKey{ID: "k1", ClientID: "acme", Name: "checkout", Hash: "h1", CreatedAt: created}
+	second := models.APIKey{ID: "k2", ClientID: "ops", Admin: true, Hash: "h2", CreatedAt: created.Add(time.Second)}
+	suite.Require().NoError(suite.store.PutAPIKey(second))
+	suite.Require().NoError(suite.store.PutAPIKey(first))
+
+	got, err := suite.store.GetAPIKey("k2")
+	suite.NoError(err)
+	suite.Equal(second, got)
+	_, err = suite.store.GetAPIKey("missing")
+	suite.ErrorIs(err, ErrAPIKeyNotFound)
+
+	// Put with an existing ID overwrites the key
+	first.RevokedAt = &revoked
+	suite.Require().NoError(suite.store.PutAPIKey(first))
+	list, err := suite.store.ListAPIKeys()
+	suite.NoError(err)
+	suite.Equal([]models.APIKey{first, second}, list)
+}
+
+func (suite *StoreTestSuite) TestConcurrentPut() {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("id-%02d", i)
+			suite.NoError(suite.store.Put(id, mockReceiptData(id, int64(i))))
+		}(i)
+	}
+	wg.Wait()
+
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(50, count)
+}
+
+// Run the test suite against the in-memory store
+func TestMemoryStoreTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &StoreTestSuite{newStore: func() Store { return NewMemoryStore() }})
+}
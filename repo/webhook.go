@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"errors"
+	"receipt-processor/models"
+	"sort"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// DeliveryQuery selects webhook deliveries, the zero value selects every delivery
+type DeliveryQuery struct {
+	// WebhookID keeps the deliveries to this webhook
+	WebhookID string
+	// Status keeps the deliveries with this status
+	Status string
+	// Before keeps the deliveries created before it, any time if zero
+	Before time.Time
+	// Limit caps the number of deliveries returned, zero means no limit
+	Limit int
+}
+
+// Matches reports whether a delivery passes the filters of the query
+func (q DeliveryQuery) Matches(d models.Delivery) bool {
+	return (q.WebhookID == "" || d.WebhookID == q.WebhookID) && (q.Status == "" || d.Status == q.Status) &&
+		(q.Before.IsZero() || d.CreatedAt.Before(q.Before))
+}
+
+// WebhookStore keeps webhook subscriptions and the history of their deliveries.
+// Implementations must be safe for concurrent use.
+type WebhookStore interface {
+	// GetWebhook retrieves a webhook by ID, returning ErrWebhookNotFound if it does not exist.
+	GetWebhook(id string) (models.Webhook, error)
+	// PutWebhook updates or inserts a webhook by its ID.
+	PutWebhook(w models.Webhook) error
+	// DeleteWebhook removes a webhook and its deliveries by ID, returning ErrWebhookNotFound if it does not exist.
+	DeleteWebhook(id string) error
+	// ListWebhooks returns every webhook ordered by creation time, then ID.
+	ListWebhooks() ([]models.Webhook, error)
+	// GetDelivery retrieves a delivery by ID, returning ErrDeliveryNotFound if it does not exist.
+	GetDelivery(id string) (models.Delivery, error)
+	// PutDelivery updates or inserts a delivery by its ID, returning ErrWebhookNotFound if its webhook
+	// does not exist, so that a delivery finishing after its webhook was deleted is not stored again.
+	PutDelivery(d models.Delivery) error
+	// ListDeliveries returns the deliveries matching the query, newest first.
+	ListDeliveries(q DeliveryQuery) ([]models.Delivery, error)
+	// DeleteDeliveries removes the deliveries matching the query, ignoring its limit, and returns how many were removed.
+	DeleteDeliveries(q DeliveryQuery) (int, error)
+}
+
+// sortedWebhooks copies the map values into a slice ordered by creation time, then ID
+func sortedWebhooks(webhooks map[string]models.Webhook) []models.Webhook {
+	list := make([]models.Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// selectDeliveries returns the deliveries of the map matching the query, newest first
+func selectDeliveries(deliveries map[string]models.Delivery, q DeliveryQuery) []models.Delivery {
+	list := make([]models.Delivery, 0)
+	for _, d := range deliveries {
+		if q.Matches(d) {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	if q.Limit > 0 && len(list) > q.Limit {
+		list = list[:q.Limit]
+	}
+	return list
+}
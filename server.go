@@ -0,0 +1,161 @@
+// server.go
+// HTTP server settings and graceful shutdown.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"receipt-processor/auth"
+	"receipt-processor/config"
+	"receipt-processor/logging"
+	"receipt-processor/metrics"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"receipt-processor/tracing"
+	"slices"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// setupLogging sends the application log to stderr in the configured format and level
+func setupLogging(cfg config.Log) {
+	logging.Setup(os.Stderr, logging.Options{
+		Level:       cfg.SlogLevel(),
+		JSON:        cfg.Format == "json",
+		LogReceipts: cfg.LogReceipts,
+	})
+}
+
+// fatal logs an error that stops the application, then exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newRouter creates the Gin router with the middleware and routes shared by every API.
+// Every request is traced, logged with its ID, and measured by m unless it is nil.
+func newRouter(cfg config.Config, m *metrics.Metrics) *gin.Engine {
+	gin.SetMode(cfg.Server.Mode)
+	router := gin.New()
+	router.Use(tracing.Middleware("/metrics", "/healthz", "/readyz"), logging.Middleware(), logging.Recovery())
+	if m != nil {
+		router.Use(m.Middleware())
+		router.GET("/metrics", gin.WrapH(m.Handler()))
+	}
+	if cfg.Server.MaxBodyBytes > 0 {
+		router.Use(limitBodySize(cfg.Server.MaxBodyBytes))
+	}
+	if origins := cfg.CORS.AllowedOrigins; len(origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		if slices.Contains(origins, "*") {
+			corsConfig.AllowAllOrigins = true
+		} else {
+			corsConfig.AllowOrigins = origins
+		}
+		router.Use(cors.New(corsConfig))
+	}
+
+	// Swagger for API docs
+	if cfg.Features.Docs {
+		router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	}
+
+	// Custom 404 handler
+	router.NoRoute(func(c *gin.Context) {
+		logging.WriteError(c, http.StatusNotFound, models.ErrorResponse{Error: "Not Found"})
+	})
+	return router
+}
+
+// adminPaths are the routes only admin clients may call when authentication is enabled.
+// Campaigns, retailers and webhooks are shared by every client, and webhooks receive the events of every receipt.
+var adminPaths = []string{"/admin", "/campaigns", "/retailers", "/webhooks"}
+
+// newAuthMiddleware authenticates requests by the API keys of keys, and by bearer JWTs if a JWKS file is configured
+func newAuthMiddleware(cfg config.Auth, keys auth.KeyVerifier) (gin.HandlerFunc, error) {
+	opts := auth.Options{Keys: keys, AdminPaths: adminPaths}
+	if cfg.JWKSFile != "" {
+		tokens, err := auth.NewTokenVerifier(auth.TokenOptions{JWKSFile: cfg.JWKSFile, Issuer: cfg.Issuer, Audience: cfg.Audience})
+		if err != nil {
+			return nil, err
+		}
+		opts.Tokens = tokens
+	}
+	return auth.Middleware(opts), nil
+}
+
+// newServer creates the HTTP server of the router with the configured settings
+func newServer(cfg config.Server, router *gin.Engine) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           router,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// limitBodySize rejects requests declaring a body larger than limit bytes with 413, and fails
+// reading past limit bytes of bodies of unknown length
+func limitBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.Abort()
+			logging.WriteError(c, http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: "Request body too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// shutdown stops accepting connections and waits for the requests in progress to finish
+// within timeout, then stops the background work of the services and flushes the store.
+// Each step runs even if an earlier one failed, and every failure is returned.
+func shutdown(server *http.Server, timeout time.Duration, steps ...func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("requests in progress were not finished: %w", err))
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pingStore fails if the store is unreachable. Stores kept in memory are always reachable.
+func pingStore(ctx context.Context, store any) error {
+	pinger, ok := store.(repo.Pinger)
+	if !ok {
+		return nil
+	}
+	return pinger.Ping(ctx)
+}
+
+// closeStore flushes and closes the store if it holds files or connections
+func closeStore(store any) error {
+	closer, ok := store.(io.Closer)
+	if !ok {
+		return nil
+	}
+	if err := closer.Close(); err != nil {
+		return fmt.Errorf("failed to close store: %w", err)
+	}
+	return nil
+}
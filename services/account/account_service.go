@@ -0,0 +1,173 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"receipt-processor/repo"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountService interface {
+	GetAccount(id string) (Account, error)
+	Redeem(accountID string, points int64) (Redemption, error)
+	ReverseRedemption(accountID, redemptionID string) (Redemption, error)
+}
+
+// A loyalty account with its balance and the ledger entries that make it up
+type Account struct {
+	ID      string
+	Balance int64
+	Entries []repo.LedgerEntry
+}
+
+// Points spent by an account, Balance is the account balance right after the redemption
+// or, once reversed, right after the reversal
+type Redemption struct {
+	ID        string
+	AccountID string
+	Points    int64
+	Balance   int64
+	Reversed  bool
+	CreatedAt time.Time
+}
+
+var (
+	// ErrInvalidPoints is returned when redeeming a non-positive number of points
+	ErrInvalidPoints = errors.New("points must be positive")
+	// ErrRedemptionNotFound is returned when an account has no redemption with the given ID
+	ErrRedemptionNotFound = errors.New("redemption not found")
+	// ErrRedemptionReversed is returned when reversing a redemption a second time
+	ErrRedemptionReversed = errors.New("redemption already reversed")
+)
+
+// ErrUnbalancedLedger is returned when an account's running balance differs from the sum of its entries
+var ErrUnbalancedLedger = errors.New("ledger balance does not match its entries")
+
+type accountServiceImpl struct {
+	ledger repo.LedgerStore
+	now    func() time.Time
+}
+
+// NewAccountService creates an AccountService backed by the given ledger
+func NewAccountService(ledger repo.LedgerStore) AccountService {
+	return &accountServiceImpl{ledger: ledger, now: time.Now}
+}
+
+// Get the balance and history of a given account ID, checking the balance against the ledger
+func (a *accountServiceImpl) GetAccount(id string) (Account, error) {
+	if err := customerAccount(id); err != nil {
+		return Account{}, err
+	}
+	entries, err := a.ledger.Entries(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrAccountNotFound) {
+			return Account{}, fmt.Errorf("account with id %s does not exist: %w", id, err)
+		}
+		return Account{}, fmt.Errorf("failed to retrieve ledger of account with id %s: %w", id, err)
+	}
+
+	// The running balance must equal the balance recomputed from the ledger
+	balance := repo.SumPoints(entries)
+	if last := entries[len(entries)-1]; last.Balance != balance {
+		return Account{}, fmt.Errorf("account with id %s has balance %d but its entries sum to %d: %w",
+			id, last.Balance, balance, ErrUnbalancedLedger)
+	}
+	return Account{ID: id, Balance: balance, Entries: entries}, nil
+}
+
+// Spends points of a given account ID, failing with repo.ErrInsufficientFunds if its balance is too low
+func (a *accountServiceImpl) Redeem(accountID string, points int64) (Redemption, error) {
+	if err := customerAccount(accountID); err != nil {
+		return Redemption{}, err
+	}
+	if points <= 0 {
+		return Redemption{}, ErrInvalidPoints
+	}
+	if _, err := a.balance(accountID); err != nil {
+		return Redemption{}, err
+	}
+
+	// The ledger checks the balance and debits it atomically
+	id := uuid.New().String()
+	entries, err := a.ledger.Transfer(repo.Transfer{
+		ID:           id,
+		Kind:         repo.EntryRedemption,
+		From:         accountID,
+		To:           repo.RedeemedAccount,
+		Points:       points,
+		RedemptionID: id,
+		CreatedAt:    a.now(),
+	})
+	if err != nil {
+		return Redemption{}, fmt.Errorf("failed to redeem %d points of account with id %s: %w", points, accountID, err)
+	}
+	debit := entries[0]
+	return Redemption{ID: id, AccountID: accountID, Points: points, Balance: debit.Balance, CreatedAt: debit.CreatedAt}, nil
+}
+
+// Refunds the points of a redemption to its account, at most once
+func (a *accountServiceImpl) ReverseRedemption(accountID, redemptionID string) (Redemption, error) {
+	if err := customerAccount(accountID); err != nil {
+		return Redemption{}, err
+	}
+	entries, err := a.ledger.Entries(accountID)
+	if err != nil {
+		if errors.Is(err, repo.ErrAccountNotFound) {
+			return Redemption{}, fmt.Errorf("account with id %s does not exist: %w", accountID, err)
+		}
+		return Redemption{}, fmt.Errorf("failed to retrieve ledger of account with id %s: %w", accountID, err)
+	}
+	var redemption *Redemption
+	for _, entry := range entries {
+		if entry.Kind == repo.EntryRedemption && entry.RedemptionID == redemptionID {
+			redemption = &Redemption{ID: redemptionID, AccountID: accountID, Points: -entry.Points, CreatedAt: entry.CreatedAt}
+			break
+		}
+	}
+	if redemption == nil {
+		return Redemption{}, fmt.Errorf("account with id %s has no redemption with id %s: %w", accountID, redemptionID, ErrRedemptionNotFound)
+	}
+
+	// The reversal ID is derived from the redemption so that the ledger rejects a second reversal
+	reversal, err := a.ledger.Transfer(repo.Transfer{
+		ID:           redemptionID + ":reversal",
+		Kind:         repo.EntryRedemptionReversal,
+		From:         repo.RedeemedAccount,
+		To:           accountID,
+		Points:       redemption.Points,
+		RedemptionID: redemptionID,
+		CreatedAt:    a.now(),
+	})
+	if errors.Is(err, repo.ErrDuplicateTransaction) {
+		return Redemption{}, fmt.Errorf("redemption with id %s: %w", redemptionID, ErrRedemptionReversed)
+	}
+	if err != nil {
+		return Redemption{}, fmt.Errorf("failed to reverse redemption with id %s: %w", redemptionID, err)
+	}
+	redemption.Balance = reversal[1].Balance
+	redemption.Reversed = true
+	return *redemption, nil
+}
+
+// customerAccount fails with repo.ErrAccountNotFound for system accounts, which may have a negative
+// balance and hold the entries of every customer, so they are never reachable through the service
+func customerAccount(id string) error {
+	if repo.IsSystemAccount(id) {
+		return fmt.Errorf("account with id %s does not exist: %w", id, repo.ErrAccountNotFound)
+	}
+	return nil
+}
+
+// balance returns the balance of an account, wrapping errors with the account ID
+func (a *accountServiceImpl) balance(accountID string) (int64, error) {
+	balance, err := a.ledger.Balance(accountID)
+	if err != nil {
+		if errors.Is(err, repo.ErrAccountNotFound) {
+			return 0, fmt.Errorf("account with id %s does not exist: %w", accountID, err)
+		}
+		return 0, fmt.Errorf("failed to retrieve balance of account with id %s: %w", accountID, err)
+	}
+	return balance, nil
+}
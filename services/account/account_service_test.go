@@ -0,0 +1,160 @@
+package account
+
+import (
+	"receipt-processor/repo"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+// AccountServiceTestSuite defines the suite for service tests
+type AccountServiceTestSuite struct {
+	suite.Suite
+	service AccountService
+	store   *repo.MemoryStore
+}
+
+// SetupTest initializes the suite
+func (suite *AccountServiceTestSuite) SetupTest() {
+	// Use a fresh storage for each test
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewAccountService(suite.store)
+}
+
+func (suite *AccountServiceTestSuite) TestGetAccount() {
+	suite.credit("alice", 28)
+	suite.credit("alice", 109)
+
+	account, err := suite.service.GetAccount("alice")
+	suite.NoError(err)
+	suite.Equal("alice", account.ID)
+	suite.Equal(int64(137), account.Balance)
+	suite.Len(account.Entries, 2)
+}
+
+func (suite *AccountServiceTestSuite) TestGetAccountNotFound() {
+	_, err := suite.service.GetAccount("nobody")
+	suite.ErrorIs(err, repo.ErrAccountNotFound)
+}
+
+func (suite *AccountServiceTestSuite) TestSystemAccountsNotFound() {
+	suite.credit("alice", 100)
+	redemption, err := suite.service.Redeem("alice", 30)
+	suite.Require().NoError(err)
+
+	// The system accounts have entries, but customers cannot read or spend them
+	for _, id := range []string{repo.IssuedAccount, repo.RedeemedAccount} {
+		_, err := suite.service.GetAccount(id)
+		suite.ErrorIs(err, repo.ErrAccountNotFound, id)
+		_, err = suite.service.Redeem(id, 1000000)
+		suite.ErrorIs(err, repo.ErrAccountNotFound, id)
+		_, err = suite.service.ReverseRedemption(id, redemption.ID)
+		suite.ErrorIs(err, repo.ErrAccountNotFound, id)
+	}
+	balance, err := suite.store.Balance(repo.IssuedAccount)
+	suite.Require().NoError(err)
+	suite.Equal(int64(-100), balance)
+}
+
+func (suite *AccountServiceTestSuite) TestGetAccountUnbalancedLedger() {
+	suite.service = NewAccountService(tamperedLedger{suite.store})
+	suite.credit("alice", 28)
+
+	_, err := suite.service.GetAccount("alice")
+	suite.ErrorIs(err, ErrUnbalancedLedger)
+}
+
+func (suite *AccountServiceTestSuite) TestRedeem() {
+	suite.credit("alice", 100)
+
+	redemption, err := suite.service.Redeem("alice", 30)
+	suite.Require().NoError(err)
+	suite.NotEmpty(redemption.ID)
+	suite.Equal(int64(30), redemption.Points)
+	suite.Equal(int64(70), redemption.Balance)
+
+	_, err = suite.service.Redeem("alice", 71)
+	suite.ErrorIs(err, repo.ErrInsufficientFunds)
+	_, err = suite.service.Redeem("alice", 0)
+	suite.ErrorIs(err, ErrInvalidPoints)
+	_, err = suite.service.Redeem("nobody", 1)
+	suite.ErrorIs(err, repo.ErrAccountNotFound)
+
+	account, err := suite.service.GetAccount("alice")
+	suite.NoError(err)
+	suite.Equal(int64(70), account.Balance)
+}
+
+func (suite *AccountServiceTestSuite) TestReverseRedemption() {
+	suite.credit("alice", 100)
+	redemption, err := suite.service.Redeem("alice", 30)
+	suite.Require().NoError(err)
+
+	reversed, err := suite.service.ReverseRedemption("alice", redemption.ID)
+	suite.NoError(err)
+	suite.True(reversed.Reversed)
+	suite.Equal(int64(30), reversed.Points)
+	suite.Equal(int64(100), reversed.Balance)
+
+	_, err = suite.service.ReverseRedemption("alice", redemption.ID)
+	suite.ErrorIs(err, ErrRedemptionReversed)
+	_, err = suite.service.ReverseRedemption("alice", "missing-id")
+	suite.ErrorIs(err, ErrRedemptionNotFound)
+
+	account, err := suite.service.GetAccount("alice")
+	suite.NoError(err)
+	suite.Equal(int64(100), account.Balance)
+}
+
+func (suite *AccountServiceTestSuite) TestRedeemConcurrent() {
+	suite.credit("alice", 100)
+
+	// The balance never goes negative however many redemptions run at once
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := suite.service.Redeem("alice", 7); err == nil {
+				succeeded.Add(1)
+			} else {
+				suite.ErrorIs(err, repo.ErrInsufficientFunds)
+			}
+		}()
+	}
+	wg.Wait()
+
+	suite.Equal(int32(14), succeeded.Load())
+	account, err := suite.service.GetAccount("alice")
+	suite.NoError(err)
+	suite.Equal(int64(2), account.Balance)
+}
+
+// credit awards points to an account as a receipt would
+func (suite *AccountServiceTestSuite) credit(accountID string, points int64) {
+	_, err := suite.store.Transfer(repo.Transfer{
+		ID: uuid.New().String(), Kind: repo.EntryCredit, From: repo.IssuedAccount, To: accountID, Points: points,
+	})
+	suite.Require().NoError(err)
+}
+
+// tamperedLedger inflates the running balance of every entry
+type tamperedLedger struct {
+	repo.LedgerStore
+}
+
+func (t tamperedLedger) Entries(accountID string) ([]repo.LedgerEntry, error) {
+	entries, err := t.LedgerStore.Entries(accountID)
+	for i := range entries {
+		entries[i].Balance += 100
+	}
+	return entries, err
+}
+
+func TestAccountServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountServiceTestSuite))
+}
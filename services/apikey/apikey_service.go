@@ -0,0 +1,117 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"receipt-processor/auth"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyPrefix starts every API key, so leaked keys are easy to recognize
+const KeyPrefix = "rpk_"
+
+type APIKeyService interface {
+	CreateAPIKey(k models.APIKey) (models.APIKey, string, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	RevokeAPIKey(id string) (models.APIKey, error)
+	VerifyKey(key string) (auth.Client, error)
+}
+
+type apiKeyServiceImpl struct {
+	store repo.APIKeyStore
+	now   func() time.Time
+}
+
+// NewAPIKeyService creates an APIKeyService keeping the hashes of keys in store
+func NewAPIKeyService(store repo.APIKeyStore) APIKeyService {
+	return &apiKeyServiceImpl{store: store, now: time.Now}
+}
+
+// Generates a key for a client and stores its hash under a generated ID. The key is returned
+// here only, as "rpk_<id>_<secret>". Invalid keys fail with models.ValidationErrors.
+func (s *apiKeyServiceImpl) CreateAPIKey(k models.APIKey) (models.APIKey, string, error) {
+	if errs := k.Validate(); len(errs) > 0 {
+		return models.APIKey{}, "", errs
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	encoded := hex.EncodeToString(secret)
+	k.ID = uuid.New().String()
+	k.Hash = hashSecret(encoded)
+	k.CreatedAt = s.now().UTC()
+	k.RevokedAt = nil
+	if err := s.store.PutAPIKey(k); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to store api key with id %s: %w", k.ID, err)
+	}
+	return k, KeyPrefix + k.ID + "_" + encoded, nil
+}
+
+// Lists every key, revoked ones included, ordered by creation time
+func (s *apiKeyServiceImpl) ListAPIKeys() ([]models.APIKey, error) {
+	list, err := s.store.ListAPIKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return list, nil
+}
+
+// Stops accepting a key. Revoking a revoked key leaves it unchanged.
+func (s *apiKeyServiceImpl) RevokeAPIKey(id string) (models.APIKey, error) {
+	k, err := s.store.GetAPIKey(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrAPIKeyNotFound) {
+			return models.APIKey{}, fmt.Errorf("api key with id %s does not exist: %w", id, err)
+		}
+		return models.APIKey{}, fmt.Errorf("failed to retrieve api key with id %s: %w", id, err)
+	}
+	if k.Revoked() {
+		return k, nil
+	}
+	revokedAt := s.now().UTC()
+	k.RevokedAt = &revokedAt
+	if err := s.store.PutAPIKey(k); err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to store api key with id %s: %w", id, err)
+	}
+	return k, nil
+}
+
+// Returns the client of an active key, failing with auth.ErrUnauthenticated for malformed,
+// unknown and revoked keys
+func (s *apiKeyServiceImpl) VerifyKey(key string) (auth.Client, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, KeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, KeyPrefix) {
+		return auth.Client{}, fmt.Errorf("%w: malformed api key", auth.ErrUnauthenticated)
+	}
+	k, err := s.store.GetAPIKey(id)
+	if errors.Is(err, repo.ErrAPIKeyNotFound) {
+		return auth.Client{}, fmt.Errorf("%w: unknown api key %s", auth.ErrUnauthenticated, id)
+	}
+	if err != nil {
+		return auth.Client{}, fmt.Errorf("failed to retrieve api key with id %s: %w", id, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.Hash)) != 1 {
+		return auth.Client{}, fmt.Errorf("%w: wrong secret for api key %s", auth.ErrUnauthenticated, id)
+	}
+	if k.Revoked() {
+		return auth.Client{}, fmt.Errorf("%w: api key %s was revoked", auth.ErrUnauthenticated, id)
+	}
+	return auth.Client{ID: k.ClientID, Admin: k.Admin}, nil
+}
+
+// hashSecret returns the hex SHA-256 of the secret part of a key. Secrets are random
+// and long, so a fast hash is enough to make stored hashes useless to an attacker.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"receipt-processor/auth"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// APIKeyServiceTestSuite defines the suite for service tests
+type APIKeyServiceTestSuite struct {
+	suite.Suite
+	service APIKeyService
+	store   *repo.MemoryStore
+}
+
+// SetupTest initializes the suite
+func (suite *APIKeyServiceTestSuite) SetupTest() {
+	// Use a fresh storage for each test
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewAPIKeyService(suite.store)
+}
+
+func (suite *APIKeyServiceTestSuite) TestCreateAPIKey() {
+	created, key, err := suite.service.CreateAPIKey(models.APIKey{ClientID: "acme", Name: "checkout"})
+	suite.Require().NoError(err)
+	suite.NotEmpty(created.ID)
+	suite.True(strings.HasPrefix(key, KeyPrefix+created.ID+"_"))
+
+	// Only the hash of the key is stored
+	stored, err := suite.store.GetAPIKey(created.ID)
+	suite.Require().NoError(err)
+	suite.Equal(created, stored)
+	suite.NotContains(key, stored.Hash)
+	suite.NotContains(stored.Hash, strings.TrimPrefix(key, KeyPrefix+created.ID+"_"))
+
+	client, err := suite.service.VerifyKey(key)
+	suite.NoError(err)
+	suite.Equal(auth.Client{ID: "acme"}, client)
+}
+
+func (suite *APIKeyServiceTestSuite) TestCreateInvalidAPIKey() {
+	_, _, err := suite.service.CreateAPIKey(models.APIKey{ClientID: "acme corp"})
+	var errs models.ValidationErrors
+	suite.Require().ErrorAs(err, &errs)
+	suite.Equal("clientId", errs[0].Field)
+}
+
+func (suite *APIKeyServiceTestSuite) TestVerifyKeyRejected() {
+	created, key, err := suite.service.CreateAPIKey(models.APIKey{ClientID: "ops", Admin: true})
+	suite.Require().NoError(err)
+	client, err := suite.service.VerifyKey(key)
+	suite.Require().NoError(err)
+	suite.True(client.Admin)
+
+	for _, rejected := range []string{
+		"",
+		"not-a-key",
+		KeyPrefix + "missing_secret",
+		KeyPrefix + created.ID + "_wrong",
+		strings.TrimPrefix(key, KeyPrefix),
+	} {
+		_, err := suite.service.VerifyKey(rejected)
+		suite.ErrorIs(err, auth.ErrUnauthenticated, rejected)
+	}
+
+	// Revoked keys are rejected, revoking again changes nothing
+	revoked, err := suite.service.RevokeAPIKey(created.ID)
+	suite.Require().NoError(err)
+	suite.True(revoked.Revoked())
+	_, err = suite.service.VerifyKey(key)
+	suite.ErrorIs(err, auth.ErrUnauthenticated)
+	again, err := suite.service.RevokeAPIKey(created.ID)
+	suite.NoError(err)
+	suite.Equal(revoked, again)
+
+	list, err := suite.service.ListAPIKeys()
+	suite.NoError(err)
+	suite.Equal([]models.APIKey{revoked}, list)
+}
+
+func (suite *APIKeyServiceTestSuite) TestRevokeMissingAPIKey() {
+	_, err := suite.service.RevokeAPIKey("missing")
+	suite.ErrorIs(err, repo.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeyServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyServiceTestSuite))
+}
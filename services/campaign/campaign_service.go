@@ -0,0 +1,86 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+
+	"github.com/google/uuid"
+)
+
+type CampaignService interface {
+	CreateCampaign(c models.Campaign) (models.Campaign, error)
+	GetCampaign(id string) (models.Campaign, error)
+	ListCampaigns() ([]models.Campaign, error)
+	UpdateCampaign(id string, c models.Campaign) (models.Campaign, error)
+	DeleteCampaign(id string) error
+}
+
+type campaignServiceImpl struct {
+	store repo.CampaignStore
+}
+
+// NewCampaignService creates a CampaignService backed by the given store
+func NewCampaignService(store repo.CampaignStore) CampaignService {
+	return &campaignServiceImpl{store: store}
+}
+
+// Validates and stores a new campaign under a generated ID. Invalid campaigns fail with models.ValidationErrors.
+func (s *campaignServiceImpl) CreateCampaign(c models.Campaign) (models.Campaign, error) {
+	if errs := c.Validate(); len(errs) > 0 {
+		return models.Campaign{}, errs
+	}
+	c.ID = uuid.New().String()
+	if err := s.store.PutCampaign(c); err != nil {
+		return models.Campaign{}, fmt.Errorf("failed to store campaign with id %s: %w", c.ID, err)
+	}
+	return c, nil
+}
+
+// Retrieves a campaign by ID
+func (s *campaignServiceImpl) GetCampaign(id string) (models.Campaign, error) {
+	c, err := s.store.GetCampaign(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrCampaignNotFound) {
+			return models.Campaign{}, fmt.Errorf("campaign with id %s does not exist: %w", id, err)
+		}
+		return models.Campaign{}, fmt.Errorf("failed to retrieve campaign with id %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// Lists every campaign, past, running and upcoming, ordered by start date
+func (s *campaignServiceImpl) ListCampaigns() ([]models.Campaign, error) {
+	list, err := s.store.ListCampaigns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	return list, nil
+}
+
+// Replaces an existing campaign. Receipts already awarded keep the points of the terms they were awarded under.
+func (s *campaignServiceImpl) UpdateCampaign(id string, c models.Campaign) (models.Campaign, error) {
+	if errs := c.Validate(); len(errs) > 0 {
+		return models.Campaign{}, errs
+	}
+	if _, err := s.GetCampaign(id); err != nil {
+		return models.Campaign{}, err
+	}
+	c.ID = id
+	if err := s.store.PutCampaign(c); err != nil {
+		return models.Campaign{}, fmt.Errorf("failed to store campaign with id %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// Deletes a campaign by ID, receipts already awarded keep its points
+func (s *campaignServiceImpl) DeleteCampaign(id string) error {
+	if err := s.store.DeleteCampaign(id); err != nil {
+		if errors.Is(err, repo.ErrCampaignNotFound) {
+			return fmt.Errorf("campaign with id %s does not exist: %w", id, err)
+		}
+		return fmt.Errorf("failed to delete campaign with id %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package campaign
+
+import (
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CampaignServiceTestSuite defines the suite for service tests
+type CampaignServiceTestSuite struct {
+	suite.Suite
+	service      CampaignService
+	store        *repo.MemoryStore
+	mockCampaign models.Campaign
+}
+
+// SetupTest initializes the suite
+func (suite *CampaignServiceTestSuite) SetupTest() {
+	// Use a fresh storage for each test
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewCampaignService(suite.store)
+	suite.mockCampaign = models.Campaign{
+		Name:       "Double points at Target",
+		StartDate:  "2022-01-01",
+		EndDate:    "2022-01-02",
+		Retailer:   "Target",
+		Multiplier: 2,
+	}
+}
+
+func (suite *CampaignServiceTestSuite) TestCreateCampaign() {
+	created, err := suite.service.CreateCampaign(suite.mockCampaign)
+	suite.NoError(err)
+	suite.NotEmpty(created.ID)
+
+	got, err := suite.service.GetCampaign(created.ID)
+	suite.NoError(err)
+	suite.Equal(created, got)
+
+	list, err := suite.service.ListCampaigns()
+	suite.NoError(err)
+	suite.Equal([]models.Campaign{created}, list)
+}
+
+func (suite *CampaignServiceTestSuite) TestCreateInvalidCampaign() {
+	suite.mockCampaign.EndDate = "2021-12-31"
+	_, err := suite.service.CreateCampaign(suite.mockCampaign)
+	var errs models.ValidationErrors
+	suite.Require().ErrorAs(err, &errs)
+	suite.Equal("endDate", errs[0].Field)
+
+	list, err := suite.service.ListCampaigns()
+	suite.NoError(err)
+	suite.Empty(list)
+}
+
+func (suite *CampaignServiceTestSuite) TestUpdateCampaign() {
+	created, err := suite.service.CreateCampaign(suite.mockCampaign)
+	suite.Require().NoError(err)
+
+	update := suite.mockCampaign
+	update.Bonus = 100
+	updated, err := suite.service.UpdateCampaign(created.ID, update)
+	suite.NoError(err)
+	suite.Equal(created.ID, updated.ID)
+	suite.Equal(int64(100), updated.Bonus)
+
+	_, err = suite.service.UpdateCampaign("missing", update)
+	suite.ErrorIs(err, repo.ErrCampaignNotFound)
+}
+
+func (suite *CampaignServiceTestSuite) TestDeleteCampaign() {
+	created, err := suite.service.CreateCampaign(suite.mockCampaign)
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.service.DeleteCampaign(created.ID))
+	_, err = suite.service.GetCampaign(created.ID)
+	suite.ErrorIs(err, repo.ErrCampaignNotFound)
+	suite.ErrorIs(suite.service.DeleteCampaign(created.ID), repo.ErrCampaignNotFound)
+}
+
+func TestCampaignServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(CampaignServiceTestSuite))
+}
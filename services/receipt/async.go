@@ -0,0 +1,233 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAsyncDisabled is returned by SubmitReceipt when asynchronous processing was not enabled
+	ErrAsyncDisabled = errors.New("asynchronous processing is disabled")
+	// ErrQueueFull is returned by SubmitReceipt when every slot of the processing queue is taken
+	ErrQueueFull = errors.New("processing queue is full")
+	// ErrDraining is returned by SubmitReceipt once Drain was called
+	ErrDraining = errors.New("processing queue is draining for shutdown")
+)
+
+// JobStatus is how far a submitted receipt has been processed
+type JobStatus string
+
+const (
+	// JobPending receipts are queued or being processed
+	JobPending JobStatus = "pending"
+	// JobProcessed receipts are stored with their points
+	JobProcessed JobStatus = "processed"
+	// JobFailed receipts could not be processed and were not stored
+	JobFailed JobStatus = "failed"
+)
+
+// Job reports the status of a receipt, with the stored data once it is processed
+type Job struct {
+	ID     string
+	Status JobStatus
+	// Data is set for processed receipts
+	Data repo.ReceiptData
+	// Err is why a failed receipt could not be processed
+	Err error
+}
+
+// failedJobRetention is how long the status of a receipt that failed to process is kept
+const failedJobRetention = 24 * time.Hour
+
+// WithAsyncProcessing enables SubmitReceipt, which queues receipts for workers goroutines to
+// process. At most queueSize receipts wait for a worker, SubmitReceipt fails with ErrQueueFull beyond that.
+func WithAsyncProcessing(workers, queueSize int) Option {
+	return func(r *receiptServiceImpl) {
+		r.async = &pipeline{
+			workers: max(workers, 1),
+			queue:   make(chan queuedReceipt, queueSize),
+			jobs:    make(map[string]jobState),
+		}
+	}
+}
+
+// jobState is the status of a pending or failed receipt
+type jobState struct {
+	status   JobStatus
+	err      error
+	failedAt time.Time
+	// owner is the client that submitted the receipt
+	owner string
+}
+
+// queuedReceipt is an admitted receipt waiting for a worker, with the context it was submitted in.
+// The context is detached from the request so that it outlives it, keeping only its values such as the logger.
+type queuedReceipt struct {
+	ctx     context.Context
+	receipt models.Receipt
+}
+
+// pipeline is a bounded queue of admitted receipts drained by a fixed number of workers.
+// It tracks the receipts that are pending or failed; processed receipts are only in the store.
+type pipeline struct {
+	workers int
+	queue   chan queuedReceipt
+	wg      sync.WaitGroup
+	now     func() time.Time
+	// mu guards closed and jobs, and orders sends on queue before closing it
+	mu     sync.Mutex
+	closed bool
+	jobs   map[string]jobState
+}
+
+// start runs the workers, which process every queued receipt until the queue is closed
+func (p *pipeline) start(process func(context.Context, models.Receipt) (repo.ReceiptData, error), now func() time.Time) {
+	p.now = now
+	for range p.workers {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for queued := range p.queue {
+				_, err := process(queued.ctx, queued.receipt)
+				if err != nil {
+					logging.FromContext(queued.ctx).Error("failed to process queued receipt", "receipt_id", queued.receipt.ID, "error", err)
+				}
+				p.finish(queued.receipt.ID, err)
+			}
+		}()
+	}
+}
+
+// enqueue queues a receipt and marks it pending, unless the queue is full or draining
+func (p *pipeline) enqueue(ctx context.Context, receipt models.Receipt) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrDraining
+	}
+	select {
+	case p.queue <- queuedReceipt{ctx: context.WithoutCancel(ctx), receipt: receipt}:
+		p.jobs[receipt.ID] = jobState{status: JobPending, owner: ownerOf(ctx)}
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// finish forgets a processed receipt, which is now in the store, or records why it failed
+func (p *pipeline) finish(id string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.jobs, id)
+		return
+	}
+	now := p.now()
+	// Failures are rare, so expired ones are dropped when another one is recorded
+	for jobID, job := range p.jobs {
+		if job.status == JobFailed && now.Sub(job.failedAt) > failedJobRetention {
+			delete(p.jobs, jobID)
+		}
+	}
+	p.jobs[id] = jobState{status: JobFailed, err: err, failedAt: now, owner: p.jobs[id].owner}
+}
+
+// job returns the status of a pending or failed receipt
+func (p *pipeline) job(id string) (jobState, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.jobs[id]
+	return job, ok
+}
+
+// drain stops accepting receipts and waits for the workers to process the queued ones
+func (p *pipeline) drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Queues a receipt to be processed in the background and returns its ID with the pending status.
+// A duplicate returned in return-existing mode is reported with the status of the existing receipt.
+func (r *receiptServiceImpl) SubmitReceipt(ctx context.Context, extReceipt models.ExtReceipt) (_ Job, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.SubmitReceipt", attrItemCount.Int(len(extReceipt.Items)))
+	defer func() { endSpan(span, err) }()
+
+	if r.async == nil {
+		return Job{}, ErrAsyncDisabled
+	}
+	internalReceipt, existing, err := r.admitReceipt(ctx, extReceipt)
+	var duplicate *DuplicateReceiptError
+	if errors.As(err, &duplicate) && duplicate.Pending {
+		if job, ok := r.async.job(duplicate.ExistingID); ok {
+			return Job{ID: duplicate.ExistingID, Status: job.status, Err: job.err}, nil
+		}
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	if existing != nil {
+		return Job{ID: existing.Receipt.ID, Status: JobProcessed, Data: *existing}, nil
+	}
+
+	if err := r.async.enqueue(ctx, internalReceipt); err != nil {
+		r.fingerprints.release(fingerprintKey(ownerOf(ctx), internalReceipt), internalReceipt.ID)
+		return Job{}, err
+	}
+	logging.FromContext(ctx).Info("receipt queued", "receipt_id", internalReceipt.ID)
+	return Job{ID: internalReceipt.ID, Status: JobPending}, nil
+}
+
+// Reports whether a receipt is pending, processed or failed, with its stored data once processed
+func (r *receiptServiceImpl) GetJob(ctx context.Context, id string) (_ Job, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.GetJob", attrReceiptID.String(id))
+	defer func() { endSpan(span, err) }()
+
+	// Receipts leave the pending jobs only once stored, so look there first
+	if r.async != nil {
+		if job, ok := r.async.job(id); ok && visible(ctx, job.owner) {
+			return Job{ID: id, Status: job.status, Err: job.err}, nil
+		}
+	}
+	receiptData, err := r.getReceiptData(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{ID: id, Status: JobProcessed, Data: receiptData}, nil
+}
+
+// processQueued completes a receipt taken from the queue, in a span under the span of the request that submitted it
+func (r *receiptServiceImpl) processQueued(ctx context.Context, receipt models.Receipt) (_ repo.ReceiptData, err error) {
+	ctx, span := r.startSpan(ctx, "receipt.process", attrReceiptID.String(receipt.ID), attrItemCount.Int(len(receipt.Items)))
+	defer func() { endSpan(span, err) }()
+	return r.completeReceipt(ctx, receipt)
+}
+
+// Stops accepting receipts for background processing and waits until the queued ones are processed,
+// or ctx is done. Without asynchronous processing it returns immediately.
+func (r *receiptServiceImpl) Drain(ctx context.Context) error {
+	if r.async == nil {
+		return nil
+	}
+	return r.async.drain(ctx)
+}
@@ -0,0 +1,111 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"receipt-processor/logging"
+	"receipt-processor/repo"
+	"time"
+)
+
+// blockingMatcher holds every receipt in processing until release is closed, then fails those from "Broken"
+type blockingMatcher struct {
+	started chan string
+	release chan struct{}
+}
+
+func (m blockingMatcher) Match(name string) (string, error) {
+	m.started <- name
+	<-m.release
+	if name == "Broken" {
+		return "", errors.New("registry unavailable")
+	}
+	return "", nil
+}
+
+func (suite *ReceiptServiceTestSuite) TestSubmitReceipt() {
+	matcher := blockingMatcher{started: make(chan string, 10), release: make(chan struct{})}
+	suite.service = NewReceiptService(suite.store, WithAsyncProcessing(1, 1), WithRetailerMatcher(matcher))
+
+	job, err := suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.Equal(JobPending, job.Status)
+	// The worker holds the first receipt, the second waits in the queue and the third does not fit
+	<-matcher.started
+	broken := newMockExtReceipt()
+	broken.Retailer = "Broken"
+	failing, err := suite.service.SubmitReceipt(ctx, broken)
+	suite.Require().NoError(err)
+	_, err = suite.service.SubmitReceipt(ctx, newMockExtReceipt())
+	suite.ErrorIs(err, ErrQueueFull)
+
+	status, err := suite.service.GetJob(ctx, job.ID)
+	suite.NoError(err)
+	suite.Equal(JobPending, status.Status)
+	_, err = suite.store.Get(job.ID)
+	suite.ErrorIs(err, repo.ErrNotFound)
+
+	close(matcher.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	suite.Require().NoError(suite.service.Drain(ctx))
+
+	status, err = suite.service.GetJob(ctx, job.ID)
+	suite.NoError(err)
+	suite.Equal(JobProcessed, status.Status)
+	suite.Equal(int64(28), status.Data.Point)
+
+	status, err = suite.service.GetJob(ctx, failing.ID)
+	suite.NoError(err)
+	suite.Equal(JobFailed, status.Status)
+	suite.Error(status.Err)
+	_, err = suite.store.Get(failing.ID)
+	suite.ErrorIs(err, repo.ErrNotFound)
+
+	_, err = suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.ErrorIs(err, ErrDraining)
+	_, err = suite.service.GetJob(ctx, "missing")
+	suite.ErrorIs(err, repo.ErrNotFound)
+}
+
+func (suite *ReceiptServiceTestSuite) TestSubmitReceiptLogsWithRequestContext() {
+	matcher := blockingMatcher{started: make(chan string, 10), release: make(chan struct{})}
+	close(matcher.release)
+	suite.service = NewReceiptService(suite.store, WithAsyncProcessing(1, 1), WithRetailerMatcher(matcher))
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil)).With("request_id", "request-1")
+
+	// The receipt is processed after the request is over
+	requestCtx, cancel := context.WithCancel(logging.NewContext(context.Background(), logger))
+	broken := newMockExtReceipt()
+	broken.Retailer = "Broken"
+	job, err := suite.service.SubmitReceipt(requestCtx, broken)
+	suite.Require().NoError(err)
+	cancel()
+	suite.Require().NoError(suite.service.Drain(context.Background()))
+
+	suite.Contains(buf.String(), `"msg":"failed to process queued receipt","request_id":"request-1","receipt_id":"`+job.ID+`"`)
+}
+
+func (suite *ReceiptServiceTestSuite) TestSubmitDuplicateReceipt() {
+	suite.service = NewReceiptService(suite.store, WithAsyncProcessing(2, 10), WithDuplicateDetection(DuplicateReturnExisting))
+
+	first, err := suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	again, err := suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.Equal(first.ID, again.ID)
+	suite.Require().NoError(suite.service.Drain(context.Background()))
+
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(1, count)
+}
+
+func (suite *ReceiptServiceTestSuite) TestSubmitReceiptDisabled() {
+	_, err := suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.ErrorIs(err, ErrAsyncDisabled)
+	suite.NoError(suite.service.Drain(context.Background()))
+}
@@ -0,0 +1,159 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"receipt-processor/repo"
+	"sync"
+	"time"
+)
+
+// defaultDuplicateWait is how long a duplicate submitted in return-existing mode waits for
+// a concurrent submission to store the receipt it duplicates
+const defaultDuplicateWait = 5 * time.Second
+
+// DuplicateMode decides what happens when a receipt with the same contents is submitted again
+type DuplicateMode string
+
+const (
+	// DuplicateAllow stores every submission as a new receipt
+	DuplicateAllow DuplicateMode = "allow"
+	// DuplicateReject fails the submission with a DuplicateReceiptError
+	DuplicateReject DuplicateMode = "reject"
+	// DuplicateReturnExisting returns the receipt stored first, with the points it was awarded
+	DuplicateReturnExisting DuplicateMode = "return-existing"
+)
+
+// ParseDuplicateMode converts a string such as "reject" into a DuplicateMode
+func ParseDuplicateMode(s string) (DuplicateMode, error) {
+	switch m := DuplicateMode(s); m {
+	case DuplicateAllow, DuplicateReject, DuplicateReturnExisting:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown duplicate mode %q", s)
+}
+
+// ErrDuplicateReceipt is matched by errors.Is for a DuplicateReceiptError
+var ErrDuplicateReceipt = errors.New("duplicate receipt")
+
+// ErrDuplicatePending is matched by errors.Is for a DuplicateReceiptError of a receipt still being processed
+var ErrDuplicatePending = errors.New("duplicated receipt is still being processed")
+
+// DuplicateReceiptError reports the ID of the receipt a rejected submission duplicates
+type DuplicateReceiptError struct {
+	ExistingID string
+	// Pending is set in return-existing mode while the existing receipt is not stored yet,
+	// the submission returns it once retried after it is stored
+	Pending bool
+}
+
+func (e *DuplicateReceiptError) Error() string {
+	if e.Pending {
+		return fmt.Sprintf("receipt duplicates receipt %s, which is still being processed", e.ExistingID)
+	}
+	return fmt.Sprintf("receipt duplicates existing receipt %s", e.ExistingID)
+}
+
+func (e *DuplicateReceiptError) Is(target error) bool {
+	return target == ErrDuplicateReceipt || (e.Pending && target == ErrDuplicatePending)
+}
+
+// WithDuplicateDetection detects receipts whose contents were already submitted
+func WithDuplicateDetection(mode DuplicateMode) Option {
+	return func(r *receiptServiceImpl) {
+		r.duplicates = mode
+	}
+}
+
+// fingerprintIndex maps the fingerprints of the receipts of each owner to the ID of the receipt stored first.
+// It is loaded from the store on first use.
+type fingerprintIndex struct {
+	mu     sync.Mutex
+	loaded bool
+	ids    map[string]string
+	// pending holds a channel for each claim whose receipt is not stored yet, closed once it is stored or released
+	pending map[string]chan struct{}
+}
+
+// reserve returns the ID already holding a fingerprint, or claims it for id.
+// The caller must release the claim if the receipt ends up not being stored.
+func (x *fingerprintIndex) reserve(store repo.ReceiptStore, fingerprint, id string) (string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(store); err != nil {
+		return "", err
+	}
+	if existing, ok := x.ids[fingerprint]; ok {
+		return existing, nil
+	}
+	x.ids[fingerprint] = id
+	x.pending[id] = make(chan struct{})
+	return "", nil
+}
+
+// release drops a fingerprint if it is still held by id
+func (x *fingerprintIndex) release(fingerprint, id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.ids[fingerprint] == id {
+		delete(x.ids, fingerprint)
+	}
+	x.settle(id)
+}
+
+// stored marks the receipt claiming a fingerprint under id as stored
+func (x *fingerprintIndex) stored(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.settle(id)
+}
+
+// settle wakes the duplicates waiting for the claim of id. The caller must hold mu.
+func (x *fingerprintIndex) settle(id string) {
+	if done, ok := x.pending[id]; ok {
+		close(done)
+		delete(x.pending, id)
+	}
+}
+
+// wait blocks until the receipt claiming a fingerprint under id is stored or released, for at most timeout,
+// and reports whether it was
+func (x *fingerprintIndex) wait(ctx context.Context, id string, timeout time.Duration) bool {
+	x.mu.Lock()
+	done, ok := x.pending[id]
+	x.mu.Unlock()
+	if !ok {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (x *fingerprintIndex) load(store repo.ReceiptStore) error {
+	if x.loaded {
+		return nil
+	}
+	list, err := store.List(repo.ListQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to load receipt fingerprints: %w", err)
+	}
+	x.ids = make(map[string]string, len(list))
+	x.pending = make(map[string]chan struct{})
+	for _, data := range list {
+		fingerprint := fingerprintKey(data.Owner, data.Receipt)
+		if _, exists := x.ids[fingerprint]; !exists {
+			x.ids[fingerprint] = data.Receipt.ID
+		}
+	}
+	x.loaded = true
+	return nil
+}
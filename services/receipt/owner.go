@@ -0,0 +1,52 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"receipt-processor/auth"
+	"receipt-processor/models"
+)
+
+// Receipts are owned by the client that submitted them. A client only sees its own receipts,
+// while admins and callers without a client, such as the rescore command or requests to a
+// server without authentication, see every receipt.
+
+// ErrForeignAccount is returned for receipts crediting the account of another client
+var ErrForeignAccount = errors.New("account belongs to another client")
+
+// checkAccount fails with ErrForeignAccount unless the client of ctx may credit an account.
+// A client's account is named after its ID, while admins and callers without a client credit any account.
+func checkAccount(ctx context.Context, accountID string) error {
+	if owner := visibleOwner(ctx); owner != "" && accountID != "" && accountID != owner {
+		return fmt.Errorf("receipt credits account %s: %w", accountID, ErrForeignAccount)
+	}
+	return nil
+}
+
+// ownerOf returns the client owning the receipts submitted in ctx, empty if ctx is not authenticated
+func ownerOf(ctx context.Context) string {
+	client, _ := auth.FromContext(ctx)
+	return client.ID
+}
+
+// visibleOwner returns the owner the receipts visible in ctx are restricted to, empty if every receipt is visible
+func visibleOwner(ctx context.Context) string {
+	client, ok := auth.FromContext(ctx)
+	if !ok || client.Admin {
+		return ""
+	}
+	return client.ID
+}
+
+// visible reports whether a receipt owned by owner is visible in ctx
+func visible(ctx context.Context, owner string) bool {
+	scope := visibleOwner(ctx)
+	return scope == "" || scope == owner
+}
+
+// fingerprintKey indexes a receipt for duplicate detection. Duplicates are only looked for among
+// the receipts of the same owner, so a client is never told the ID of another client's receipt.
+func fingerprintKey(owner string, receipt models.Receipt) string {
+	return owner + "/" + receipt.Fingerprint()
+}
@@ -0,0 +1,147 @@
+package receipt
+
+import (
+	"context"
+	"receipt-processor/auth"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+)
+
+// Contexts of the requests of two clients and of an admin
+var (
+	acmeCtx   = auth.NewContext(ctx, auth.Client{ID: "acme"})
+	globexCtx = auth.NewContext(ctx, auth.Client{ID: "globex"})
+	adminCtx  = auth.NewContext(ctx, auth.Client{ID: "ops", Admin: true})
+)
+
+func (suite *ReceiptServiceTestSuite) TestReceiptsAreOwned() {
+	id, err := suite.service.ProcessReceipt(acmeCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	stored, err := suite.store.Get(id)
+	suite.Require().NoError(err)
+	suite.Equal("acme", stored.Owner)
+
+	// The owner, admins and callers without a client see the receipt
+	for _, visibleCtx := range []context.Context{acmeCtx, adminCtx, ctx} {
+		points, err := suite.service.GetPoints(visibleCtx, id)
+		suite.NoError(err)
+		suite.Equal(int64(28), points)
+	}
+
+	// Other clients are told it does not exist
+	_, err = suite.service.GetPoints(globexCtx, id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	_, err = suite.service.GetPointsBreakdown(globexCtx, id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	_, err = suite.service.GetReceipt(globexCtx, id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	suite.ErrorIs(suite.service.DeleteReceipt(globexCtx, id), repo.ErrNotFound)
+	_, err = suite.store.Get(id)
+	suite.NoError(err)
+}
+
+func (suite *ReceiptServiceTestSuite) TestAccountsOfClient() {
+	store := repo.NewMemoryStore()
+	suite.service = NewReceiptService(store, WithLedger(store), WithAsyncProcessing(1, 1))
+	suite.mockExtReceipt.AccountID = "acme"
+
+	// A client only credits its own account
+	_, err := suite.service.ProcessReceipt(globexCtx, suite.mockExtReceipt)
+	suite.ErrorIs(err, ErrForeignAccount)
+	_, err = suite.service.SubmitReceipt(globexCtx, suite.mockExtReceipt)
+	suite.ErrorIs(err, ErrForeignAccount)
+	results := suite.service.ProcessReceipts(globexCtx, []models.ExtReceipt{suite.mockExtReceipt})
+	suite.ErrorIs(results[0].Err, ErrForeignAccount)
+	_, err = store.Balance("acme")
+	suite.ErrorIs(err, repo.ErrAccountNotFound)
+
+	for _, creditCtx := range []context.Context{acmeCtx, adminCtx, ctx} {
+		_, err := suite.service.ProcessReceipt(creditCtx, suite.mockExtReceipt)
+		suite.NoError(err)
+	}
+	balance, err := store.Balance("acme")
+	suite.Require().NoError(err)
+	suite.Equal(int64(84), balance)
+	suite.Require().NoError(suite.service.Drain(context.Background()))
+}
+
+func (suite *ReceiptServiceTestSuite) TestAccountOfDottedClient() {
+	store := repo.NewMemoryStore()
+	suite.service = NewReceiptService(store, WithLedger(store))
+	dottedCtx := auth.NewContext(ctx, auth.Client{ID: "acme.corp"})
+
+	// A client ID valid for an API key is a valid account ID of its own
+	suite.Require().Empty(models.APIKey{ClientID: "acme.corp"}.Validate())
+	suite.mockExtReceipt.AccountID = "acme.corp"
+	_, err := suite.service.ProcessReceipt(dottedCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	balance, err := store.Balance("acme.corp")
+	suite.NoError(err)
+	suite.Equal(int64(28), balance)
+}
+
+func (suite *ReceiptServiceTestSuite) TestListReceiptsOfOwner() {
+	acmeID, err := suite.service.ProcessReceipt(acmeCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	globexID, err := suite.service.ProcessReceipt(globexCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	listed := func(c context.Context) []string {
+		page, err := suite.service.ListReceipts(c, ListQuery{})
+		suite.Require().NoError(err)
+		var ids []string
+		for _, data := range page.Receipts {
+			ids = append(ids, data.Receipt.ID)
+		}
+		return ids
+	}
+	suite.Equal([]string{acmeID}, listed(acmeCtx))
+	suite.Equal([]string{globexID}, listed(globexCtx))
+	suite.ElementsMatch([]string{acmeID, globexID}, listed(adminCtx))
+	suite.ElementsMatch([]string{acmeID, globexID}, listed(ctx))
+}
+
+func (suite *ReceiptServiceTestSuite) TestDuplicatesOfOwner() {
+	suite.service = NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReturnExisting))
+
+	first, err := suite.service.ProcessReceipt(acmeCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	again, err := suite.service.ProcessReceipt(acmeCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.Equal(first, again)
+
+	// The same receipt from another client is its own, so the ID of the first one is not revealed
+	other, err := suite.service.ProcessReceipt(globexCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.NotEqual(first, other)
+
+	// The fingerprints of stored receipts are loaded with their owner
+	restarted := NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReturnExisting))
+	again, err = restarted.ProcessReceipt(globexCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.Equal(other, again)
+}
+
+func (suite *ReceiptServiceTestSuite) TestJobsOfOwner() {
+	matcher := blockingMatcher{started: make(chan string, 10), release: make(chan struct{})}
+	suite.service = NewReceiptService(suite.store, WithAsyncProcessing(1, 1), WithRetailerMatcher(matcher))
+
+	job, err := suite.service.SubmitReceipt(acmeCtx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	<-matcher.started
+
+	// Pending receipts are hidden from other clients like stored ones
+	_, err = suite.service.GetJob(globexCtx, job.ID)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	status, err := suite.service.GetJob(acmeCtx, job.ID)
+	suite.Require().NoError(err)
+	suite.Equal(JobPending, status.Status)
+
+	close(matcher.release)
+	suite.Require().NoError(suite.service.Drain(context.Background()))
+	stored, err := suite.store.Get(job.ID)
+	suite.Require().NoError(err)
+	suite.Equal("acme", stored.Owner)
+	_, err = suite.service.GetJob(globexCtx, job.ID)
+	suite.ErrorIs(err, repo.ErrNotFound)
+}
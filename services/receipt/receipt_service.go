@@ -1,122 +1,610 @@
 package receipt
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"math"
+	"receipt-processor/logging"
 	"receipt-processor/models"
 	"receipt-processor/repo"
-	"regexp"
-	"strconv"
-	"strings"
+	"receipt-processor/services/rules"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ReceiptService processes and scores receipts. Methods log with the logger of their context
+// and trace their work, down to each store call, in spans under the span of their context.
 type ReceiptService interface {
-	ProcessReceipt(extReceipt models.ExtReceipt) (string, error)
-	GetPoints(id string) (int64, error)
+	ProcessReceipt(ctx context.Context, extReceipt models.ExtReceipt) (string, error)
+	SubmitReceipt(ctx context.Context, extReceipt models.ExtReceipt) (Job, error)
+	GetJob(ctx context.Context, id string) (Job, error)
+	Drain(ctx context.Context) error
+	ScoreReceipt(ctx context.Context, extReceipt models.ExtReceipt) (ScoreResult, error)
+	GetPoints(ctx context.Context, id string) (int64, error)
+	GetPointsBreakdown(ctx context.Context, id string) (models.PointsBreakdown, error)
+	ProcessReceipts(ctx context.Context, extReceipts []models.ExtReceipt) []BatchResult
+	GetReceipt(ctx context.Context, id string) (repo.ReceiptData, error)
+	ListReceipts(ctx context.Context, q ListQuery) (ReceiptPage, error)
+	DeleteReceipt(ctx context.Context, id string) error
+	Rescore(ctx context.Context, q RescoreQuery) (RescoreReport, error)
 }
 
-type receiptServiceImpl struct{}
+// Outcome of one receipt of a batch, either the stored ID and points or an error
+type BatchResult struct {
+	ID     string
+	Points int64
+	Err    error
+}
+
+// Points a receipt would be awarded, explained rule by rule
+type ScoreResult struct {
+	RuleVersion string
+	// Breakdown explains the base rules and then each campaign
+	Breakdown models.PointsBreakdown
+	Campaigns []models.AppliedCampaign
+}
+
+// DefaultPageSize is the number of receipts listed when ListQuery.Limit is not set
+const DefaultPageSize = 20
+
+// ListQuery selects a page of receipts, continuing after Cursor if set
+type ListQuery struct {
+	Cursor string
+	Limit  int
+	Filter repo.ListQuery
+}
+
+// A page of receipts ordered by ID, NextCursor is empty on the last page
+type ReceiptPage struct {
+	Receipts   []repo.ReceiptData
+	NextCursor string
+}
+
+// RetailerMatcher maps retailer names to canonical retailer IDs
+type RetailerMatcher interface {
+	// Match returns the ID of the retailer a name matches, or an empty ID if it matches none
+	Match(name string) (string, error)
+}
 
-func NewReceiptService() ReceiptService {
-	return &receiptServiceImpl{}
+// EventPublisher delivers receipt events to subscribers, such as webhooks
+type EventPublisher interface {
+	// Publish delivers an event in the background
+	Publish(event models.ReceiptEvent)
+}
+
+// Observer is told about every receipt the service stores, for example to export metrics
+type Observer interface {
+	// ReceiptProcessed is called with the points breakdown of each processed receipt once it is stored
+	ReceiptProcessed(breakdown models.PointsBreakdown)
+}
+
+// ErrInvalidCursor is returned when a page cursor was not issued by ListReceipts
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type receiptServiceImpl struct {
+	store        repo.ReceiptStore
+	ledger       repo.LedgerStore
+	campaigns    repo.CampaignStore
+	retailers    RetailerMatcher
+	events       EventPublisher
+	observer     Observer
+	now          func() time.Time
+	rules        *rules.RuleSet
+	ruleSets     map[string]*rules.RuleSet
+	duplicates   DuplicateMode
+	fingerprints fingerprintIndex
+	// duplicateWait bounds how long a duplicate waits for the receipt it duplicates to be stored
+	duplicateWait time.Duration
+	async         *pipeline
+	tracer        trace.Tracer
+	// mu serializes deletes with rescoring, which rewrites stored receipts
+	mu sync.Mutex
+}
+
+// Option customizes a ReceiptService
+type Option func(*receiptServiceImpl)
+
+// WithRuleSet scores receipts with the given rule set instead of the default rules
+func WithRuleSet(rs *rules.RuleSet) Option {
+	return func(r *receiptServiceImpl) {
+		r.rules = rs
+	}
+}
+
+// WithLedger credits the points of receipts submitted with an account ID to that account
+func WithLedger(ledger repo.LedgerStore) Option {
+	return func(r *receiptServiceImpl) {
+		r.ledger = ledger
+	}
+}
+
+// WithCampaigns adds the points of the running campaigns a receipt qualifies for to its base points
+func WithCampaigns(campaigns repo.CampaignStore) Option {
+	return func(r *receiptServiceImpl) {
+		r.campaigns = campaigns
+	}
+}
+
+// WithRetailerMatcher records on each receipt the canonical retailer its retailer name matches
+func WithRetailerMatcher(m RetailerMatcher) Option {
+	return func(r *receiptServiceImpl) {
+		r.retailers = m
+	}
+}
+
+// WithEventPublisher publishes an event whenever a receipt is processed, deleted or rescored
+func WithEventPublisher(p EventPublisher) Option {
+	return func(r *receiptServiceImpl) {
+		r.events = p
+	}
+}
+
+// WithObserver tells the observer about every processed receipt
+func WithObserver(o Observer) Option {
+	return func(r *receiptServiceImpl) {
+		r.observer = o
+	}
+}
+
+// NewReceiptService creates a ReceiptService backed by the given store
+func NewReceiptService(store repo.ReceiptStore, opts ...Option) ReceiptService {
+	r := &receiptServiceImpl{
+		store:         store,
+		now:           time.Now,
+		rules:         rules.Default(),
+		ruleSets:      make(map[string]*rules.RuleSet),
+		duplicates:    DuplicateAllow,
+		tracer:        defaultTracer(),
+		duplicateWait: defaultDuplicateWait,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	// The current rule set takes precedence over a historical one with the same version
+	r.ruleSets[r.rules.Version] = r.rules
+	if r.async != nil {
+		r.async.start(r.processQueued, r.now)
+	}
+	return r
 }
 
 // Stores a receipt, generates an ID, process points and returns the ID
-func (r *receiptServiceImpl) ProcessReceipt(extReceipt models.ExtReceipt) (string, error) {
+func (r *receiptServiceImpl) ProcessReceipt(ctx context.Context, extReceipt models.ExtReceipt) (_ string, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.ProcessReceipt", attrItemCount.Int(len(extReceipt.Items)))
+	defer func() { endSpan(span, err) }()
+
+	receiptData, err := r.processReceipt(ctx, extReceipt)
+	if err != nil {
+		return "", err
+	}
+	return receiptData.Receipt.ID, nil
+}
+
+// Scores a receipt with the current rules without storing it, generating an ID or crediting an account
+func (r *receiptServiceImpl) ScoreReceipt(ctx context.Context, extReceipt models.ExtReceipt) (_ ScoreResult, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.ScoreReceipt", attrItemCount.Int(len(extReceipt.Items)))
+	defer func() { endSpan(span, err) }()
+
+	// Convert external receipt to internal receipt, rejecting malformed amounts
+	internalReceipt, err := extReceipt.ToReceipt("")
+	if err != nil {
+		return ScoreResult{}, err
+	}
+	if internalReceipt.RetailerID, err = r.matchRetailer(ctx, internalReceipt.Retailer); err != nil {
+		return ScoreResult{}, err
+	}
+	breakdown, applied, err := r.score(ctx, internalReceipt)
+	if err != nil {
+		return ScoreResult{}, err
+	}
+	return ScoreResult{RuleVersion: r.rules.Version, Breakdown: breakdown, Campaigns: applied}, nil
+}
+
+// score awards a receipt the points of the current rules, then those of every campaign it qualifies for
+func (r *receiptServiceImpl) score(ctx context.Context, receipt models.Receipt) (_ models.PointsBreakdown, _ []models.AppliedCampaign, err error) {
+	ctx, span := r.startSpan(ctx, "receipt.score",
+		attrReceiptID.String(receipt.ID),
+		attrItemCount.Int(len(receipt.Items)),
+		attrRuleVersion.String(r.rules.Version),
+		attrRuleCount.Int(len(r.rules.Rules)))
+	defer func() { endSpan(span, err) }()
+
+	breakdown := r.rules.Score(receipt)
+	if r.campaigns == nil {
+		span.SetAttributes(attrPoints.Int64(breakdown.Total))
+		return breakdown, nil, nil
+	}
+	_, storeSpan := r.startStoreSpan(ctx, "ListCampaigns")
+	campaigns, err := r.campaigns.ListCampaigns()
+	endSpan(storeSpan, err)
+	if err != nil {
+		return models.PointsBreakdown{}, nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	var applied []models.AppliedCampaign
+	for _, campaign := range campaigns {
+		if campaign.Retailer != "" && receipt.RetailerID != "" {
+			// Campaigns match receipts of their retailer under any of its names
+			if campaign.RetailerID, err = r.matchRetailer(ctx, campaign.Retailer); err != nil {
+				return models.PointsBreakdown{}, nil, err
+			}
+		}
+		if campaign.Matches(receipt) {
+			applied = append(applied, campaign.Apply(breakdown.Total))
+		}
+	}
+	breakdown = explainCampaigns(breakdown, applied)
+	span.SetAttributes(attrPoints.Int64(breakdown.Total), attrCampaignsCount.Int(len(applied)))
+	return breakdown, applied, nil
+}
+
+// matchRetailer returns the ID of the canonical retailer a name matches, empty if it matches none or no matcher is configured
+func (r *receiptServiceImpl) matchRetailer(ctx context.Context, name string) (_ string, err error) {
+	if r.retailers == nil {
+		return "", nil
+	}
+	_, span := r.startSpan(ctx, "retailers.Match")
+	defer func() { endSpan(span, err) }()
+	id, err := r.retailers.Match(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to match retailer %q: %w", name, err)
+	}
+	return id, nil
+}
+
+// explainCampaigns adds one result per applied campaign to the breakdown of the base points
+func explainCampaigns(breakdown models.PointsBreakdown, applied []models.AppliedCampaign) models.PointsBreakdown {
+	basePoints := breakdown.Total
+	for _, campaign := range applied {
+		breakdown.Rules = append(breakdown.Rules, models.RuleResult{
+			Rule:        "campaign",
+			Description: fmt.Sprintf("Points of the %q campaign.", campaign.Name),
+			Matched:     true,
+			Inputs: map[string]any{
+				"campaignId": campaign.ID,
+				"multiplier": campaign.Multiplier,
+				"bonus":      campaign.Bonus,
+				"basePoints": basePoints,
+			},
+			Points: campaign.Points,
+		})
+		breakdown.Total += campaign.Points
+	}
+	return breakdown
+}
+
+// Validates, scores and stores every receipt independently; one failing receipt does not affect the others
+func (r *receiptServiceImpl) ProcessReceipts(ctx context.Context, extReceipts []models.ExtReceipt) []BatchResult {
+	ctx, span := r.startSpan(ctx, "ReceiptService.ProcessReceipts", attrBatchSize.Int(len(extReceipts)))
+	defer span.End()
+
+	results := make([]BatchResult, len(extReceipts))
+	for i, extReceipt := range extReceipts {
+		if errs := extReceipt.Validate(); len(errs) > 0 {
+			results[i].Err = errs
+			continue
+		}
+		// Each receipt of the batch has a span of its own
+		itemCtx, itemSpan := r.startSpan(ctx, "receipt.process", attrItemCount.Int(len(extReceipt.Items)))
+		receiptData, err := r.processReceipt(itemCtx, extReceipt)
+		endSpan(itemSpan, err)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].ID = receiptData.Receipt.ID
+		results[i].Points = receiptData.Point
+	}
+	return results
+}
+
+// Converts, scores and stores a receipt, returning the stored data
+func (r *receiptServiceImpl) processReceipt(ctx context.Context, extReceipt models.ExtReceipt) (repo.ReceiptData, error) {
+	internalReceipt, existing, err := r.admitReceipt(ctx, extReceipt)
+	var duplicate *DuplicateReceiptError
+	if errors.As(err, &duplicate) && duplicate.Pending && r.fingerprints.wait(ctx, duplicate.ExistingID, r.duplicateWait) {
+		// The receipt duplicated was stored or given up meanwhile, so admitting again returns or claims it
+		internalReceipt, existing, err = r.admitReceipt(ctx, extReceipt)
+	}
+	if err != nil {
+		return repo.ReceiptData{}, err
+	}
+	if existing != nil {
+		return *existing, nil
+	}
+	return r.completeReceipt(ctx, internalReceipt)
+}
+
+// admitReceipt converts a receipt under a new ID and claims its fingerprint, which completeReceipt frees again
+// if the receipt is not stored. Duplicates fail with a DuplicateReceiptError, or in return-existing mode the stored
+// receipt is returned as existing instead, failing with a pending DuplicateReceiptError until it is stored.
+func (r *receiptServiceImpl) admitReceipt(ctx context.Context, extReceipt models.ExtReceipt) (models.Receipt, *repo.ReceiptData, error) {
 	// Generate unique ID
 	id := uuid.New().String()
+	trace.SpanFromContext(ctx).SetAttributes(attrReceiptID.String(id))
+	logger := logging.FromContext(ctx)
+	logger.Debug("admitting receipt", "receipt_id", id, logging.Receipt("receipt", extReceipt))
+	if err := checkAccount(ctx, extReceipt.AccountID); err != nil {
+		return models.Receipt{}, nil, err
+	}
 
-	// Convert external receipt to internal receipt
-	var items []models.Item
-	for _, extItem := range extReceipt.Items {
-		items = append(items, models.Item{
-			ShortDescription: extItem.ShortDescription,
-			Price:            extItem.Price,
-		})
+	// Convert external receipt to internal receipt, rejecting malformed amounts
+	internalReceipt, err := extReceipt.ToReceipt(id)
+	if err != nil {
+		return models.Receipt{}, nil, err
 	}
 
-	internalReceipt := models.Receipt{
-		ID:           id,
-		Retailer:     extReceipt.Retailer,
-		PurchaseDate: extReceipt.PurchaseDate,
-		PurchaseTime: extReceipt.PurchaseTime,
-		Items:        items,
-		Total:        extReceipt.Total,
+	// Look for an earlier submission of the same receipt
+	if r.duplicates == DuplicateAllow {
+		return internalReceipt, nil, nil
+	}
+	existingID, err := r.fingerprints.reserve(r.store, fingerprintKey(ownerOf(ctx), internalReceipt), id)
+	if err != nil {
+		return models.Receipt{}, nil, err
+	}
+	if existingID == "" {
+		return internalReceipt, nil, nil
+	}
+	logger.Info("duplicate receipt submitted", "existing_receipt_id", existingID, "duplicates", r.duplicates)
+	if r.duplicates != DuplicateReturnExisting {
+		return models.Receipt{}, nil, &DuplicateReceiptError{ExistingID: existingID}
+	}
+	// Report the points the existing receipt was awarded, which rule changes, campaigns
+	// and rescoring make differ from the points its contents would score now
+	trace.SpanFromContext(ctx).SetAttributes(attrReceiptID.String(existingID))
+	existing, err := r.getReceiptData(ctx, existingID)
+	if errors.Is(err, repo.ErrNotFound) {
+		// A concurrent submission claimed the fingerprint and is still storing the receipt
+		return models.Receipt{}, nil, &DuplicateReceiptError{ExistingID: existingID, Pending: true}
+	}
+	if err != nil {
+		return models.Receipt{}, nil, err
+	}
+	return models.Receipt{}, &existing, nil
+}
+
+// completeReceipt matches, scores and stores an admitted receipt and credits its account
+func (r *receiptServiceImpl) completeReceipt(ctx context.Context, internalReceipt models.Receipt) (_ repo.ReceiptData, err error) {
+	id := internalReceipt.ID
+	// Free the fingerprint again if the receipt is not stored
+	defer func() {
+		if err != nil {
+			r.fingerprints.release(fingerprintKey(ownerOf(ctx), internalReceipt), id)
+		} else {
+			r.fingerprints.stored(id)
+		}
+	}()
+
+	if internalReceipt.RetailerID, err = r.matchRetailer(ctx, internalReceipt.Retailer); err != nil {
+		return repo.ReceiptData{}, err
+	}
+
+	// Calculate points when processing a new receipt, base rules first and then campaigns
+	breakdown, applied, err := r.score(ctx, internalReceipt)
+	if err != nil {
+		return repo.ReceiptData{}, err
 	}
 
 	// Create ReceiptData and save to repo
-	receiptData := repo.ReceiptData{Receipt: internalReceipt, Point: 0}
+	receiptData := repo.ReceiptData{Receipt: internalReceipt, Point: breakdown.Total, RuleVersion: r.rules.Version, Campaigns: applied, Owner: ownerOf(ctx)}
+	if err = r.putStored(ctx, id, receiptData); err != nil {
+		return repo.ReceiptData{}, fmt.Errorf("failed to store receipt with id %s: %w", id, err)
+	}
 
-	// Calculate points when processing a new receipt
-	receiptData.Point = calculatePoints(receiptData.Receipt)
-	repo.UpdateReceiptData(id, receiptData)
-	return id, nil
+	// Credit the points to the account, the receipt is not kept if that fails
+	credit := repo.Transfer{ID: uuid.New().String(), Kind: repo.EntryCredit, From: repo.IssuedAccount}
+	if err = r.transfer(ctx, receiptData, credit); err != nil {
+		if deleteErr := r.deleteStored(ctx, id); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to remove uncredited receipt with id %s: %w", id, deleteErr))
+		}
+		return repo.ReceiptData{}, err
+	}
+	if r.observer != nil {
+		r.observer.ReceiptProcessed(breakdown)
+	}
+	logging.FromContext(ctx).Info("receipt processed", "receipt_id", id, "points", receiptData.Point,
+		"rule_version", receiptData.RuleVersion, "campaigns", len(applied), "retailer_id", internalReceipt.RetailerID)
+	r.publish(models.EventReceiptProcessed, receiptData, nil)
+	return receiptData, nil
+}
+
+// publish sends an event about a receipt to the event publisher, if there is one
+func (r *receiptServiceImpl) publish(eventType string, receiptData repo.ReceiptData, previousPoints *int64) {
+	if r.events == nil {
+		return
+	}
+	r.events.Publish(models.ReceiptEvent{
+		ID:             uuid.New().String(),
+		Type:           eventType,
+		CreatedAt:      r.now().UTC(),
+		ReceiptID:      receiptData.Receipt.ID,
+		AccountID:      receiptData.Receipt.AccountID,
+		Points:         receiptData.Point,
+		RuleVersion:    receiptData.RuleVersion,
+		PreviousPoints: previousPoints,
+	})
+}
+
+// Records a transfer of the receipt's points to or from the account of the receipt, if it has one.
+// From or To is set to the account, receipts awarded no points transfer nothing.
+func (r *receiptServiceImpl) transfer(ctx context.Context, receiptData repo.ReceiptData, t repo.Transfer) error {
+	accountID := receiptData.Receipt.AccountID
+	if r.ledger == nil || accountID == "" || receiptData.Point == 0 {
+		return nil
+	}
+	if t.From == "" {
+		t.From = accountID
+	} else {
+		t.To = accountID
+	}
+	t.Points = receiptData.Point
+	t.ReceiptID = receiptData.Receipt.ID
+	t.CreatedAt = r.now()
+	_, span := r.startStoreSpan(ctx, "Transfer", attrReceiptID.String(t.ReceiptID), attribute.String("transfer.kind", string(t.Kind)))
+	_, err := r.ledger.Transfer(t)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to record %s of receipt with id %s for account %s: %w", t.Kind, t.ReceiptID, accountID, err)
+	}
+	return nil
 }
 
 // Get points for a given receipt ID
-func (r *receiptServiceImpl) GetPoints(id string) (int64, error) {
-	receiptData, err := repo.GetReceiptData(id)
+func (r *receiptServiceImpl) GetPoints(ctx context.Context, id string) (_ int64, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.GetPoints", attrReceiptID.String(id))
+	defer func() { endSpan(span, err) }()
+
+	receiptData, err := r.getReceiptData(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return receiptData.Point, nil
+}
+
+// Retrieves stored receipt data, wrapping errors with the receipt ID.
+// Receipts owned by another client than the one of ctx are reported as not found.
+func (r *receiptServiceImpl) getReceiptData(ctx context.Context, id string) (repo.ReceiptData, error) {
+	_, span := r.startStoreSpan(ctx, "Get", attrReceiptID.String(id))
+	receiptData, err := r.store.Get(id)
+	endSpan(span, err)
+	if err == nil && !visible(ctx, receiptData.Owner) {
+		err = repo.ErrNotFound
+	}
 	if err != nil {
 		// Handle the specific error (e.g., receipt not found)
-		if err == repo.ErrNotFound {
-			return 0, fmt.Errorf("receipt with id %s does not exist: %w", id, err)
+		if errors.Is(err, repo.ErrNotFound) {
+			return repo.ReceiptData{}, fmt.Errorf("receipt with id %s does not exist: %w", id, err)
 		}
 		// Handle other potential errors (if any)
-		return 0, fmt.Errorf("failed to retrieve receipt with id %s: %w", id, err)
+		return repo.ReceiptData{}, fmt.Errorf("failed to retrieve receipt with id %s: %w", id, err)
 	}
+	return receiptData, nil
+}
 
-	return receiptData.Point, nil
+// Explains the points awarded to a given receipt ID rule by rule
+func (r *receiptServiceImpl) GetPointsBreakdown(ctx context.Context, id string) (_ models.PointsBreakdown, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.GetPointsBreakdown", attrReceiptID.String(id))
+	defer func() { endSpan(span, err) }()
+
+	receiptData, err := r.getReceiptData(ctx, id)
+	if err != nil {
+		return models.PointsBreakdown{}, err
+	}
+	// Explain the points with the rule set that awarded them, if it is still known
+	ruleSet, ok := r.ruleSets[receiptData.RuleVersion]
+	if !ok {
+		ruleSet = r.rules
+	}
+	breakdown := explainCampaigns(ruleSet.Score(receiptData.Receipt), receiptData.Campaigns)
+	breakdown.Recomputed = !ok
+	return breakdown, nil
 }
 
-// Calculates points for a given receipt
-func calculatePoints(receipt models.Receipt) int64 {
-	var points int64
+// Retrieves the stored receipt and its points for a given receipt ID
+func (r *receiptServiceImpl) GetReceipt(ctx context.Context, id string) (_ repo.ReceiptData, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.GetReceipt", attrReceiptID.String(id))
+	defer func() { endSpan(span, err) }()
 
-	// Rule 1: One point for every alphanumeric character in the retailer name.
-	retailerRegex := regexp.MustCompile(`[a-zA-Z0-9]`)
-	points += int64(len(retailerRegex.FindAllString(receipt.Retailer, -1)))
+	return r.getReceiptData(ctx, id)
+}
 
-	// Rule 2: 50 points if the total is a round dollar amount with no cents.
-	total, err := strconv.ParseFloat(receipt.Total, 64)
-	if err != nil {
-		return points
+// Lists one page of stored receipts matching the filters, among the receipts visible to the client of ctx
+func (r *receiptServiceImpl) ListReceipts(ctx context.Context, q ListQuery) (_ ReceiptPage, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.ListReceipts")
+	defer func() { endSpan(span, err) }()
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
 	}
-	if total == float64(int(total)) {
-		points += 50
+	filter := q.Filter
+	if owner := visibleOwner(ctx); owner != "" {
+		filter.Owner = owner
 	}
+	if q.Cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil || len(after) == 0 {
+			return ReceiptPage{}, ErrInvalidCursor
+		}
+		filter.After = string(after)
+	}
+	// Read one receipt more than requested to know whether another page follows
+	filter.Limit = q.Limit + 1
 
-	// Rule 3: 25 points if the total is a multiple of 0.25.
-	if math.Mod(total, 0.25) == 0 {
-		points += 25
+	_, storeSpan := r.startStoreSpan(ctx, "List", attribute.Int("list.limit", filter.Limit))
+	list, err := r.store.List(filter)
+	endSpan(storeSpan, err)
+	if err != nil {
+		return ReceiptPage{}, fmt.Errorf("failed to list receipts: %w", err)
 	}
+	page := ReceiptPage{Receipts: list}
+	if len(list) > q.Limit {
+		page.Receipts = list[:q.Limit]
+		lastID := page.Receipts[len(page.Receipts)-1].Receipt.ID
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(lastID))
+	}
+	return page, nil
+}
 
-	// Rule 4: 5 points for every two items on the receipt.
-	points += int64(len(receipt.Items) / 2 * 5)
+// Deletes a stored receipt, after which its contents may be submitted again.
+// Points credited to an account for the receipt are reversed first, which fails
+// with repo.ErrInsufficientFunds if the account has already spent them.
+func (r *receiptServiceImpl) DeleteReceipt(ctx context.Context, id string) (err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.DeleteReceipt", attrReceiptID.String(id))
+	defer func() { endSpan(span, err) }()
 
-	// Rule 5: If the trimmed length of the item description is a multiple of 3, multiply the price by 0.2 and round up to the nearest integer.
-	for _, item := range receipt.Items {
-		trimmedDescription := strings.TrimSpace(item.ShortDescription)
-		if len(trimmedDescription)%3 == 0 {
-			price, err := strconv.ParseFloat(item.Price, 64)
-			if err == nil {
-				points += int64(math.Ceil(price * 0.2))
-			}
-		}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	receiptData, err := r.getReceiptData(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	// Rule 6: 6 points if the day in the purchase date is odd.
-	day, err := strconv.Atoi(strings.Split(receipt.PurchaseDate, "-")[2])
-	if err == nil && day%2 != 0 {
-		points += 6
+	// Every attempt gets its own reversal ID, since a failed attempt gives the points back under another ID
+	// and a retry must be able to reverse them again. Deletes hold the lock, so the receipt is reversed once.
+	reversal := repo.Transfer{ID: id + ":reversal:" + uuid.New().String(), Kind: repo.EntryReversal, To: repo.IssuedAccount}
+	if err := r.transfer(ctx, receiptData, reversal); err != nil {
+		return err
 	}
 
-	// Rule 7: 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-	hour, err := strconv.Atoi(strings.Split(receipt.PurchaseTime, ":")[0])
-	if err == nil && hour >= 14 && hour < 16 {
-		points += 10
+	if err := r.deleteStored(ctx, id); err != nil {
+		// A store may fail after the delete took effect, so the points are only given back if the receipt is kept
+		_, getErr := r.getReceiptData(ctx, id)
+		switch {
+		case getErr == nil:
+			credit := repo.Transfer{ID: uuid.New().String(), Kind: repo.EntryCredit, From: repo.IssuedAccount}
+			if creditErr := r.transfer(ctx, receiptData, credit); creditErr != nil {
+				err = errors.Join(err, creditErr)
+			}
+			return fmt.Errorf("failed to delete receipt with id %s: %w", id, err)
+		case !errors.Is(getErr, repo.ErrNotFound):
+			return fmt.Errorf("failed to delete receipt with id %s: %w", id, errors.Join(err, getErr))
+		}
+		logging.FromContext(ctx).Warn("receipt deleted despite store error", "receipt_id", id, "error", err)
 	}
+	r.fingerprints.release(fingerprintKey(receiptData.Owner, receiptData.Receipt), id)
+	logging.FromContext(ctx).Info("receipt deleted", "receipt_id", id, "points", receiptData.Point)
+	r.publish(models.EventReceiptDeleted, receiptData, nil)
+	return nil
+}
+
+// putStored stores a receipt, replacing the one with the same ID
+func (r *receiptServiceImpl) putStored(ctx context.Context, id string, receiptData repo.ReceiptData) error {
+	_, span := r.startStoreSpan(ctx, "Put", attrReceiptID.String(id))
+	err := r.store.Put(id, receiptData)
+	endSpan(span, err)
+	return err
+}
 
-	return points
+// deleteStored removes a receipt from the store
+func (r *receiptServiceImpl) deleteStored(ctx context.Context, id string) error {
+	_, span := r.startStoreSpan(ctx, "Delete", attrReceiptID.String(id))
+	err := r.store.Delete(id)
+	endSpan(span, err)
+	return err
 }
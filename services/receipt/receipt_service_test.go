@@ -1,34 +1,49 @@
 package receipt
 
 import (
+	"context"
+	"errors"
 	"receipt-processor/models"
 	"receipt-processor/repo"
+	"receipt-processor/services/rules"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
 
+// ctx is the context of the calls to the service under test
+var ctx = context.Background()
+
 // ReceiptServiceTestSuite defines the suite for service tests
 type ReceiptServiceTestSuite struct {
 	suite.Suite
 	service        ReceiptService
+	store          repo.ReceiptStore
 	mockExtReceipt models.ExtReceipt
 }
 
 // SetupTest initializes the suite
 func (suite *ReceiptServiceTestSuite) SetupTest() {
-	// Reset the storage before each test
-	repo.Receipts = make(map[string]repo.ReceiptData)
+	// Use a fresh storage for each test
+	suite.store = repo.NewMemoryStore()
 
 	// Initialize the ReceiptService
-	suite.service = NewReceiptService()
+	suite.service = NewReceiptService(suite.store)
 
 	// Define a mock external receipt (ExtReceipt)
-	suite.mockExtReceipt = models.ExtReceipt{
+	suite.mockExtReceipt = newMockExtReceipt()
+}
+
+// newMockExtReceipt returns a receipt worth 28 points under the default rules
+func newMockExtReceipt() models.ExtReceipt {
+	return models.ExtReceipt{
 		Retailer:     "Target",
 		PurchaseDate: "2022-01-01",
 		PurchaseTime: "13:01",
-		Items: []models.Item{
+		Items: []models.ExtItem{
 			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
 			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
 			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
@@ -41,25 +56,42 @@ func (suite *ReceiptServiceTestSuite) SetupTest() {
 
 func (suite *ReceiptServiceTestSuite) TestProcessReceipt() {
 	// Process the mock receipt
-	id, err := suite.service.ProcessReceipt(suite.mockExtReceipt)
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
 
 	// Assertions for receipt processing
 	suite.NoError(err)
 	suite.NotEmpty(id)
 
 	// Check if receipt exists in storage
-	receiptData, err := repo.GetReceiptData(id)
+	receiptData, err := suite.store.Get(id)
 	suite.NoError(err) // Ensure no error is returned
 	suite.Equal(suite.mockExtReceipt.Retailer, receiptData.Receipt.Retailer)
-	suite.Equal(suite.mockExtReceipt.Total, receiptData.Receipt.Total)
+	suite.Equal(suite.mockExtReceipt.Total, receiptData.Receipt.Total.String())
+}
+
+func (suite *ReceiptServiceTestSuite) TestProcessReceiptRejectsMalformedAmounts() {
+	malformedTotal := suite.mockExtReceipt
+	malformedTotal.Total = "35.3"
+	_, err := suite.service.ProcessReceipt(ctx, malformedTotal)
+	suite.ErrorIs(err, models.ErrInvalidAmount)
+
+	malformedPrice := suite.mockExtReceipt
+	malformedPrice.Items = append([]models.ExtItem{{ShortDescription: "Gatorade", Price: "abc"}}, suite.mockExtReceipt.Items...)
+	_, err = suite.service.ProcessReceipt(ctx, malformedPrice)
+	suite.ErrorIs(err, models.ErrInvalidAmount)
+
+	// Nothing is stored for a rejected receipt
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(0, count)
 }
 
 func (suite *ReceiptServiceTestSuite) TestGetPoints() {
 	// Process the mock receipt and get its ID
-	id, _ := suite.service.ProcessReceipt(suite.mockExtReceipt)
+	id, _ := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
 
 	// Get points for the processed receipt
-	points, err := suite.service.GetPoints(id)
+	points, err := suite.service.GetPoints(ctx, id)
 
 	// Assertions
 	suite.NoError(err)
@@ -67,10 +99,538 @@ func (suite *ReceiptServiceTestSuite) TestGetPoints() {
 	suite.Equal(points, int64(28), "Points of this mock receipt should be 28")
 
 	// Verify that points were updated in storage
-	receiptData, _ := repo.GetReceiptData(id)
+	receiptData, _ := suite.store.Get(id)
 	suite.Equal(points, receiptData.Point)
 }
 
+func (suite *ReceiptServiceTestSuite) TestGetPointsBreakdown() {
+	id, _ := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+
+	breakdown, err := suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.Equal(int64(28), breakdown.Total)
+	suite.Len(breakdown.Rules, 7)
+
+	// The rule points add up to the stored points
+	var sum int64
+	matched := map[string]int64{}
+	for _, rule := range breakdown.Rules {
+		sum += rule.Points
+		if rule.Matched {
+			matched[rule.Rule] = rule.Points
+		}
+	}
+	suite.Equal(breakdown.Total, sum)
+	suite.Equal(map[string]int64{
+		"retailer_alphanumeric":   6,
+		"item_pairs":              10,
+		"item_description_length": 6,
+		"odd_purchase_day":        6,
+	}, matched)
+}
+
+func (suite *ReceiptServiceTestSuite) TestGetPointsBreakdownUnknownRuleVersion() {
+	id, _ := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+
+	breakdown, err := suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.False(breakdown.Recomputed)
+
+	// Receipts scored by a rule set that is no longer configured are explained with the
+	// current rules and flagged as such
+	receiptData, err := suite.store.Get(id)
+	suite.Require().NoError(err)
+	receiptData.RuleVersion = "retired"
+	suite.Require().NoError(suite.store.Put(id, receiptData))
+
+	breakdown, err = suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.True(breakdown.Recomputed)
+	suite.Equal(int64(28), breakdown.Total)
+}
+
+func (suite *ReceiptServiceTestSuite) TestGetPointsBreakdownNotFound() {
+	_, err := suite.service.GetPointsBreakdown(ctx, "missing-id")
+	suite.ErrorIs(err, repo.ErrNotFound)
+}
+
+func (suite *ReceiptServiceTestSuite) TestScoreReceipt() {
+	result, err := suite.service.ScoreReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+	suite.Equal(int64(28), result.Breakdown.Total)
+	suite.Len(result.Breakdown.Rules, 7)
+	suite.Equal("1", result.RuleVersion)
+
+	// Nothing is stored
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(0, count)
+
+	malformed := suite.mockExtReceipt
+	malformed.Total = "35.3"
+	_, err = suite.service.ScoreReceipt(ctx, malformed)
+	suite.ErrorIs(err, models.ErrInvalidAmount)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDuplicateDetection() {
+	// Whitespace around names does not make a receipt different
+	resubmitted := suite.mockExtReceipt
+	resubmitted.Retailer = " Target "
+
+	// By default duplicates are stored as new receipts
+	first, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	second, err := suite.service.ProcessReceipt(ctx, resubmitted)
+	suite.Require().NoError(err)
+	suite.NotEqual(first, second)
+
+	// Rejecting duplicates reports one of the stored receipts
+	rejecting := NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReject))
+	_, err = rejecting.ProcessReceipt(ctx, resubmitted)
+	suite.ErrorIs(err, ErrDuplicateReceipt)
+	var duplicate *DuplicateReceiptError
+	suite.Require().ErrorAs(err, &duplicate)
+	suite.Contains([]string{first, second}, duplicate.ExistingID)
+
+	// Returning the existing ID does not store anything
+	returning := NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReturnExisting))
+	id, err := returning.ProcessReceipt(ctx, resubmitted)
+	suite.NoError(err)
+	suite.Equal(duplicate.ExistingID, id)
+	count, _ := suite.store.Count()
+	suite.Equal(2, count)
+
+	// A different receipt is not a duplicate
+	different := suite.mockExtReceipt
+	different.PurchaseTime = "13:02"
+	id, err = returning.ProcessReceipt(ctx, different)
+	suite.NoError(err)
+	suite.NotContains([]string{first, second}, id)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDuplicateReturnsStoredPoints() {
+	first, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	// The rules change before the receipt is submitted again
+	promo, err := rules.New(rules.Config{Version: "promo", Rules: []rules.RuleConfig{
+		{Kind: "retailer_alphanumeric", Params: map[string]any{"pointsPerCharacter": 10}},
+	}})
+	suite.Require().NoError(err)
+	returning := NewReceiptService(suite.store, WithRuleSet(promo), WithDuplicateDetection(DuplicateReturnExisting))
+
+	results := returning.ProcessReceipts(ctx, []models.ExtReceipt{suite.mockExtReceipt})
+	suite.Require().NoError(results[0].Err)
+	suite.Equal(first, results[0].ID)
+	suite.Equal(int64(28), results[0].Points)
+	points, err := returning.GetPoints(ctx, first)
+	suite.Require().NoError(err)
+	suite.Equal(int64(28), points)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDuplicateOfPendingReceipt() {
+	matcher := blockingMatcher{started: make(chan string, 10), release: make(chan struct{})}
+	service := NewReceiptService(suite.store, WithAsyncProcessing(1, 1), WithRetailerMatcher(matcher),
+		WithDuplicateDetection(DuplicateReturnExisting)).(*receiptServiceImpl)
+	job, err := service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	<-matcher.started
+
+	// A duplicate gives up waiting for the receipt to be stored with a retryable error
+	service.duplicateWait = time.Millisecond
+	_, err = service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.ErrorIs(err, ErrDuplicatePending)
+	var duplicate *DuplicateReceiptError
+	suite.Require().ErrorAs(err, &duplicate)
+	suite.Equal(job.ID, duplicate.ExistingID)
+
+	// or returns it once it is stored
+	service.duplicateWait = 5 * time.Second
+	ids := make(chan string)
+	go func() {
+		id, err := service.ProcessReceipt(ctx, suite.mockExtReceipt)
+		suite.NoError(err)
+		ids <- id
+	}()
+	close(matcher.release)
+	suite.Equal(job.ID, <-ids)
+	suite.Require().NoError(service.Drain(context.Background()))
+	count, _ := suite.store.Count()
+	suite.Equal(1, count)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDuplicateDetectionConcurrent() {
+	service := NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReturnExisting))
+
+	ids := make(chan string, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := service.ProcessReceipt(ctx, suite.mockExtReceipt)
+			suite.NoError(err)
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	// Every concurrent submission gets the same ID and only one receipt is stored
+	first := <-ids
+	for id := range ids {
+		suite.Equal(first, id)
+	}
+	count, _ := suite.store.Count()
+	suite.Equal(1, count)
+}
+
+func (suite *ReceiptServiceTestSuite) TestProcessReceipts() {
+	invalid := suite.mockExtReceipt
+	invalid.PurchaseDate = "2022-02-30"
+	malformed := suite.mockExtReceipt
+	malformed.Total = "abc"
+
+	results := suite.service.ProcessReceipts(ctx, []models.ExtReceipt{suite.mockExtReceipt, invalid, malformed})
+
+	suite.Require().Len(results, 3)
+	suite.NoError(results[0].Err)
+	suite.Equal(int64(28), results[0].Points)
+	stored, err := suite.store.Get(results[0].ID)
+	suite.NoError(err)
+	suite.Equal(int64(28), stored.Point)
+
+	var validationErrs models.ValidationErrors
+	suite.ErrorAs(results[1].Err, &validationErrs)
+	suite.Equal("purchaseDate", validationErrs[0].Field)
+	suite.Error(results[2].Err)
+
+	count, err := suite.store.Count()
+	suite.NoError(err)
+	suite.Equal(1, count)
+}
+
+func (suite *ReceiptServiceTestSuite) TestGetReceipt() {
+	id, _ := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+
+	receiptData, err := suite.service.GetReceipt(ctx, id)
+	suite.NoError(err)
+	suite.Equal(id, receiptData.Receipt.ID)
+	suite.Equal(int64(28), receiptData.Point)
+
+	_, err = suite.service.GetReceipt(ctx, "missing-id")
+	suite.ErrorIs(err, repo.ErrNotFound)
+}
+
+func (suite *ReceiptServiceTestSuite) TestListReceipts() {
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+		suite.Require().NoError(err)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Follow the cursors through every page
+	var listed []string
+	q := ListQuery{Limit: 2}
+	for pages := 0; ; pages++ {
+		suite.Require().Less(pages, 3)
+		page, err := suite.service.ListReceipts(ctx, q)
+		suite.Require().NoError(err)
+		for _, data := range page.Receipts {
+			listed = append(listed, data.Receipt.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	suite.Equal(ids, listed)
+
+	_, err := suite.service.ListReceipts(ctx, ListQuery{Cursor: "not a cursor"})
+	suite.ErrorIs(err, ErrInvalidCursor)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDeleteReceipt() {
+	suite.service = NewReceiptService(suite.store, WithDuplicateDetection(DuplicateReject))
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.service.DeleteReceipt(ctx, id))
+	_, err = suite.store.Get(id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	suite.ErrorIs(suite.service.DeleteReceipt(ctx, id), repo.ErrNotFound)
+
+	// The deleted receipt no longer counts as a duplicate
+	_, err = suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+}
+
+func (suite *ReceiptServiceTestSuite) TestAccountLedger() {
+	store := repo.NewMemoryStore()
+	suite.store = store
+	suite.service = NewReceiptService(store, WithLedger(store))
+	suite.mockExtReceipt.AccountID = "alice"
+
+	first, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	second, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	// Receipts without an account are not credited to anyone
+	suite.mockExtReceipt.AccountID = ""
+	_, err = suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	balance, err := store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(56), balance)
+
+	// Deleting a receipt reverses its credit
+	suite.Require().NoError(suite.service.DeleteReceipt(ctx, first))
+	entries, err := store.Entries("alice")
+	suite.NoError(err)
+	suite.Require().Len(entries, 3)
+	suite.Equal(repo.EntryCredit, entries[1].Kind)
+	suite.Equal(second, entries[1].ReceiptID)
+	suite.Equal(int64(56), entries[1].Balance)
+	suite.Equal(repo.EntryReversal, entries[2].Kind)
+	suite.Equal(first, entries[2].ReceiptID)
+	suite.Equal(int64(-28), entries[2].Points)
+	suite.Equal(int64(28), entries[2].Balance)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDeleteReceiptAfterRedemption() {
+	store := repo.NewMemoryStore()
+	suite.store = store
+	suite.service = NewReceiptService(store, WithLedger(store))
+	suite.mockExtReceipt.AccountID = "alice"
+
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	_, err = store.Transfer(repo.Transfer{ID: "redeem", Kind: repo.EntryRedemption, From: "alice", To: repo.RedeemedAccount, Points: 10})
+	suite.Require().NoError(err)
+
+	// The points were spent, so they cannot be reversed and the receipt is kept
+	suite.ErrorIs(suite.service.DeleteReceipt(ctx, id), repo.ErrInsufficientFunds)
+	_, err = store.Get(id)
+	suite.NoError(err)
+	balance, err := store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(18), balance)
+}
+
+// failingDeleteStore fails the given number of deletes before deleting receipts again.
+// With deleteOnFailure the failing deletes take effect nonetheless.
+type failingDeleteStore struct {
+	*repo.MemoryStore
+	failures        int
+	deleteOnFailure bool
+}
+
+func (s *failingDeleteStore) Delete(id string) error {
+	if s.failures > 0 {
+		s.failures--
+		if s.deleteOnFailure {
+			s.MemoryStore.Delete(id)
+		}
+		return errors.New("store unavailable")
+	}
+	return s.MemoryStore.Delete(id)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDeleteReceiptRetry() {
+	store := &failingDeleteStore{MemoryStore: repo.NewMemoryStore(), failures: 1}
+	suite.store = store
+	suite.service = NewReceiptService(store, WithLedger(store))
+	suite.mockExtReceipt.AccountID = "alice"
+
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	// The failed delete keeps the receipt and gives its points back
+	err = suite.service.DeleteReceipt(ctx, id)
+	suite.Error(err)
+	suite.NotErrorIs(err, repo.ErrNotFound)
+	_, err = store.Get(id)
+	suite.NoError(err)
+	balance, err := store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(28), balance)
+
+	// Retrying reverses the points again and deletes the receipt
+	suite.Require().NoError(suite.service.DeleteReceipt(ctx, id))
+	_, err = store.Get(id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	balance, err = store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(0), balance)
+	suite.ErrorIs(suite.service.DeleteReceipt(ctx, id), repo.ErrNotFound)
+}
+
+func (suite *ReceiptServiceTestSuite) TestDeleteReceiptCommittedDespiteError() {
+	store := &failingDeleteStore{MemoryStore: repo.NewMemoryStore(), failures: 1, deleteOnFailure: true}
+	suite.store = store
+	suite.service = NewReceiptService(store, WithLedger(store), WithDuplicateDetection(DuplicateReject))
+	suite.mockExtReceipt.AccountID = "alice"
+
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	// The receipt is gone, so the delete succeeded and its points stay reversed
+	suite.Require().NoError(suite.service.DeleteReceipt(ctx, id))
+	_, err = store.Get(id)
+	suite.ErrorIs(err, repo.ErrNotFound)
+	balance, err := store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(0), balance)
+
+	// Its fingerprint was released
+	_, err = suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+}
+
+func (suite *ReceiptServiceTestSuite) TestCampaigns() {
+	store := repo.NewMemoryStore()
+	suite.store = store
+	suite.service = NewReceiptService(store, WithLedger(store), WithCampaigns(store))
+	suite.mockExtReceipt.AccountID = "alice"
+	campaigns := []models.Campaign{
+		{ID: "double", Name: "Double points at Target", StartDate: "2022-01-01", EndDate: "2022-01-02", Retailer: "target", Multiplier: 2},
+		{ID: "pizza", Name: "Pizza bonus", StartDate: "2021-12-01", EndDate: "2022-01-31", ItemContains: "PIZZA", Bonus: 100},
+		{ID: "ended", Name: "Last year", StartDate: "2021-01-01", EndDate: "2021-12-31", Bonus: 1000},
+		{ID: "walgreens", Name: "Walgreens weekend", StartDate: "2022-01-01", EndDate: "2022-01-02", Retailer: "Walgreens", Bonus: 1000},
+	}
+	for _, campaign := range campaigns {
+		suite.Require().NoError(store.PutCampaign(campaign))
+	}
+
+	// The base 28 points are doubled and the bonus is added
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	receiptData, err := store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(156), receiptData.Point)
+	suite.Equal([]models.AppliedCampaign{
+		{ID: "pizza", Name: "Pizza bonus", Bonus: 100, Points: 100},
+		{ID: "double", Name: "Double points at Target", Multiplier: 2, Points: 28},
+	}, receiptData.Campaigns)
+	balance, err := store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(156), balance)
+
+	// The breakdown explains the campaigns after the base rules and adds up to the points
+	breakdown, err := suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.Equal(int64(156), breakdown.Total)
+	suite.Require().Len(breakdown.Rules, 9)
+	suite.Equal("campaign", breakdown.Rules[8].Rule)
+	suite.Equal("double", breakdown.Rules[8].Inputs["campaignId"])
+
+	// Deleting a campaign keeps the points it awarded
+	suite.Require().NoError(store.DeleteCampaign("double"))
+	breakdown, err = suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.Equal(int64(156), breakdown.Total)
+
+	// Previews include the running campaigns
+	result, err := suite.service.ScoreReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+	suite.Equal(int64(128), result.Breakdown.Total)
+	suite.Len(result.Campaigns, 1)
+}
+
+// retailerMatcher matches names to retailer IDs exactly
+type retailerMatcher map[string]string
+
+func (m retailerMatcher) Match(name string) (string, error) {
+	return m[name], nil
+}
+
+func (suite *ReceiptServiceTestSuite) TestRetailerMatcher() {
+	suite.service = NewReceiptService(suite.store, WithRetailerMatcher(retailerMatcher{"Target": "target"}))
+
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	receiptData, err := suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal("target", receiptData.Receipt.RetailerID)
+
+	suite.mockExtReceipt.Retailer = "Walgreens"
+	id, err = suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	receiptData, err = suite.store.Get(id)
+	suite.NoError(err)
+	suite.Empty(receiptData.Receipt.RetailerID)
+}
+
+func (suite *ReceiptServiceTestSuite) TestCampaignOfRetailerAlias() {
+	store := repo.NewMemoryStore()
+	suite.store = store
+	suite.service = NewReceiptService(store, WithCampaigns(store),
+		WithRetailerMatcher(retailerMatcher{"Target": "target", "TGT": "target", "Walgreens": "walgreens"}))
+	suite.Require().NoError(store.PutCampaign(models.Campaign{
+		ID: "double", Name: "Double points at Target", StartDate: "2022-01-01", EndDate: "2022-01-02", Retailer: "Target", Multiplier: 2,
+	}))
+
+	// The receipt names Target by an alias, its base 25 points are doubled
+	suite.mockExtReceipt.Retailer = "TGT"
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	receiptData, err := store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(50), receiptData.Point)
+	suite.Equal([]models.AppliedCampaign{{ID: "double", Name: "Double points at Target", Multiplier: 2, Points: 25}}, receiptData.Campaigns)
+
+	// Previews match the alias too
+	result, err := suite.service.ScoreReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+	suite.Equal(int64(50), result.Breakdown.Total)
+
+	// Receipts of other retailers do not qualify
+	suite.mockExtReceipt.Retailer = "Walgreens"
+	result, err = suite.service.ScoreReceipt(ctx, suite.mockExtReceipt)
+	suite.NoError(err)
+	suite.Empty(result.Campaigns)
+}
+
+// breakdownRecorder keeps the breakdowns of processed receipts
+type breakdownRecorder struct {
+	breakdowns []models.PointsBreakdown
+}
+
+func (b *breakdownRecorder) ReceiptProcessed(breakdown models.PointsBreakdown) {
+	b.breakdowns = append(b.breakdowns, breakdown)
+}
+
+func (suite *ReceiptServiceTestSuite) TestObserver() {
+	recorder := &breakdownRecorder{}
+	suite.service = NewReceiptService(suite.store, WithObserver(recorder))
+
+	_, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	// Previews are not processed receipts
+	_, err = suite.service.ScoreReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(recorder.breakdowns, 1)
+	suite.Equal(int64(28), recorder.breakdowns[0].Total)
+	suite.NotEmpty(recorder.breakdowns[0].Rules)
+}
+
+func TestParseDuplicateMode(t *testing.T) {
+	for _, s := range []string{"allow", "reject", "return-existing"} {
+		mode, err := ParseDuplicateMode(s)
+		if err != nil || string(mode) != s {
+			t.Errorf("ParseDuplicateMode(%q) = %q, %v", s, mode, err)
+		}
+	}
+	if _, err := ParseDuplicateMode("maybe"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
 // Run the test suite
 func TestReceiptServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(ReceiptServiceTestSuite))
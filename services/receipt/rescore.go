@@ -0,0 +1,185 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"receipt-processor/logging"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"receipt-processor/services/rules"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrUnknownRuleVersion is returned when rescoring with a rule set version the service does not know
+var ErrUnknownRuleVersion = errors.New("unknown rule set version")
+
+// rescorePageSize is how many receipts are read from the store at a time while rescoring
+const rescorePageSize = 100
+
+// WithRuleHistory makes older or alternative rule sets available for rescoring, by version
+func WithRuleHistory(sets ...*rules.RuleSet) Option {
+	return func(r *receiptServiceImpl) {
+		for _, rs := range sets {
+			r.ruleSets[rs.Version] = rs
+		}
+	}
+}
+
+// RescoreQuery selects the receipts to rescore and the rule set version to score them with
+type RescoreQuery struct {
+	// Version of the rule set, the current rule set if empty
+	Version string
+	// Filter selects the receipts, After and Limit are ignored
+	Filter repo.ListQuery
+	// Apply stores the new points, otherwise the changes are only reported
+	Apply bool
+}
+
+// Outcome of rescoring one receipt
+type RescoreResult struct {
+	ReceiptID  string
+	OldVersion string
+	OldPoints  int64
+	NewPoints  int64
+	Delta      int64
+	// Err is set if the new points could not be applied
+	Err error
+}
+
+// Report of a rescoring run with one result per selected receipt
+type RescoreReport struct {
+	Version    string
+	Applied    bool
+	Results    []RescoreResult
+	Changed    int
+	Failed     int
+	TotalDelta int64
+}
+
+// Scores the selected receipts visible to the client of ctx with a rule set version and reports the change of points of each.
+// When applied, receipts are updated and the points difference is credited to or debited from
+// their account; a receipt whose account has already spent the points it would lose is left unchanged.
+func (r *receiptServiceImpl) Rescore(ctx context.Context, q RescoreQuery) (_ RescoreReport, err error) {
+	ctx, span := r.startSpan(ctx, "ReceiptService.Rescore", attribute.Bool("rescore.apply", q.Apply))
+	defer func() { endSpan(span, err) }()
+
+	ruleSet := r.rules
+	if q.Version != "" {
+		var ok bool
+		if ruleSet, ok = r.ruleSets[q.Version]; !ok {
+			return RescoreReport{}, fmt.Errorf("rule set version %q: %w", q.Version, ErrUnknownRuleVersion)
+		}
+	}
+
+	// Keep deletes from racing with receipts being rewritten
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span.SetAttributes(attrRuleVersion.String(ruleSet.Version), attrRuleCount.Int(len(ruleSet.Rules)))
+	logger := logging.FromContext(ctx)
+	report := RescoreReport{Version: ruleSet.Version, Applied: q.Apply}
+	filter := q.Filter
+	if owner := visibleOwner(ctx); owner != "" {
+		filter.Owner = owner
+	}
+	filter.After = ""
+	filter.Limit = rescorePageSize
+	for {
+		_, storeSpan := r.startStoreSpan(ctx, "List", attribute.Int("list.limit", filter.Limit))
+		page, err := r.store.List(filter)
+		endSpan(storeSpan, err)
+		if err != nil {
+			return RescoreReport{}, fmt.Errorf("failed to list receipts: %w", err)
+		}
+		for _, receiptData := range page {
+			rescored := rescoreData(receiptData, ruleSet)
+			result := RescoreResult{
+				ReceiptID:  receiptData.Receipt.ID,
+				OldVersion: receiptData.RuleVersion,
+				OldPoints:  receiptData.Point,
+				NewPoints:  rescored.Point,
+			}
+			result.Delta = result.NewPoints - result.OldPoints
+			if q.Apply && (result.Delta != 0 || result.OldVersion != ruleSet.Version) {
+				if result.Err = r.applyRescore(ctx, receiptData, rescored); result.Err == nil {
+					r.publish(models.EventReceiptRescored, rescored, &result.OldPoints)
+				} else {
+					logger.Warn("failed to rescore receipt", "receipt_id", result.ReceiptID, "error", result.Err)
+				}
+			}
+
+			if result.Err != nil {
+				report.Failed++
+			} else if result.Delta != 0 {
+				report.Changed++
+				report.TotalDelta += result.Delta
+			}
+			report.Results = append(report.Results, result)
+		}
+		if len(page) < rescorePageSize {
+			logger.Info("receipts rescored", "rule_version", report.Version, "applied", report.Applied,
+				"receipts", len(report.Results), "changed", report.Changed, "failed", report.Failed, "total_delta", report.TotalDelta)
+			return report, nil
+		}
+		filter.After = page[len(page)-1].Receipt.ID
+	}
+}
+
+// rescoreData scores a stored receipt with a rule set. The campaigns applied to the receipt
+// keep their terms and are applied again to the new base points.
+func rescoreData(receiptData repo.ReceiptData, ruleSet *rules.RuleSet) repo.ReceiptData {
+	rescored := receiptData
+	rescored.RuleVersion = ruleSet.Version
+	rescored.Point = ruleSet.Score(receiptData.Receipt).Total
+	if len(receiptData.Campaigns) > 0 {
+		base := rescored.Point
+		rescored.Campaigns = make([]models.AppliedCampaign, len(receiptData.Campaigns))
+		for i, applied := range receiptData.Campaigns {
+			applied.Points = applied.Rescale(base)
+			rescored.Campaigns[i] = applied
+			rescored.Point += applied.Points
+		}
+	}
+	return rescored
+}
+
+// applyRescore stores the new points of a receipt and moves the difference to or from its account.
+// Only the score of the receipt is written, so that a retailer assigned since it was listed is kept.
+func (r *receiptServiceImpl) applyRescore(ctx context.Context, receiptData, rescored repo.ReceiptData) error {
+	id := receiptData.Receipt.ID
+	if err := r.assignScore(ctx, id, rescored); err != nil {
+		return fmt.Errorf("failed to store receipt with id %s: %w", id, err)
+	}
+
+	delta := rescored.Point - receiptData.Point
+	if delta == 0 {
+		return nil
+	}
+	adjustment := repo.Transfer{ID: uuid.New().String(), Kind: repo.EntryRescore}
+	if delta > 0 {
+		adjustment.From = repo.IssuedAccount
+	} else {
+		adjustment.To = repo.IssuedAccount
+		delta = -delta
+	}
+	err := r.transfer(ctx, repo.ReceiptData{Receipt: receiptData.Receipt, Point: delta}, adjustment)
+	if err != nil {
+		// Keep the receipt consistent with its account
+		if restoreErr := r.assignScore(ctx, id, receiptData); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to restore receipt with id %s: %w", id, restoreErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// assignScore stores the points, rule version and campaigns of receiptData on the stored receipt
+func (r *receiptServiceImpl) assignScore(ctx context.Context, id string, receiptData repo.ReceiptData) error {
+	_, span := r.startStoreSpan(ctx, "AssignScore", attrReceiptID.String(id))
+	err := r.store.AssignScore(id, receiptData.Point, receiptData.RuleVersion, receiptData.Campaigns)
+	endSpan(span, err)
+	return err
+}
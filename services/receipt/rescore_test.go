@@ -0,0 +1,227 @@
+package receipt
+
+import (
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"receipt-processor/services/rules"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RescoreTestSuite defines the suite for rescoring tests
+type RescoreTestSuite struct {
+	suite.Suite
+	service        ReceiptService
+	store          *repo.MemoryStore
+	mockExtReceipt models.ExtReceipt
+}
+
+// SetupTest creates a service scoring with the default rules and knowing a promotion
+func (suite *RescoreTestSuite) SetupTest() {
+	suite.mockExtReceipt = newMockExtReceipt()
+	promo, err := rules.New(rules.Config{Version: "promo", Rules: []rules.RuleConfig{
+		{Kind: "retailer_alphanumeric", Params: map[string]any{"pointsPerCharacter": 10}},
+	}})
+	suite.Require().NoError(err)
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewReceiptService(suite.store, WithLedger(suite.store), WithRuleHistory(promo))
+}
+
+func (suite *RescoreTestSuite) TestProcessRecordsRuleVersion() {
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	receiptData, err := suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal("1", receiptData.RuleVersion)
+}
+
+func (suite *RescoreTestSuite) TestKeepsCampaignTerms() {
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	receiptData, err := suite.store.Get(id)
+	suite.Require().NoError(err)
+	receiptData.Point = 128
+	receiptData.Campaigns = []models.AppliedCampaign{{ID: "double", Name: "Double points", Multiplier: 2, Bonus: 72, Points: 100}}
+	suite.Require().NoError(suite.store.Put(id, receiptData))
+
+	// The campaign doubles the new base points and adds its bonus again
+	report, err := suite.service.Rescore(ctx, RescoreQuery{Version: "promo", Apply: true})
+	suite.NoError(err)
+	suite.Equal([]RescoreResult{{ReceiptID: id, OldVersion: "1", OldPoints: 128, NewPoints: 192, Delta: 64}}, report.Results)
+	receiptData, err = suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(132), receiptData.Campaigns[0].Points)
+}
+
+func (suite *RescoreTestSuite) TestDryRun() {
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	report, err := suite.service.Rescore(ctx, RescoreQuery{Version: "promo"})
+	suite.NoError(err)
+	suite.False(report.Applied)
+	suite.Equal(1, report.Changed)
+	suite.Equal([]RescoreResult{{ReceiptID: id, OldVersion: "1", OldPoints: 28, NewPoints: 60, Delta: 32}}, report.Results)
+
+	// Nothing changed
+	receiptData, err := suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(28), receiptData.Point)
+	suite.Equal("1", receiptData.RuleVersion)
+}
+
+func (suite *RescoreTestSuite) TestApply() {
+	suite.mockExtReceipt.AccountID = "alice"
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	report, err := suite.service.Rescore(ctx, RescoreQuery{Version: "promo", Apply: true})
+	suite.NoError(err)
+	suite.Equal(int64(32), report.TotalDelta)
+
+	receiptData, err := suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(60), receiptData.Point)
+	suite.Equal("promo", receiptData.RuleVersion)
+	balance, err := suite.store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(60), balance)
+
+	// The breakdown is explained by the rules that awarded the points
+	breakdown, err := suite.service.GetPointsBreakdown(ctx, id)
+	suite.NoError(err)
+	suite.Equal(int64(60), breakdown.Total)
+
+	// Back to the current rules, the difference is debited
+	_, err = suite.service.Rescore(ctx, RescoreQuery{Apply: true})
+	suite.NoError(err)
+	balance, err = suite.store.Balance("alice")
+	suite.NoError(err)
+	suite.Equal(int64(28), balance)
+}
+
+func (suite *RescoreTestSuite) TestApplyKeepsSpentPoints() {
+	suite.mockExtReceipt.AccountID = "alice"
+	_, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	_, err = suite.service.Rescore(ctx, RescoreQuery{Version: "promo", Apply: true})
+	suite.Require().NoError(err)
+	_, err = suite.store.Transfer(repo.Transfer{ID: "redeem", Kind: repo.EntryRedemption, From: "alice", To: repo.RedeemedAccount, Points: 50})
+	suite.Require().NoError(err)
+
+	// Only 10 points are left, so the receipt cannot lose 32
+	report, err := suite.service.Rescore(ctx, RescoreQuery{Apply: true})
+	suite.NoError(err)
+	suite.Equal(1, report.Failed)
+	suite.ErrorIs(report.Results[0].Err, repo.ErrInsufficientFunds)
+	receiptData, err := suite.store.Get(report.Results[0].ReceiptID)
+	suite.NoError(err)
+	suite.Equal(int64(60), receiptData.Point)
+	suite.Equal("promo", receiptData.RuleVersion)
+}
+
+func (suite *RescoreTestSuite) TestFilterAndPaging() {
+	for i := 0; i < rescorePageSize+5; i++ {
+		_, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+		suite.Require().NoError(err)
+	}
+	suite.mockExtReceipt.Retailer = "Walmart"
+	_, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+
+	report, err := suite.service.Rescore(ctx, RescoreQuery{Version: "promo"})
+	suite.NoError(err)
+	suite.Len(report.Results, rescorePageSize+6)
+
+	report, err = suite.service.Rescore(ctx, RescoreQuery{Version: "promo", Filter: repo.ListQuery{Retailer: "walmart"}})
+	suite.NoError(err)
+	suite.Len(report.Results, 1)
+}
+
+func (suite *RescoreTestSuite) TestUnknownVersion() {
+	_, err := suite.service.Rescore(ctx, RescoreQuery{Version: "missing"})
+	suite.ErrorIs(err, ErrUnknownRuleVersion)
+}
+
+// assigningStore assigns a retailer to every listed receipt right after listing it,
+// like the retailer service matching receipts while they are being rescored
+type assigningStore struct {
+	*repo.MemoryStore
+}
+
+func (s assigningStore) List(q repo.ListQuery) ([]repo.ReceiptData, error) {
+	page, err := s.MemoryStore.List(q)
+	for _, data := range page {
+		s.MemoryStore.AssignRetailer(data.Receipt.ID, "target")
+	}
+	return page, err
+}
+
+func (suite *RescoreTestSuite) TestApplyKeepsAssignedRetailer() {
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	promo, err := rules.New(rules.Config{Version: "promo", Rules: []rules.RuleConfig{
+		{Kind: "retailer_alphanumeric", Params: map[string]any{"pointsPerCharacter": 10}},
+	}})
+	suite.Require().NoError(err)
+	service := NewReceiptService(assigningStore{suite.store}, WithLedger(suite.store), WithRuleHistory(promo))
+
+	_, err = service.Rescore(ctx, RescoreQuery{Version: "promo", Apply: true})
+	suite.Require().NoError(err)
+	receiptData, err := suite.store.Get(id)
+	suite.NoError(err)
+	suite.Equal(int64(60), receiptData.Point)
+	suite.Equal("target", receiptData.Receipt.RetailerID)
+}
+
+// eventRecorder keeps the events published to it
+type eventRecorder struct {
+	events []models.ReceiptEvent
+}
+
+func (e *eventRecorder) Publish(event models.ReceiptEvent) {
+	e.events = append(e.events, event)
+}
+
+func (suite *RescoreTestSuite) TestPublishesEvents() {
+	promo, err := rules.New(rules.Config{Version: "promo", Rules: []rules.RuleConfig{
+		{Kind: "retailer_alphanumeric", Params: map[string]any{"pointsPerCharacter": 10}},
+	}})
+	suite.Require().NoError(err)
+	recorder := &eventRecorder{}
+	suite.service = NewReceiptService(suite.store, WithRuleHistory(promo), WithEventPublisher(recorder))
+
+	id, err := suite.service.ProcessReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	// A dry run changes nothing, so publishes nothing
+	_, err = suite.service.Rescore(ctx, RescoreQuery{Version: "promo"})
+	suite.Require().NoError(err)
+	_, err = suite.service.Rescore(ctx, RescoreQuery{Version: "promo", Apply: true})
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.service.DeleteReceipt(ctx, id))
+
+	suite.Require().Len(recorder.events, 3)
+	processed, rescored, deleted := recorder.events[0], recorder.events[1], recorder.events[2]
+	suite.Equal(models.EventReceiptProcessed, processed.Type)
+	suite.Equal(id, processed.ReceiptID)
+	suite.Equal(int64(28), processed.Points)
+	suite.Equal("1", processed.RuleVersion)
+	suite.Nil(processed.PreviousPoints)
+	suite.NotEmpty(processed.ID)
+
+	suite.Equal(models.EventReceiptRescored, rescored.Type)
+	suite.Equal(int64(60), rescored.Points)
+	suite.Equal("promo", rescored.RuleVersion)
+	suite.Require().NotNil(rescored.PreviousPoints)
+	suite.Equal(int64(28), *rescored.PreviousPoints)
+
+	suite.Equal(models.EventReceiptDeleted, deleted.Type)
+	suite.Equal(id, deleted.ReceiptID)
+	suite.Equal(int64(60), deleted.Points)
+}
+
+func TestRescoreTestSuite(t *testing.T) {
+	suite.Run(t, new(RescoreTestSuite))
+}
@@ -0,0 +1,55 @@
+package receipt
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the receipt service
+const instrumentationName = "receipt-processor/services/receipt"
+
+// Attributes of the spans of the receipt service
+const (
+	attrReceiptID      = attribute.Key("receipt.id")
+	attrItemCount      = attribute.Key("receipt.item_count")
+	attrPoints         = attribute.Key("receipt.points")
+	attrRuleCount      = attribute.Key("rules.count")
+	attrRuleVersion    = attribute.Key("rules.version")
+	attrCampaignsCount = attribute.Key("campaigns.applied")
+	attrBatchSize      = attribute.Key("batch.size")
+)
+
+// WithTracerProvider creates the spans of the service with tp instead of the global tracer provider
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(r *receiptServiceImpl) {
+		r.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// defaultTracer creates spans with the global tracer provider, which is a no-op until one is installed
+func defaultTracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// startSpan starts a span of the service as a child of the span in ctx, if any
+func (r *receiptServiceImpl) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startStoreSpan starts the span of a call to a store, named after the store method
+func (r *receiptServiceImpl) startStoreSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, "store."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, recording err on it unless it is nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,110 @@
+package receipt
+
+import (
+	"context"
+	"receipt-processor/repo"
+	"receipt-processor/services/rules"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans returns a tracer provider keeping the spans it ends in the returned exporter
+func recordSpans() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+// spansByName indexes ended spans by name, each name is expected once
+func (suite *ReceiptServiceTestSuite) spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		suite.Require().NotContains(spans, span.Name)
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+// requireChild fails unless child is a direct child of parent
+func (suite *ReceiptServiceTestSuite) requireChild(parent, child tracetest.SpanStub) {
+	suite.Require().Equal(parent.SpanContext.SpanID(), child.Parent.SpanID(), "%s is not a child of %s", child.Name, parent.Name)
+	suite.Require().Equal(parent.SpanContext.TraceID(), child.SpanContext.TraceID())
+}
+
+// attributes returns the attributes of a span by key
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func (suite *ReceiptServiceTestSuite) TestProcessReceiptSpans() {
+	provider, exporter := recordSpans()
+	store := repo.NewMemoryStore()
+	suite.service = NewReceiptService(store,
+		WithLedger(store),
+		WithCampaigns(store),
+		WithRetailerMatcher(retailerMatcher{"Target": "target"}),
+		WithTracerProvider(provider))
+	suite.mockExtReceipt.AccountID = "alice"
+
+	requestCtx, request := provider.Tracer("test").Start(ctx, "request")
+	id, err := suite.service.ProcessReceipt(requestCtx, suite.mockExtReceipt)
+	request.End()
+	suite.Require().NoError(err)
+
+	spans := suite.spansByName(exporter)
+	suite.Len(spans, 7)
+	process := spans["ReceiptService.ProcessReceipt"]
+	suite.requireChild(spans["request"], process)
+	for _, name := range []string{"retailers.Match", "receipt.score", "store.Put", "store.Transfer"} {
+		suite.requireChild(process, spans[name])
+	}
+	suite.requireChild(spans["receipt.score"], spans["store.ListCampaigns"])
+
+	suite.Equal(id, attributes(process)[attrReceiptID].AsString())
+	suite.Equal(int64(5), attributes(process)[attrItemCount].AsInt64())
+	score := attributes(spans["receipt.score"])
+	suite.Equal(id, score[attrReceiptID].AsString())
+	suite.Equal(int64(len(rules.Default().Rules)), score[attrRuleCount].AsInt64())
+	suite.Equal(int64(5), score[attrItemCount].AsInt64())
+	suite.Equal(int64(28), score[attrPoints].AsInt64())
+	suite.Equal(id, attributes(spans["store.Put"])[attrReceiptID].AsString())
+}
+
+func (suite *ReceiptServiceTestSuite) TestFailedSpans() {
+	provider, exporter := recordSpans()
+	suite.service = NewReceiptService(suite.store, WithTracerProvider(provider))
+
+	_, err := suite.service.GetPoints(ctx, "missing-id")
+	suite.Require().ErrorIs(err, repo.ErrNotFound)
+
+	spans := suite.spansByName(exporter)
+	suite.Len(spans, 2)
+	suite.requireChild(spans["ReceiptService.GetPoints"], spans["store.Get"])
+	for _, span := range spans {
+		suite.Equal(codes.Error, span.Status.Code)
+		suite.Len(span.Events, 1, "the error is recorded on %s", span.Name)
+	}
+}
+
+func (suite *ReceiptServiceTestSuite) TestSubmitReceiptSpans() {
+	provider, exporter := recordSpans()
+	suite.service = NewReceiptService(suite.store, WithAsyncProcessing(1, 1), WithTracerProvider(provider))
+
+	job, err := suite.service.SubmitReceipt(ctx, suite.mockExtReceipt)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.service.Drain(context.Background()))
+
+	// The receipt is processed in a span under the span of its submission
+	spans := suite.spansByName(exporter)
+	process := spans["receipt.process"]
+	suite.requireChild(spans["ReceiptService.SubmitReceipt"], process)
+	suite.requireChild(process, spans["receipt.score"])
+	suite.requireChild(process, spans["store.Put"])
+	suite.Equal(job.ID, attributes(process)[attrReceiptID].AsString())
+}
@@ -0,0 +1,122 @@
+package retailer
+
+import (
+	"receipt-processor/models"
+	"strings"
+	"unicode"
+)
+
+// NormalizeName reduces a retailer name to lowercase words of letters and digits, so that
+// spelling variants compare equal: "&" reads as "and", store numbers such as "#1234" and
+// standalone numbers are dropped, and other punctuation separates words.
+// "TARGET #1234" and "Target" both normalize to "target".
+func NormalizeName(name string) string {
+	var words []string
+	for _, field := range strings.Fields(strings.ReplaceAll(name, "&", " and ")) {
+		if strings.HasPrefix(field, "#") || strings.IndexFunc(field, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return ' '
+		}, field)
+		words = append(words, strings.Fields(word)...)
+	}
+	return strings.Join(words, " ")
+}
+
+// retailerID derives the ID of a retailer from its normalized name
+func retailerID(normalized string) string {
+	return strings.ReplaceAll(normalized, " ", "-")
+}
+
+// maxDistance is the number of edits tolerated when fuzzy matching a name of n characters
+func maxDistance(n int) int {
+	return min(n/5, 3)
+}
+
+// index maps the normalized names and aliases of the registered retailers to their IDs
+type index map[string]string
+
+func newIndex(retailers []models.Retailer) index {
+	idx := make(index)
+	for _, r := range retailers {
+		idx[NormalizeName(r.Name)] = r.ID
+		for _, alias := range r.Aliases {
+			idx[NormalizeName(alias)] = r.ID
+		}
+	}
+	return idx
+}
+
+// match finds the retailer of a name. An exact match of the normalized name wins, then the
+// closest name within maxDistance edits, then the longest name the normalized name starts
+// with as whole words, such as "target" for "target store". A name equally close to
+// several retailers matches none of them.
+func (idx index) match(name string) (string, bool) {
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return "", false
+	}
+	if id, ok := idx[normalized]; ok {
+		return id, true
+	}
+
+	found, best, bestID, ambiguous := false, 0, "", false
+	consider := func(score int, id string) {
+		switch {
+		case !found || score < best:
+			found, best, bestID, ambiguous = true, score, id, false
+		case score == best && id != bestID:
+			ambiguous = true
+		}
+	}
+	for key, id := range idx {
+		if d := distance(normalized, key); d <= maxDistance(len([]rune(key))) {
+			consider(d, id)
+		}
+	}
+	if !found {
+		for key, id := range idx {
+			if strings.HasPrefix(normalized, key+" ") {
+				// Longer prefixes are closer matches
+				consider(-len(key), id)
+			}
+		}
+	}
+	if !found || ambiguous {
+		return "", false
+	}
+	return bestID, true
+}
+
+// distance is the edit distance between two strings, the number of single character
+// insertions, deletions, substitutions and transpositions of adjacent characters turning
+// one into the other, each substring being edited once
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between the first i runes of a and the first j runes of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,68 @@
+package retailer
+
+import (
+	"receipt-processor/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Target":               "target",
+		"TARGET #1234":         "target",
+		"  Target   Store 12 ": "target store",
+		"M&M Corner Market":    "m and m corner market",
+		"Walgreens-Express":    "walgreens express",
+		"7-Eleven":             "7 eleven",
+		"#42":                  "",
+	}
+	for name, want := range tests {
+		require.Equal(t, want, NormalizeName(name), name)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	idx := newIndex([]models.Retailer{
+		{ID: "target", Name: "Target", Aliases: []string{"Target Store"}},
+		{ID: "m-and-m-corner-market", Name: "M&M Corner Market"},
+		{ID: "walmart", Name: "Walmart"},
+		{ID: "walgreens", Name: "Walgreens"},
+	})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"TARGET #1234", "target"},
+		{"target store #7", "target"},
+		{"M & M Corner Market", "m-and-m-corner-market"},
+		{"M and M Corner Markt", "m-and-m-corner-market"},
+		{"Walgreen", "walgreens"},
+		{"Targte", "target"},
+		{"Target Express Downtown", "target"},
+		{"Costco", ""},
+		{"#1234", ""},
+	}
+	for _, tt := range tests {
+		id, _ := idx.match(tt.name)
+		require.Equal(t, tt.want, id, tt.name)
+	}
+}
+
+func TestMatchAmbiguous(t *testing.T) {
+	// "walmarts" is one edit away from both
+	idx := newIndex([]models.Retailer{
+		{ID: "walmart", Name: "Walmart"},
+		{ID: "walmarts-deli", Name: "Walmarts Deli", Aliases: []string{"Walmartss"}},
+	})
+	_, ok := idx.match("walmarts")
+	require.False(t, ok)
+}
+
+func TestDistance(t *testing.T) {
+	require.Equal(t, 0, distance("target", "target"))
+	require.Equal(t, 3, distance("kitten", "sitting"))
+	require.Equal(t, 1, distance("target", "targte"))
+	require.Equal(t, 6, distance("", "target"))
+}
@@ -0,0 +1,279 @@
+package retailer
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type RetailerService interface {
+	RegisterRetailer(name string, aliases []string) (models.Retailer, error)
+	GetRetailer(id string) (models.Retailer, error)
+	ListRetailers() ([]models.Retailer, error)
+	AddAlias(id, alias string) (models.Retailer, error)
+	RemoveAlias(id, alias string) (models.Retailer, error)
+	ListUnmatched() ([]models.UnmatchedRetailer, error)
+	Match(name string) (string, error)
+}
+
+var (
+	// ErrInvalidName is returned when a retailer name or alias has no letters
+	ErrInvalidName = errors.New("retailer name must contain letters")
+	// ErrRetailerExists is returned when registering a retailer whose name normalizes to a registered ID
+	ErrRetailerExists = errors.New("retailer already registered")
+	// ErrAliasTaken is returned when a name or alias already matches another retailer exactly
+	ErrAliasTaken = errors.New("name already belongs to another retailer")
+	// ErrAliasNotFound is returned when removing an alias the retailer does not have
+	ErrAliasNotFound = errors.New("alias not found")
+)
+
+// scanPageSize is how many receipts are read from the store at a time when scanning unmatched receipts
+const scanPageSize = 100
+
+type retailerServiceImpl struct {
+	store    repo.RetailerStore
+	receipts repo.ReceiptStore
+	// mu guards idx and serializes changes to the registry
+	mu  sync.RWMutex
+	idx index
+}
+
+// NewRetailerService creates a RetailerService keeping the registry in store. Receipts that
+// matched no retailer are matched again whenever a retailer or alias is added.
+func NewRetailerService(store repo.RetailerStore, receipts repo.ReceiptStore) RetailerService {
+	return &retailerServiceImpl{store: store, receipts: receipts}
+}
+
+// Registers a canonical retailer with an ID derived from its name, then assigns it the unmatched receipts it matches
+func (s *retailerServiceImpl) RegisterRetailer(name string, aliases []string) (models.Retailer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.editIndex()
+	if err != nil {
+		return models.Retailer{}, err
+	}
+
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return models.Retailer{}, ErrInvalidName
+	}
+	r := models.Retailer{ID: retailerID(normalized), Name: strings.TrimSpace(name), Aliases: []string{}}
+	if _, err := s.store.GetRetailer(r.ID); err == nil {
+		return models.Retailer{}, fmt.Errorf("retailer with id %s: %w", r.ID, ErrRetailerExists)
+	} else if !errors.Is(err, repo.ErrRetailerNotFound) {
+		return models.Retailer{}, fmt.Errorf("failed to retrieve retailer with id %s: %w", r.ID, err)
+	}
+	if err := idx.claim(normalized, r.ID); err != nil {
+		return models.Retailer{}, err
+	}
+	for _, alias := range aliases {
+		if r, err = addAlias(idx, r, alias); err != nil {
+			return models.Retailer{}, err
+		}
+	}
+	return r, s.save(r, idx)
+}
+
+// Retrieves a retailer by ID
+func (s *retailerServiceImpl) GetRetailer(id string) (models.Retailer, error) {
+	r, err := s.store.GetRetailer(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrRetailerNotFound) {
+			return models.Retailer{}, fmt.Errorf("retailer with id %s does not exist: %w", id, err)
+		}
+		return models.Retailer{}, fmt.Errorf("failed to retrieve retailer with id %s: %w", id, err)
+	}
+	return r, nil
+}
+
+// Lists every registered retailer ordered by ID
+func (s *retailerServiceImpl) ListRetailers() ([]models.Retailer, error) {
+	list, err := s.store.ListRetailers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retailers: %w", err)
+	}
+	return list, nil
+}
+
+// Adds an alternative name to a retailer, then assigns it the unmatched receipts it now matches
+func (s *retailerServiceImpl) AddAlias(id, alias string) (models.Retailer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.editIndex()
+	if err != nil {
+		return models.Retailer{}, err
+	}
+	r, err := s.GetRetailer(id)
+	if err != nil {
+		return models.Retailer{}, err
+	}
+	if r, err = addAlias(idx, r, alias); err != nil {
+		return models.Retailer{}, err
+	}
+	return r, s.save(r, idx)
+}
+
+// Removes an alias from a retailer. Receipts already matched through it keep their retailer.
+func (s *retailerServiceImpl) RemoveAlias(id, alias string) (models.Retailer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.editIndex()
+	if err != nil {
+		return models.Retailer{}, err
+	}
+	r, err := s.GetRetailer(id)
+	if err != nil {
+		return models.Retailer{}, err
+	}
+	normalized := NormalizeName(alias)
+	i := slices.IndexFunc(r.Aliases, func(a string) bool { return NormalizeName(a) == normalized })
+	if i < 0 {
+		return models.Retailer{}, fmt.Errorf("retailer with id %s has no alias %q: %w", id, alias, ErrAliasNotFound)
+	}
+	r.Aliases = append(r.Aliases[:i:i], r.Aliases[i+1:]...)
+	if err := s.store.PutRetailer(r); err != nil {
+		return models.Retailer{}, fmt.Errorf("failed to store retailer with id %s: %w", id, err)
+	}
+	// The canonical name may normalize to the same words as the removed alias
+	if normalized != NormalizeName(r.Name) {
+		delete(idx, normalized)
+	}
+	s.idx = idx
+	return r, nil
+}
+
+// Lists the retailer names of stored receipts that matched no retailer, most frequent first
+func (s *retailerServiceImpl) ListUnmatched() ([]models.UnmatchedRetailer, error) {
+	counts := make(map[string]int)
+	err := s.scanUnmatched(func(data repo.ReceiptData) error {
+		counts[strings.TrimSpace(data.Receipt.Retailer)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]models.UnmatchedRetailer, 0, len(counts))
+	for name, n := range counts {
+		list = append(list, models.UnmatchedRetailer{Name: name, Receipts: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Receipts != list[j].Receipts {
+			return list[i].Receipts > list[j].Receipts
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Returns the ID of the retailer a name matches, or an empty ID if it matches none
+func (s *retailerServiceImpl) Match(name string) (string, error) {
+	s.mu.RLock()
+	idx := s.idx
+	s.mu.RUnlock()
+	if idx == nil {
+		s.mu.Lock()
+		var err error
+		idx, err = s.index()
+		s.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+	}
+	id, _ := idx.match(name)
+	return id, nil
+}
+
+// index returns the cached index of the registry, loading it on first use. Callers hold mu.
+func (s *retailerServiceImpl) index() (index, error) {
+	if s.idx != nil {
+		return s.idx, nil
+	}
+	retailers, err := s.store.ListRetailers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retailers: %w", err)
+	}
+	s.idx = newIndex(retailers)
+	return s.idx, nil
+}
+
+// editIndex returns a copy of the index to change, installed once the change is stored. Callers hold mu.
+func (s *retailerServiceImpl) editIndex() (index, error) {
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	return maps.Clone(idx), nil
+}
+
+// save stores a retailer whose names were claimed in idx, installs idx and assigns
+// the retailer the unmatched receipts it matches
+func (s *retailerServiceImpl) save(r models.Retailer, idx index) error {
+	if err := s.store.PutRetailer(r); err != nil {
+		return fmt.Errorf("failed to store retailer with id %s: %w", r.ID, err)
+	}
+	s.idx = idx
+	return s.scanUnmatched(func(data repo.ReceiptData) error {
+		id, ok := idx.match(data.Receipt.Retailer)
+		if !ok || id != r.ID {
+			return nil
+		}
+		err := s.receipts.AssignRetailer(data.Receipt.ID, id)
+		if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			return fmt.Errorf("failed to assign retailer with id %s to receipt with id %s: %w", id, data.Receipt.ID, err)
+		}
+		return nil
+	})
+}
+
+// scanUnmatched calls fn for every stored receipt that matched no retailer
+func (s *retailerServiceImpl) scanUnmatched(fn func(repo.ReceiptData) error) error {
+	unmatched := ""
+	q := repo.ListQuery{Limit: scanPageSize, RetailerID: &unmatched}
+	for {
+		page, err := s.receipts.List(q)
+		if err != nil {
+			return fmt.Errorf("failed to list receipts: %w", err)
+		}
+		for _, data := range page {
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+		q.After = page[len(page)-1].Receipt.ID
+	}
+}
+
+// claim maps a normalized name to a retailer unless it belongs to another one
+func (idx index) claim(normalized, id string) error {
+	if owner, ok := idx[normalized]; ok && owner != id {
+		return fmt.Errorf("%q matches retailer with id %s: %w", normalized, owner, ErrAliasTaken)
+	}
+	idx[normalized] = id
+	return nil
+}
+
+// addAlias claims an alias for a retailer in the index and adds it to its aliases, unless it already has it
+func addAlias(idx index, r models.Retailer, alias string) (models.Retailer, error) {
+	normalized := NormalizeName(alias)
+	if normalized == "" {
+		return r, ErrInvalidName
+	}
+	if err := idx.claim(normalized, r.ID); err != nil {
+		return r, err
+	}
+	if normalized == NormalizeName(r.Name) || slices.IndexFunc(r.Aliases, func(a string) bool { return NormalizeName(a) == normalized }) >= 0 {
+		return r, nil
+	}
+	r.Aliases = append(r.Aliases, strings.TrimSpace(alias))
+	return r, nil
+}
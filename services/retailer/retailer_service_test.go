@@ -0,0 +1,133 @@
+package retailer
+
+import (
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RetailerServiceTestSuite defines the suite for service tests
+type RetailerServiceTestSuite struct {
+	suite.Suite
+	service RetailerService
+	store   *repo.MemoryStore
+}
+
+// SetupTest initializes the suite
+func (suite *RetailerServiceTestSuite) SetupTest() {
+	// Use a fresh storage for each test
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewRetailerService(suite.store, suite.store)
+}
+
+// putReceipt stores a receipt from a retailer that matched no retailer
+func (suite *RetailerServiceTestSuite) putReceipt(id, retailer string) {
+	data := repo.ReceiptData{Receipt: models.Receipt{ID: id, Retailer: retailer, PurchaseDate: "2022-01-01"}}
+	suite.Require().NoError(suite.store.Put(id, data))
+}
+
+func (suite *RetailerServiceTestSuite) retailerOf(id string) string {
+	data, err := suite.store.Get(id)
+	suite.Require().NoError(err)
+	return data.Receipt.RetailerID
+}
+
+func (suite *RetailerServiceTestSuite) TestRegisterRetailer() {
+	r, err := suite.service.RegisterRetailer(" M&M Corner Market ", []string{"M and M Market", "M&M Market"})
+	suite.NoError(err)
+	suite.Equal(models.Retailer{ID: "m-and-m-corner-market", Name: "M&M Corner Market", Aliases: []string{"M and M Market"}}, r)
+
+	got, err := suite.service.GetRetailer(r.ID)
+	suite.NoError(err)
+	suite.Equal(r, got)
+
+	// The ID is taken, as are the names
+	_, err = suite.service.RegisterRetailer("M and M Corner Market", nil)
+	suite.ErrorIs(err, ErrRetailerExists)
+	_, err = suite.service.RegisterRetailer("Corner Store", []string{"M&M Market"})
+	suite.ErrorIs(err, ErrAliasTaken)
+	_, err = suite.service.RegisterRetailer("#12", nil)
+	suite.ErrorIs(err, ErrInvalidName)
+
+	// Failed registrations leave no trace
+	id, err := suite.service.Match("Corner Store")
+	suite.NoError(err)
+	suite.Empty(id)
+	list, err := suite.service.ListRetailers()
+	suite.NoError(err)
+	suite.Len(list, 1)
+}
+
+func (suite *RetailerServiceTestSuite) TestAliases() {
+	_, err := suite.service.RegisterRetailer("Target", nil)
+	suite.Require().NoError(err)
+	_, err = suite.service.RegisterRetailer("Walgreens", nil)
+	suite.Require().NoError(err)
+
+	r, err := suite.service.AddAlias("target", "Tgt Superstore")
+	suite.NoError(err)
+	suite.Equal([]string{"Tgt Superstore"}, r.Aliases)
+	id, err := suite.service.Match("TGT SUPERSTORE #44")
+	suite.NoError(err)
+	suite.Equal("target", id)
+
+	_, err = suite.service.AddAlias("walgreens", "tgt superstore")
+	suite.ErrorIs(err, ErrAliasTaken)
+	_, err = suite.service.AddAlias("missing", "Costco")
+	suite.ErrorIs(err, repo.ErrRetailerNotFound)
+
+	r, err = suite.service.RemoveAlias("target", "tgt  superstore")
+	suite.NoError(err)
+	suite.Empty(r.Aliases)
+	id, err = suite.service.Match("TGT SUPERSTORE #44")
+	suite.NoError(err)
+	suite.Empty(id)
+	_, err = suite.service.RemoveAlias("target", "Tgt Superstore")
+	suite.ErrorIs(err, ErrAliasNotFound)
+}
+
+func (suite *RetailerServiceTestSuite) TestUnmatchedReceipts() {
+	suite.putReceipt("r1", "TARGET #1234")
+	suite.putReceipt("r2", "Target")
+	suite.putReceipt("r3", "Tgt")
+	suite.putReceipt("r4", "Walgreens")
+	suite.putReceipt("r5", "Target")
+
+	unmatched, err := suite.service.ListUnmatched()
+	suite.NoError(err)
+	suite.Equal([]models.UnmatchedRetailer{
+		{Name: "Target", Receipts: 2},
+		{Name: "TARGET #1234", Receipts: 1},
+		{Name: "Tgt", Receipts: 1},
+		{Name: "Walgreens", Receipts: 1},
+	}, unmatched)
+
+	// Registering a retailer and its aliases assigns it the receipts that now match
+	_, err = suite.service.RegisterRetailer("Target", nil)
+	suite.Require().NoError(err)
+	suite.Equal("target", suite.retailerOf("r1"))
+	suite.Equal("target", suite.retailerOf("r5"))
+	suite.Empty(suite.retailerOf("r3"))
+
+	_, err = suite.service.AddAlias("target", "Tgt")
+	suite.Require().NoError(err)
+	suite.Equal("target", suite.retailerOf("r3"))
+
+	unmatched, err = suite.service.ListUnmatched()
+	suite.NoError(err)
+	suite.Equal([]models.UnmatchedRetailer{{Name: "Walgreens", Receipts: 1}}, unmatched)
+}
+
+func (suite *RetailerServiceTestSuite) TestMatchLoadsRegistry() {
+	suite.Require().NoError(suite.store.PutRetailer(models.Retailer{ID: "target", Name: "Target", Aliases: []string{}}))
+
+	id, err := suite.service.Match("Target #1")
+	suite.NoError(err)
+	suite.Equal("target", id)
+}
+
+func TestRetailerServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(RetailerServiceTestSuite))
+}
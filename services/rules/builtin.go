@@ -0,0 +1,324 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"receipt-processor/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// newRule builds a built-in rule from its configuration.
+// Params left out of the configuration keep the values of the original rules.
+func newRule(rc RuleConfig) (Rule, error) {
+	name := rc.Name
+	if name == "" {
+		name = rc.Kind
+	}
+
+	var rule Rule
+	var params any
+	switch rc.Kind {
+	case "retailer_alphanumeric":
+		r := &retailerAlphanumericRule{name: name, PointsPerCharacter: 1}
+		rule, params = r, r
+	case "round_dollar_total":
+		r := &roundDollarTotalRule{name: name, Points: 50}
+		rule, params = r, r
+	case "total_multiple":
+		r := &totalMultipleRule{name: name, Multiple: 0.25, Points: 25}
+		rule, params = r, r
+	case "item_pairs":
+		r := &itemPairsRule{name: name, ItemsPerGroup: 2, Points: 5}
+		rule, params = r, r
+	case "item_description_length":
+		r := &itemDescriptionLengthRule{name: name, LengthMultiple: 3, PriceMultiplier: 0.2}
+		rule, params = r, r
+	case "odd_purchase_day":
+		r := &oddPurchaseDayRule{name: name, Points: 6}
+		rule, params = r, r
+	case "purchase_time_window":
+		r := &purchaseTimeWindowRule{name: name, Start: "14:00", End: "16:00", Points: 10}
+		rule, params = r, r
+	default:
+		return nil, fmt.Errorf("unknown rule kind %q", rc.Kind)
+	}
+
+	if err := decodeParams(rc.Params, params); err != nil {
+		return nil, err
+	}
+	if v, ok := rule.(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rule, nil
+}
+
+func pointsIf(matched bool, points int64) int64 {
+	if matched {
+		return points
+	}
+	return 0
+}
+
+var alphanumericRegex = regexp.MustCompile(`[a-zA-Z0-9]`)
+
+// Points for every alphanumeric character in the retailer name
+type retailerAlphanumericRule struct {
+	name               string
+	PointsPerCharacter int64 `json:"pointsPerCharacter"`
+}
+
+func (r *retailerAlphanumericRule) Name() string { return r.name }
+
+func (r *retailerAlphanumericRule) Apply(receipt models.Receipt) models.RuleResult {
+	count := len(alphanumericRegex.FindAllString(receipt.Retailer, -1))
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d point(s) for every alphanumeric character in the retailer name.", r.PointsPerCharacter),
+		Matched:     count > 0,
+		Inputs:      map[string]any{"retailer": receipt.Retailer, "alphanumericCount": count},
+		Points:      int64(count) * r.PointsPerCharacter,
+	}
+}
+
+// Points if the total is a round dollar amount with no cents
+type roundDollarTotalRule struct {
+	name   string
+	Points int64 `json:"points"`
+}
+
+func (r *roundDollarTotalRule) Name() string { return r.name }
+
+func (r *roundDollarTotalRule) Apply(receipt models.Receipt) models.RuleResult {
+	matched := receipt.Total.Cents()%100 == 0
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d points if the total is a round dollar amount with no cents.", r.Points),
+		Matched:     matched,
+		Inputs:      map[string]any{"total": receipt.Total.String()},
+		Points:      pointsIf(matched, r.Points),
+	}
+}
+
+// Points if the total is a multiple of an amount
+type totalMultipleRule struct {
+	name          string
+	Multiple      float64 `json:"multiple"`
+	Points        int64   `json:"points"`
+	multipleCents int64
+}
+
+func (r *totalMultipleRule) Name() string { return r.name }
+
+func (r *totalMultipleRule) validate() error {
+	cents, ok := exactScaled(r.Multiple, 100)
+	if !ok || cents <= 0 {
+		return errors.New("multiple must be a positive amount in whole cents")
+	}
+	r.multipleCents = cents
+	return nil
+}
+
+func (r *totalMultipleRule) Apply(receipt models.Receipt) models.RuleResult {
+	matched := receipt.Total.Cents()%r.multipleCents == 0
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d points if the total is a multiple of %g.", r.Points, r.Multiple),
+		Matched:     matched,
+		Inputs:      map[string]any{"total": receipt.Total.String()},
+		Points:      pointsIf(matched, r.Points),
+	}
+}
+
+// Points for every group of items on the receipt
+type itemPairsRule struct {
+	name          string
+	ItemsPerGroup int   `json:"itemsPerGroup"`
+	Points        int64 `json:"points"`
+}
+
+func (r *itemPairsRule) Name() string { return r.name }
+
+func (r *itemPairsRule) validate() error {
+	if r.ItemsPerGroup <= 0 {
+		return errors.New("itemsPerGroup must be positive")
+	}
+	return nil
+}
+
+func (r *itemPairsRule) Apply(receipt models.Receipt) models.RuleResult {
+	groups := len(receipt.Items) / r.ItemsPerGroup
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d points for every %d items on the receipt.", r.Points, r.ItemsPerGroup),
+		Matched:     groups > 0,
+		Inputs:      map[string]any{"itemCount": len(receipt.Items)},
+		Points:      int64(groups) * r.Points,
+	}
+}
+
+// Points for items whose trimmed description length is a multiple of a number,
+// worth the price times a multiplier rounded up to the nearest integer
+type itemDescriptionLengthRule struct {
+	name            string
+	LengthMultiple  int     `json:"lengthMultiple"`
+	PriceMultiplier float64 `json:"priceMultiplier"`
+	// PriceMultiplier in millionths, so points are computed without binary float rounding
+	multiplierMicros int64
+}
+
+func (r *itemDescriptionLengthRule) Name() string { return r.name }
+
+func (r *itemDescriptionLengthRule) validate() error {
+	if r.LengthMultiple <= 0 {
+		return errors.New("lengthMultiple must be positive")
+	}
+	micros, ok := exactScaled(r.PriceMultiplier, 1_000_000)
+	if !ok || micros < 0 {
+		return errors.New("priceMultiplier must be non-negative with at most 6 decimals")
+	}
+	r.multiplierMicros = micros
+	return nil
+}
+
+func (r *itemDescriptionLengthRule) Apply(receipt models.Receipt) models.RuleResult {
+	var points int64
+	itemInputs := make([]map[string]any, 0, len(receipt.Items))
+	for _, item := range receipt.Items {
+		trimmedDescription := strings.TrimSpace(item.ShortDescription)
+		var itemPoints int64
+		if len(trimmedDescription)%r.LengthMultiple == 0 {
+			itemPoints = ceilDiv(item.Price.Cents(), r.multiplierMicros, 100*1_000_000)
+		}
+		points += itemPoints
+		itemInputs = append(itemInputs, map[string]any{
+			"shortDescription": item.ShortDescription,
+			"trimmedLength":    len(trimmedDescription),
+			"price":            item.Price.String(),
+			"points":           itemPoints,
+		})
+	}
+	return models.RuleResult{
+		Rule: r.name,
+		Description: fmt.Sprintf("If the trimmed length of an item description is a multiple of %d, "+
+			"multiply the price by %g and round up to the nearest integer.", r.LengthMultiple, r.PriceMultiplier),
+		Matched: points > 0,
+		Inputs:  map[string]any{"items": itemInputs},
+		Points:  points,
+	}
+}
+
+// exactScaled converts a config value such as 0.25 into an integer number of 1/scale units,
+// reporting false if it has more precision than the scale can hold
+func exactScaled(value float64, scale int64) (int64, bool) {
+	scaled := math.Round(value * float64(scale))
+	if math.Abs(value*float64(scale)-scaled) > 1e-6 || math.Abs(scaled) > math.MaxInt64/2 {
+		return 0, false
+	}
+	return int64(scaled), true
+}
+
+// ceilDiv returns ceil(a * b / d) for non-negative a, b and positive d without overflowing
+func ceilDiv(a, b, d int64) int64 {
+	product := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	divisor := big.NewInt(d)
+	quotient, remainder := new(big.Int).QuoRem(product, divisor, new(big.Int))
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if !quotient.IsInt64() {
+		return math.MaxInt64
+	}
+	return quotient.Int64()
+}
+
+// Points if the day in the purchase date is odd
+type oddPurchaseDayRule struct {
+	name   string
+	Points int64 `json:"points"`
+}
+
+func (r *oddPurchaseDayRule) Name() string { return r.name }
+
+func (r *oddPurchaseDayRule) Apply(receipt models.Receipt) models.RuleResult {
+	day, err := strconv.Atoi(dayOfDate(receipt.PurchaseDate))
+	matched := err == nil && day%2 != 0
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d points if the day in the purchase date is odd.", r.Points),
+		Matched:     matched,
+		Inputs:      map[string]any{"purchaseDate": receipt.PurchaseDate},
+		Points:      pointsIf(matched, r.Points),
+	}
+}
+
+// dayOfDate returns the day field of a yyyy-mm-dd date, or "" if there is none
+func dayOfDate(date string) string {
+	parts := strings.Split(date, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// Points if the time of purchase is within [Start, End)
+type purchaseTimeWindowRule struct {
+	name   string
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Points int64  `json:"points"`
+}
+
+func (r *purchaseTimeWindowRule) Name() string { return r.name }
+
+func (r *purchaseTimeWindowRule) validate() error {
+	start, err := minuteOfDay(r.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := minuteOfDay(r.End)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if start >= end {
+		return errors.New("start must be before end")
+	}
+	return nil
+}
+
+func (r *purchaseTimeWindowRule) Apply(receipt models.Receipt) models.RuleResult {
+	// The window bounds were checked by validate
+	start, _ := minuteOfDay(r.Start)
+	end, _ := minuteOfDay(r.End)
+	minute, err := minuteOfDay(receipt.PurchaseTime)
+	matched := err == nil && minute >= start && minute < end
+	return models.RuleResult{
+		Rule:        r.name,
+		Description: fmt.Sprintf("%d points if the time of purchase is at or after %s and before %s.", r.Points, r.Start, r.End),
+		Matched:     matched,
+		Inputs:      map[string]any{"purchaseTime": receipt.PurchaseTime},
+		Points:      pointsIf(matched, r.Points),
+	}
+}
+
+// minuteOfDay converts a 24-hour "hh:mm" time into minutes since midnight
+func minuteOfDay(clock string) (int, error) {
+	hh, mm, found := strings.Cut(clock, ":")
+	if !found {
+		return 0, fmt.Errorf("time %q is not in hh:mm format", clock)
+	}
+	hour, err := strconv.Atoi(hh)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("time %q has an invalid hour", clock)
+	}
+	minute, err := strconv.Atoi(mm)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time %q has an invalid minute", clock)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,169 @@
+// Package rules scores receipts with a configurable set of rules.
+package rules
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"receipt-processor/models"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule awards points for one aspect of a receipt
+type Rule interface {
+	// Name identifies the rule in a points breakdown
+	Name() string
+	// Apply scores a receipt and explains the result
+	Apply(receipt models.Receipt) models.RuleResult
+}
+
+// RuleSet is an ordered, versioned list of enabled rules
+type RuleSet struct {
+	Version string
+	Rules   []Rule
+}
+
+// Score applies every rule to a receipt and explains how each one contributed to the total
+func (rs *RuleSet) Score(receipt models.Receipt) models.PointsBreakdown {
+	breakdown := models.PointsBreakdown{Rules: make([]models.RuleResult, 0, len(rs.Rules))}
+	for _, rule := range rs.Rules {
+		result := rule.Apply(receipt)
+		breakdown.Rules = append(breakdown.Rules, result)
+		breakdown.Total += result.Points
+	}
+	return breakdown
+}
+
+// Config is the file representation of a RuleSet
+type Config struct {
+	Version string       `json:"version" yaml:"version"`
+	Rules   []RuleConfig `json:"rules" yaml:"rules"`
+}
+
+// RuleConfig configures a single rule.
+// Name defaults to Kind, Enabled defaults to true and Params depend on Kind.
+type RuleConfig struct {
+	Kind    string         `json:"kind" yaml:"kind"`
+	Name    string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Enabled *bool          `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Params  map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+//go:embed default.yaml
+var defaultConfig []byte
+
+// DefaultConfig returns the configuration reproducing the original scoring rules
+func DefaultConfig() Config {
+	var config Config
+	if err := yaml.Unmarshal(defaultConfig, &config); err != nil {
+		panic(fmt.Sprintf("invalid embedded default rule set: %v", err))
+	}
+	return config
+}
+
+// Default returns the rule set reproducing the original scoring rules
+func Default() *RuleSet {
+	rs, err := New(DefaultConfig())
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded default rule set: %v", err))
+	}
+	return rs
+}
+
+// Load reads a rule set from a .yaml, .yml or .json file
+func Load(path string) (*RuleSet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule set: %w", err)
+	}
+
+	var config Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(raw, &config)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &config)
+	default:
+		return nil, fmt.Errorf("unsupported rule set format %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rule set %s: %w", path, err)
+	}
+
+	rs, err := New(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule set %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// LoadDir reads every .yaml, .yml and .json rule set in dir, for example the
+// rule sets used in the past. Each must have a version, and versions must be unique.
+func LoadDir(dir string) ([]*RuleSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule set directory: %w", err)
+	}
+
+	var sets []*RuleSet
+	paths := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		rs, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if rs.Version == "" {
+			return nil, fmt.Errorf("rule set %s has no version", path)
+		}
+		if other, exists := paths[rs.Version]; exists {
+			return nil, fmt.Errorf("rule sets %s and %s have the same version %q", other, path, rs.Version)
+		}
+		paths[rs.Version] = path
+		sets = append(sets, rs)
+	}
+	return sets, nil
+}
+
+// New builds a RuleSet from its configuration, skipping disabled rules
+func New(config Config) (*RuleSet, error) {
+	rs := &RuleSet{Version: config.Version}
+	names := make(map[string]bool)
+	for i, rc := range config.Rules {
+		if rc.Enabled != nil && !*rc.Enabled {
+			continue
+		}
+		rule, err := newRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rc.Kind, err)
+		}
+		if names[rule.Name()] {
+			return nil, fmt.Errorf("rule %d (%s): duplicate rule name %q", i, rc.Kind, rule.Name())
+		}
+		names[rule.Name()] = true
+		rs.Rules = append(rs.Rules, rule)
+	}
+	return rs, nil
+}
+
+// decodeParams copies the generic params of a rule into its typed params, rejecting unknown keys
+func decodeParams(params map[string]any, dst any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
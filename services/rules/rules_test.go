@@ -0,0 +1,261 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"receipt-processor/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// legacyCalculatePoints is the hard-coded float scoring the default rule set replaces
+func legacyCalculatePoints(receipt models.Receipt) int64 {
+	var points int64
+
+	retailerRegex := regexp.MustCompile(`[a-zA-Z0-9]`)
+	points += int64(len(retailerRegex.FindAllString(receipt.Retailer, -1)))
+
+	total, err := strconv.ParseFloat(receipt.Total.String(), 64)
+	if err != nil {
+		return points
+	}
+	if total == float64(int(total)) {
+		points += 50
+	}
+	if math.Mod(total, 0.25) == 0 {
+		points += 25
+	}
+
+	points += int64(len(receipt.Items) / 2 * 5)
+
+	for _, item := range receipt.Items {
+		trimmedDescription := strings.TrimSpace(item.ShortDescription)
+		if len(trimmedDescription)%3 == 0 {
+			price, err := strconv.ParseFloat(item.Price.String(), 64)
+			if err == nil {
+				points += int64(math.Ceil(price * 0.2))
+			}
+		}
+	}
+
+	day, err := strconv.Atoi(strings.Split(receipt.PurchaseDate, "-")[2])
+	if err == nil && day%2 != 0 {
+		points += 6
+	}
+
+	hour, err := strconv.Atoi(strings.Split(receipt.PurchaseTime, ":")[0])
+	if err == nil && hour >= 14 && hour < 16 {
+		points += 10
+	}
+
+	return points
+}
+
+func targetReceipt() models.Receipt {
+	return models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
+			{ShortDescription: "Emils Cheese Pizza", Price: 1225},
+			{ShortDescription: "Knorr Creamy Chicken", Price: 126},
+			{ShortDescription: "Doritos Nacho Cheese", Price: 335},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: 1200},
+		},
+		Total: 3535,
+	}
+}
+
+func marketReceipt() models.Receipt {
+	return models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+		},
+		Total: 900,
+	}
+}
+
+func TestDefaultRuleSetScores(t *testing.T) {
+	rs := Default()
+	require.Equal(t, "1", rs.Version)
+	require.Len(t, rs.Rules, 7)
+
+	require.Equal(t, int64(28), rs.Score(targetReceipt()).Total)
+	require.Equal(t, int64(109), rs.Score(marketReceipt()).Total)
+}
+
+func TestDefaultRuleSetMatchesLegacyScoring(t *testing.T) {
+	rs := Default()
+	rng := rand.New(rand.NewSource(1))
+	descriptions := []string{"Gatorade", "Mountain Dew 12PK", "  Pepsi ", "Emils Cheese Pizza", "abc", "Klarbrunn 12-PK 12 FL OZ"}
+
+	for i := 0; i < 2000; i++ {
+		receipt := models.Receipt{
+			Retailer:     []string{"Target", "M&M Corner Market", "Walgreens", "7-Eleven #42"}[rng.Intn(4)],
+			PurchaseDate: fmt.Sprintf("2022-%02d-%02d", rng.Intn(12)+1, rng.Intn(28)+1),
+			PurchaseTime: fmt.Sprintf("%02d:%02d", rng.Intn(24), rng.Intn(60)),
+		}
+		var cents int
+		for n := rng.Intn(6); n > 0; n-- {
+			price := rng.Intn(2000)
+			cents += price
+			receipt.Items = append(receipt.Items, models.Item{
+				ShortDescription: descriptions[rng.Intn(len(descriptions))],
+				Price:            models.Money(price),
+			})
+		}
+		if rng.Intn(3) == 0 {
+			cents -= cents % 25
+		}
+		receipt.Total = models.Money(cents)
+
+		require.Equal(t, legacyCalculatePoints(receipt), rs.Score(receipt).Total, "receipt %+v", receipt)
+	}
+}
+
+func TestRulesUseExactMoneyArithmetic(t *testing.T) {
+	rs, err := New(Config{Rules: []RuleConfig{
+		{Kind: "total_multiple", Params: map[string]any{"multiple": 0.1, "points": 10}},
+		{Kind: "item_description_length", Params: map[string]any{"priceMultiplier": 1.1}},
+	}})
+	require.NoError(t, err)
+
+	// In binary floats 0.30 is not a multiple of 0.1 and 50.00 * 1.1 is 55.00000000000001
+	receipt := models.Receipt{
+		Items: []models.Item{{ShortDescription: "abc", Price: 5000}},
+		Total: 30,
+	}
+	breakdown := rs.Score(receipt)
+	require.True(t, breakdown.Rules[0].Matched)
+	require.Equal(t, int64(10), breakdown.Rules[0].Points)
+	require.Equal(t, int64(55), breakdown.Rules[1].Points)
+
+	// Fractions of a cent still round up
+	receipt.Items[0].Price = 1
+	require.Equal(t, int64(1), rs.Score(receipt).Rules[1].Points)
+}
+
+func TestScoreBreakdownAddsUp(t *testing.T) {
+	breakdown := Default().Score(targetReceipt())
+
+	var sum int64
+	for _, result := range breakdown.Rules {
+		sum += result.Points
+	}
+	require.Equal(t, breakdown.Total, sum)
+	require.Equal(t, "quarter_multiple_total", breakdown.Rules[2].Rule)
+	require.Equal(t, "afternoon_purchase_time", breakdown.Rules[6].Rule)
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+version: "2024-promo"
+rules:
+  - kind: retailer_alphanumeric
+    params:
+      pointsPerCharacter: 2
+  - kind: round_dollar_total
+    enabled: false
+  - kind: purchase_time_window
+    name: happy_hour
+    params:
+      start: "17:00"
+      end: "19:30"
+      points: 100
+`), 0o644))
+
+	rs, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, "2024-promo", rs.Version)
+	require.Len(t, rs.Rules, 2)
+
+	receipt := marketReceipt()
+	receipt.PurchaseTime = "19:29"
+	breakdown := rs.Score(receipt)
+	require.Equal(t, int64(14*2+100), breakdown.Total)
+	require.Equal(t, "happy_hour", breakdown.Rules[1].Rule)
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"version": "json",
+		"rules": [
+			{"kind": "item_description_length", "params": {"lengthMultiple": 4, "priceMultiplier": 1}},
+			{"kind": "item_pairs", "params": {"itemsPerGroup": 4, "points": 20}}
+		]
+	}`), 0o644))
+
+	rs, err := Load(path)
+	require.NoError(t, err)
+
+	// "Gatorade" has 8 characters: 3 points for each of the four items and one group of four
+	require.Equal(t, int64(4*3+20), rs.Score(marketReceipt()).Total)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v1.yaml"), defaultConfig, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v2.json"), []byte(`{
+		"version": "2",
+		"rules": [{"kind": "round_dollar_total", "params": {"points": 75}}]
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a rule set"), 0o644))
+
+	sets, err := LoadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, "1", sets[0].Version)
+	require.Equal(t, "2", sets[1].Version)
+
+	// Versions must be unique
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v1-copy.yml"), defaultConfig, 0o644))
+	_, err = LoadDir(dir)
+	require.ErrorContains(t, err, "same version")
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := map[string]RuleConfig{
+		"unknown kind":       {Kind: "lucky_number"},
+		"unknown param":      {Kind: "round_dollar_total", Params: map[string]any{"bonus": 10}},
+		"wrong param type":   {Kind: "round_dollar_total", Params: map[string]any{"points": "ten"}},
+		"zero multiple":      {Kind: "total_multiple", Params: map[string]any{"multiple": 0}},
+		"zero group":         {Kind: "item_pairs", Params: map[string]any{"itemsPerGroup": 0}},
+		"bad time window":    {Kind: "purchase_time_window", Params: map[string]any{"start": "16:00", "end": "14:00"}},
+		"malformed time":     {Kind: "purchase_time_window", Params: map[string]any{"start": "2pm"}},
+		"zero length multi":  {Kind: "item_description_length", Params: map[string]any{"lengthMultiple": 0}},
+		"sub-cent multiple":  {Kind: "total_multiple", Params: map[string]any{"multiple": 0.001}},
+		"precise multiplier": {Kind: "item_description_length", Params: map[string]any{"priceMultiplier": 0.1234567}},
+	}
+	for name, rc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(Config{Rules: []RuleConfig{rc}})
+			require.Error(t, err)
+		})
+	}
+
+	_, err := New(Config{Rules: []RuleConfig{{Kind: "odd_purchase_day"}, {Kind: "odd_purchase_day"}}})
+	require.ErrorContains(t, err, "duplicate rule name")
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`version = "1"`), 0o644))
+	_, err := Load(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"strconv"
+	"time"
+)
+
+// Headers of each delivery request
+const (
+	EventHeader     = "X-Receipt-Event"
+	DeliveryHeader  = "X-Receipt-Delivery"
+	TimestampHeader = "X-Receipt-Timestamp"
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the timestamp, a dot and the body
+	SignatureHeader = "X-Receipt-Signature"
+)
+
+// Sign computes the signature header value of a delivery body sent at a Unix timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// spawn runs fn in the background unless the service is closed
+func (s *webhookServiceImpl) spawn(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+}
+
+// start attempts a pending delivery in the background
+func (s *webhookServiceImpl) start(d models.Delivery) {
+	s.spawn(func() { s.deliver(d) })
+}
+
+// deliver makes up to maxAttempts requests for a delivery, recording each attempt, until one succeeds.
+// The delivery becomes a dead letter when every attempt failed.
+func (s *webhookServiceImpl) deliver(d models.Delivery) {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		slog.Error("failed to encode webhook event", "event_id", d.Event.ID, "error", err)
+		return
+	}
+
+	for attempt := 0; attempt < s.maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(s.backoff(attempt)):
+			case <-s.stop:
+				// Left pending for ResumePending
+				return
+			}
+		}
+		// The webhook may have been deleted, or its URL changed, since the delivery was created
+		w, err := s.store.GetWebhook(d.WebhookID)
+		if errors.Is(err, repo.ErrWebhookNotFound) {
+			return
+		}
+		if err != nil {
+			slog.Error("failed to retrieve webhook", "webhook_id", d.WebhookID, "delivery_id", d.ID, "error", err)
+			return
+		}
+
+		result := s.attempt(w, d, body)
+		d.Attempts = append(d.Attempts, result)
+		if result.Error == "" {
+			d.Status = models.DeliverySucceeded
+		} else if attempt == s.maxAttempts-1 {
+			d.Status = models.DeliveryDead
+		}
+		if err := s.store.PutDelivery(d); err != nil {
+			// A webhook deleted during the request took its deliveries with it
+			if !errors.Is(err, repo.ErrWebhookNotFound) {
+				slog.Error("failed to store webhook delivery", "delivery_id", d.ID, "error", err)
+			}
+			return
+		}
+		if d.Status == models.DeliveryDead {
+			slog.Warn("webhook delivery is a dead letter", "delivery_id", d.ID, "webhook_id", d.WebhookID,
+				"event_id", d.Event.ID, "attempts", len(d.Attempts), "error", result.Error)
+		}
+		if d.Status != models.DeliveryPending {
+			return
+		}
+	}
+}
+
+// pruneLoop deletes the succeeded deliveries older than the retention every pruneInterval until the service is closed
+func (s *webhookServiceImpl) pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		s.prune()
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// prune deletes the succeeded deliveries older than the retention. Dead letters are kept for redelivery.
+func (s *webhookServiceImpl) prune() {
+	q := repo.DeliveryQuery{Status: models.DeliverySucceeded, Before: s.now().Add(-s.retention)}
+	deleted, err := s.store.DeleteDeliveries(q)
+	if err != nil {
+		slog.Error("failed to delete expired webhook deliveries", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("deleted expired webhook deliveries", "deliveries", deleted, "before", q.Before)
+	}
+}
+
+// backoff is the wait before an attempt, doubling from baseDelay with every failed attempt up to maxDelay
+func (s *webhookServiceImpl) backoff(attempt int) time.Duration {
+	delay := s.baseDelay
+	for i := 1; i < attempt && delay < s.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxDelay)
+}
+
+// attempt posts the signed event to the webhook; any response other than 2xx is a failure
+func (s *webhookServiceImpl) attempt(w models.Webhook, d models.Delivery, body []byte) models.DeliveryAttempt {
+	now := s.now().UTC()
+	result := models.DeliveryAttempt{At: now}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event.Type)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, now.Unix(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Error = "unexpected status " + resp.Status
+	}
+	return result
+}
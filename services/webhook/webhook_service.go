@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookService interface {
+	CreateWebhook(w models.Webhook) (models.Webhook, error)
+	GetWebhook(id string) (models.Webhook, error)
+	ListWebhooks() ([]models.Webhook, error)
+	DeleteWebhook(id string) error
+	ListDeliveries(webhookID string, limit int) ([]models.Delivery, error)
+	ListDeadLetters(limit int) ([]models.Delivery, error)
+	Redeliver(deliveryID string) (models.Delivery, error)
+	Publish(event models.ReceiptEvent)
+	ResumePending() error
+	Close(ctx context.Context) error
+}
+
+// ErrNotDead is returned when redelivering a delivery that is not a dead letter
+var ErrNotDead = errors.New("delivery is not a dead letter")
+
+type webhookServiceImpl struct {
+	store  repo.WebhookStore
+	client *http.Client
+	now    func() time.Time
+	// maxAttempts requests are made for each delivery, waiting baseDelay after the first
+	// failure and doubling the wait after each further failure up to maxDelay
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	// retention is how long succeeded deliveries are kept, forever if zero
+	retention time.Duration
+	pruning   sync.Once
+
+	// stop is closed by Close, wg tracks the deliveries in progress
+	stop chan struct{}
+	wg   sync.WaitGroup
+	// mu orders starting deliveries before closing
+	mu     sync.Mutex
+	closed bool
+}
+
+// Option customizes a WebhookService
+type Option func(*webhookServiceImpl)
+
+// WithHTTPClient sends deliveries with the given client instead of one with a 10 second timeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *webhookServiceImpl) {
+		s.client = client
+	}
+}
+
+// WithRetryPolicy makes up to maxAttempts requests for each delivery, waiting baseDelay after the first
+// failed one and twice as long after each further failure, up to maxDelay. Defaults to 8 attempts
+// waiting from 1 second to 5 minutes.
+func WithRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(s *webhookServiceImpl) {
+		s.maxAttempts = max(maxAttempts, 1)
+		s.baseDelay = baseDelay
+		s.maxDelay = maxDelay
+	}
+}
+
+// defaultRetention is how long succeeded deliveries are kept unless WithDeliveryRetention says otherwise
+const defaultRetention = 7 * 24 * time.Hour
+
+// pruneInterval is how often expired deliveries are deleted
+const pruneInterval = time.Hour
+
+// WithDeliveryRetention deletes succeeded deliveries once they are older than retention, zero keeps them forever.
+// Defaults to 7 days. Dead letters and pending deliveries are always kept.
+func WithDeliveryRetention(retention time.Duration) Option {
+	return func(s *webhookServiceImpl) {
+		s.retention = max(retention, 0)
+	}
+}
+
+// NewWebhookService creates a WebhookService keeping webhooks and their deliveries in store
+func NewWebhookService(store repo.WebhookStore, opts ...Option) WebhookService {
+	s := &webhookServiceImpl{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		maxAttempts: 8,
+		baseDelay:   time.Second,
+		maxDelay:    5 * time.Minute,
+		retention:   defaultRetention,
+		stop:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Validates and stores a new webhook with a generated ID, and a generated secret unless one is given
+func (s *webhookServiceImpl) CreateWebhook(w models.Webhook) (models.Webhook, error) {
+	if errs := w.Validate(); len(errs) > 0 {
+		return models.Webhook{}, errs
+	}
+	w.ID = uuid.New().String()
+	w.CreatedAt = s.now().UTC()
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+	if w.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return models.Webhook{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		w.Secret = hex.EncodeToString(secret)
+	}
+	if err := s.store.PutWebhook(w); err != nil {
+		return models.Webhook{}, fmt.Errorf("failed to store webhook with id %s: %w", w.ID, err)
+	}
+	return w, nil
+}
+
+// Retrieves a webhook by ID
+func (s *webhookServiceImpl) GetWebhook(id string) (models.Webhook, error) {
+	w, err := s.store.GetWebhook(id)
+	if err != nil {
+		if errors.Is(err, repo.ErrWebhookNotFound) {
+			return models.Webhook{}, fmt.Errorf("webhook with id %s does not exist: %w", id, err)
+		}
+		return models.Webhook{}, fmt.Errorf("failed to retrieve webhook with id %s: %w", id, err)
+	}
+	return w, nil
+}
+
+// Lists every webhook ordered by creation time
+func (s *webhookServiceImpl) ListWebhooks() ([]models.Webhook, error) {
+	list, err := s.store.ListWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return list, nil
+}
+
+// Deletes a webhook with its delivery history. Deliveries in progress are abandoned.
+func (s *webhookServiceImpl) DeleteWebhook(id string) error {
+	if err := s.store.DeleteWebhook(id); err != nil {
+		if errors.Is(err, repo.ErrWebhookNotFound) {
+			return fmt.Errorf("webhook with id %s does not exist: %w", id, err)
+		}
+		return fmt.Errorf("failed to delete webhook with id %s: %w", id, err)
+	}
+	return nil
+}
+
+// Lists the latest deliveries to a webhook, newest first
+func (s *webhookServiceImpl) ListDeliveries(webhookID string, limit int) ([]models.Delivery, error) {
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	list, err := s.store.ListDeliveries(repo.DeliveryQuery{WebhookID: webhookID, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries of webhook with id %s: %w", webhookID, err)
+	}
+	return list, nil
+}
+
+// Lists the latest deliveries of every webhook that failed all their attempts, newest first
+func (s *webhookServiceImpl) ListDeadLetters(limit int) ([]models.Delivery, error) {
+	list, err := s.store.ListDeliveries(repo.DeliveryQuery{Status: models.DeliveryDead, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return list, nil
+}
+
+// Attempts a dead letter again with a fresh round of retries, keeping its earlier attempts
+func (s *webhookServiceImpl) Redeliver(deliveryID string) (models.Delivery, error) {
+	d, err := s.store.GetDelivery(deliveryID)
+	if err != nil {
+		if errors.Is(err, repo.ErrDeliveryNotFound) {
+			return models.Delivery{}, fmt.Errorf("delivery with id %s does not exist: %w", deliveryID, err)
+		}
+		return models.Delivery{}, fmt.Errorf("failed to retrieve delivery with id %s: %w", deliveryID, err)
+	}
+	if d.Status != models.DeliveryDead {
+		return models.Delivery{}, fmt.Errorf("delivery with id %s is %s: %w", deliveryID, d.Status, ErrNotDead)
+	}
+	d.Status = models.DeliveryPending
+	if err := s.store.PutDelivery(d); err != nil {
+		return models.Delivery{}, fmt.Errorf("failed to store delivery with id %s: %w", deliveryID, err)
+	}
+	s.start(d)
+	return d, nil
+}
+
+// Delivers an event to every webhook subscribed to its type in the background
+func (s *webhookServiceImpl) Publish(event models.ReceiptEvent) {
+	s.spawn(func() {
+		webhooks, err := s.store.ListWebhooks()
+		if err != nil {
+			slog.Error("failed to list webhooks", "event_id", event.ID, "error", err)
+			return
+		}
+		for _, w := range webhooks {
+			if !w.Subscribes(event.Type) {
+				continue
+			}
+			d := models.Delivery{
+				ID:        uuid.New().String(),
+				WebhookID: w.ID,
+				Event:     event,
+				Status:    models.DeliveryPending,
+				Attempts:  []models.DeliveryAttempt{},
+				CreatedAt: s.now().UTC(),
+			}
+			if err := s.store.PutDelivery(d); err != nil {
+				if !errors.Is(err, repo.ErrWebhookNotFound) {
+					slog.Error("failed to store webhook delivery", "event_id", event.ID, "webhook_id", w.ID, "error", err)
+				}
+				continue
+			}
+			s.start(d)
+		}
+	})
+}
+
+// Restarts the deliveries left pending when the service was last closed, and starts deleting
+// expired deliveries in the background
+func (s *webhookServiceImpl) ResumePending() error {
+	pending, err := s.store.ListDeliveries(repo.DeliveryQuery{Status: models.DeliveryPending})
+	if err != nil {
+		return fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+	for _, d := range pending {
+		s.start(d)
+	}
+	if s.retention > 0 {
+		s.pruning.Do(func() { s.spawn(s.pruneLoop) })
+	}
+	return nil
+}
+
+// Stops retrying and waits for the requests in progress to finish, or for ctx to be done.
+// Deliveries waiting for a retry stay pending and are resumed by ResumePending.
+func (s *webhookServiceImpl) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	"receipt-processor/repo"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// WebhookServiceTestSuite defines the suite for service tests
+type WebhookServiceTestSuite struct {
+	suite.Suite
+	store   *repo.MemoryStore
+	service WebhookService
+	// failures is how many requests the receiver fails before succeeding, -1 fails every request
+	failures atomic.Int64
+	received chan *http.Request
+	bodies   chan []byte
+	receiver *httptest.Server
+}
+
+// SetupTest starts a receiver and a service retrying quickly
+func (suite *WebhookServiceTestSuite) SetupTest() {
+	suite.store = repo.NewMemoryStore()
+	suite.service = NewWebhookService(suite.store, WithRetryPolicy(3, time.Millisecond, 5*time.Millisecond))
+	suite.failures.Store(0)
+	suite.received = make(chan *http.Request, 10)
+	suite.bodies = make(chan []byte, 10)
+	suite.receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		suite.received <- r
+		suite.bodies <- body
+		if n := suite.failures.Load(); n != 0 {
+			suite.failures.Add(-1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func (suite *WebhookServiceTestSuite) TearDownTest() {
+	suite.NoError(suite.service.Close(context.Background()))
+	suite.receiver.Close()
+}
+
+func (suite *WebhookServiceTestSuite) newEvent(eventType string) models.ReceiptEvent {
+	return models.ReceiptEvent{ID: "e1", Type: eventType, CreatedAt: time.Now().UTC(), ReceiptID: "r1", Points: 28, RuleVersion: "1"}
+}
+
+// waitForStatus waits until the only delivery to a webhook has the given status
+func (suite *WebhookServiceTestSuite) waitForStatus(webhookID, status string) models.Delivery {
+	var delivery models.Delivery
+	suite.Require().Eventually(func() bool {
+		deliveries, err := suite.service.ListDeliveries(webhookID, 0)
+		suite.Require().NoError(err)
+		if len(deliveries) != 1 {
+			return false
+		}
+		delivery = deliveries[0]
+		return delivery.Status == status
+	}, 5*time.Second, time.Millisecond)
+	return delivery
+}
+
+func (suite *WebhookServiceTestSuite) TestPublishSignsEvent() {
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL, Events: []string{models.EventReceiptProcessed}})
+	suite.Require().NoError(err)
+	suite.NotEmpty(w.Secret)
+
+	// Only subscribed events are delivered
+	suite.service.Publish(suite.newEvent(models.EventReceiptDeleted))
+	event := suite.newEvent(models.EventReceiptProcessed)
+	suite.service.Publish(event)
+
+	r := <-suite.received
+	body := <-suite.bodies
+	suite.Equal(models.EventReceiptProcessed, r.Header.Get(EventHeader))
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	suite.Require().NoError(err)
+	suite.Equal(Sign(w.Secret, timestamp, body), r.Header.Get(SignatureHeader))
+	var got models.ReceiptEvent
+	suite.Require().NoError(json.Unmarshal(body, &got))
+	suite.Equal(event, got)
+
+	delivery := suite.waitForStatus(w.ID, models.DeliverySucceeded)
+	suite.Equal(r.Header.Get(DeliveryHeader), delivery.ID)
+	suite.Require().Len(delivery.Attempts, 1)
+	suite.Equal(http.StatusNoContent, delivery.Attempts[0].StatusCode)
+}
+
+func (suite *WebhookServiceTestSuite) TestRetriesWithBackoff() {
+	suite.failures.Store(2)
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL})
+	suite.Require().NoError(err)
+
+	suite.service.Publish(suite.newEvent(models.EventReceiptRescored))
+
+	delivery := suite.waitForStatus(w.ID, models.DeliverySucceeded)
+	suite.Require().Len(delivery.Attempts, 3)
+	suite.Equal(http.StatusInternalServerError, delivery.Attempts[0].StatusCode)
+	suite.NotEmpty(delivery.Attempts[0].Error)
+	suite.Empty(delivery.Attempts[2].Error)
+}
+
+func (suite *WebhookServiceTestSuite) TestDeadLetters() {
+	suite.failures.Store(-1)
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL})
+	suite.Require().NoError(err)
+
+	suite.service.Publish(suite.newEvent(models.EventReceiptProcessed))
+
+	dead := suite.waitForStatus(w.ID, models.DeliveryDead)
+	suite.Len(dead.Attempts, 3)
+	letters, err := suite.service.ListDeadLetters(0)
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{dead}, letters)
+
+	// Redelivering starts a new round of attempts once the receiver recovers
+	suite.failures.Store(0)
+	_, err = suite.service.Redeliver(dead.ID)
+	suite.Require().NoError(err)
+	delivered := suite.waitForStatus(w.ID, models.DeliverySucceeded)
+	suite.Len(delivered.Attempts, 4)
+	_, err = suite.service.Redeliver(dead.ID)
+	suite.ErrorIs(err, ErrNotDead)
+	_, err = suite.service.Redeliver("missing")
+	suite.ErrorIs(err, repo.ErrDeliveryNotFound)
+}
+
+func (suite *WebhookServiceTestSuite) TestResumePending() {
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL})
+	suite.Require().NoError(err)
+	pending := models.Delivery{ID: "d1", WebhookID: w.ID, Event: suite.newEvent(models.EventReceiptDeleted), Status: models.DeliveryPending}
+	suite.Require().NoError(suite.store.PutDelivery(pending))
+
+	suite.NoError(suite.service.ResumePending())
+
+	suite.waitForStatus(w.ID, models.DeliverySucceeded)
+}
+
+func (suite *WebhookServiceTestSuite) TestCreateAndDeleteWebhook() {
+	_, err := suite.service.CreateWebhook(models.Webhook{URL: "ftp://example.com"})
+	var errs models.ValidationErrors
+	suite.ErrorAs(err, &errs)
+
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL, Secret: "shared"})
+	suite.Require().NoError(err)
+	suite.Equal("shared", w.Secret)
+	suite.Equal([]string{}, w.Events)
+
+	suite.NoError(suite.service.DeleteWebhook(w.ID))
+	_, err = suite.service.GetWebhook(w.ID)
+	suite.ErrorIs(err, repo.ErrWebhookNotFound)
+	_, err = suite.service.ListDeliveries(w.ID, 0)
+	suite.ErrorIs(err, repo.ErrWebhookNotFound)
+	suite.ErrorIs(suite.service.DeleteWebhook(w.ID), repo.ErrWebhookNotFound)
+}
+
+func (suite *WebhookServiceTestSuite) TestDeleteWebhookDuringDelivery() {
+	release := make(chan struct{})
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.received <- r
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer blocking.Close()
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: blocking.URL})
+	suite.Require().NoError(err)
+
+	// The webhook is deleted while its delivery request is in flight
+	suite.service.Publish(suite.newEvent(models.EventReceiptProcessed))
+	<-suite.received
+	suite.Require().NoError(suite.service.DeleteWebhook(w.ID))
+	close(release)
+	suite.Require().NoError(suite.service.Close(context.Background()))
+
+	// The finished delivery is not stored again for ResumePending to restart
+	deliveries, err := suite.store.ListDeliveries(repo.DeliveryQuery{})
+	suite.NoError(err)
+	suite.Empty(deliveries)
+}
+
+func (suite *WebhookServiceTestSuite) TestPruneDeliveries() {
+	suite.failures.Store(-1)
+	w, err := suite.service.CreateWebhook(models.Webhook{URL: suite.receiver.URL})
+	suite.Require().NoError(err)
+	suite.service.Publish(suite.newEvent(models.EventReceiptProcessed))
+	dead := suite.waitForStatus(w.ID, models.DeliveryDead)
+	suite.failures.Store(0)
+	suite.service.Publish(suite.newEvent(models.EventReceiptDeleted))
+	suite.Require().Eventually(func() bool {
+		deliveries, err := suite.store.ListDeliveries(repo.DeliveryQuery{Status: models.DeliverySucceeded})
+		suite.Require().NoError(err)
+		return len(deliveries) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	// Deliveries within the retention are kept
+	s := suite.service.(*webhookServiceImpl)
+	s.prune()
+	deliveries, err := suite.store.ListDeliveries(repo.DeliveryQuery{})
+	suite.NoError(err)
+	suite.Len(deliveries, 2)
+
+	// Expired succeeded deliveries are deleted, dead letters are kept for redelivery
+	s.now = func() time.Time { return time.Now().Add(defaultRetention + time.Minute) }
+	s.prune()
+	deliveries, err = suite.store.ListDeliveries(repo.DeliveryQuery{})
+	suite.NoError(err)
+	suite.Equal([]models.Delivery{dead}, deliveries)
+}
+
+func TestBackoff(t *testing.T) {
+	s := NewWebhookService(repo.NewMemoryStore(), WithRetryPolicy(10, time.Second, 5*time.Second)).(*webhookServiceImpl)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		require.Equal(t, delay, s.backoff(i+1), "attempt %d", i+1)
+	}
+}
+
+func TestWebhookServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookServiceTestSuite))
+}
@@ -29,7 +29,7 @@ func (suite *SmokeTestSuite) SetupSuite() {
 		Retailer:     "Target",
 		PurchaseDate: "2022-01-01",
 		PurchaseTime: "13:01",
-		Items: []models.Item{
+		Items: []models.ExtItem{
 			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
 			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
 			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
@@ -0,0 +1,101 @@
+// Package tracing sets up OpenTelemetry tracing, exporting spans over OTLP or to stdout,
+// and continues the W3C trace context of incoming requests.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName identifies the service in exported traces
+const ServiceName = "receipt-processor"
+
+// Exporters spans can be sent to
+const (
+	// ExporterNone records no spans, trace context is still propagated
+	ExporterNone = "none"
+	// ExporterStdout writes spans to stdout as JSON
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OTLP receiver over HTTP
+	ExporterOTLP = "otlp"
+)
+
+// Options configures tracing
+type Options struct {
+	// Exporter is none, stdout or otlp
+	Exporter string
+	// Endpoint is the host:port of the OTLP receiver. The OTEL_EXPORTER_OTLP_ENDPOINT
+	// environment variable, or localhost:4318, is used if empty.
+	Endpoint string
+	// Insecure sends spans to the OTLP receiver over plain HTTP
+	Insecure bool
+	// SampleRatio is the fraction of new traces recorded. Requests continuing a trace
+	// are recorded if the caller recorded them.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider exporting spans as configured, and the W3C trace context
+// and baggage propagators. The returned function flushes the spans not yet exported and stops exporting.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a server span named after the route of each request, continuing the trace of
+// its traceparent header if there is one. Requests to the paths given are not traced.
+// It uses the global tracer provider and propagators, so Setup must be called first.
+func Middleware(untraced ...string) gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		for _, path := range untraced {
+			if r.URL.Path == path {
+				return false
+			}
+		}
+		return true
+	}))
+}
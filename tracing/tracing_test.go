@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipt-processor/models"
+	receipt_handler "receipt-processor/public/v1/receipt"
+	"receipt-processor/repo"
+	receiptSvc "receipt-processor/services/receipt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceParent is the W3C trace context of a caller, with the trace ID and span ID below
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{ExporterNone, ExporterStdout, ExporterOTLP} {
+		shutdown, err := Setup(context.Background(), Options{Exporter: exporter, Endpoint: "localhost:4318", Insecure: true, SampleRatio: 1})
+		require.NoError(t, err, exporter)
+		require.NoError(t, shutdown(context.Background()), exporter)
+	}
+
+	_, err := Setup(context.Background(), Options{Exporter: "jaeger"})
+	require.ErrorContains(t, err, `unknown trace exporter "jaeger"`)
+}
+
+func TestMiddleware(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	require.NoError(t, err)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	router := gin.New()
+	router.Use(Middleware("/metrics"))
+	router.GET("/metrics", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	store := repo.NewMemoryStore()
+	receipt_handler.Register(router, receiptSvc.NewReceiptService(store, receiptSvc.WithTracerProvider(provider)))
+
+	body, _ := json.Marshal(models.ExtReceipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []models.ExtItem{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:        "6.49",
+	})
+	req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", traceParent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	require.Len(t, spans, 4)
+	require.NotContains(t, spans, "/metrics")
+
+	// The server span continues the trace of the caller, and the service and store spans are under it
+	server := spans["/receipts/process"]
+	require.Equal(t, trace.SpanKindServer, server.SpanKind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	require.True(t, server.Parent.IsRemote())
+	process := spans["ReceiptService.ProcessReceipt"]
+	require.Equal(t, server.SpanContext.SpanID(), process.Parent.SpanID())
+	require.Equal(t, process.SpanContext.SpanID(), spans["receipt.score"].Parent.SpanID())
+	require.Equal(t, process.SpanContext.SpanID(), spans["store.Put"].Parent.SpanID())
+	require.Equal(t, trace.SpanKindClient, spans["store.Put"].SpanKind)
+}
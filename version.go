@@ -0,0 +1,42 @@
+// version.go
+// Build information reported by the /version endpoint.
+
+package main
+
+import (
+	"errors"
+	"receipt-processor/services/rules"
+	"runtime/debug"
+)
+
+// Set at build time with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildCommit returns the commit set at build time, or else the revision the Go toolchain
+// stamped into the binary when it was built from a git checkout
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// checkRuleSet fails if no rule set is loaded, which would award no points to any receipt
+func checkRuleSet(ruleSet *rules.RuleSet) error {
+	if ruleSet == nil || len(ruleSet.Rules) == 0 {
+		return errors.New("no scoring rules are loaded")
+	}
+	return nil
+}